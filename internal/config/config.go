@@ -0,0 +1,248 @@
+// Package config reads the optional snips configuration file,
+// which holds settings too bulky or too stable for flags.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	dirName  = "snips"
+	fileName = "config.yaml"
+
+	// AnyProject is the ClosedStatuses key for projects not otherwise listed.
+	AnyProject = "*"
+
+	// Placeholders that may appear in a Section's Query.
+	PlaceholderUser  = "{user}"
+	PlaceholderStart = "{start}"
+	PlaceholderEnd   = "{end}"
+
+	// DefaultDateQualifier is the GitHubSection.DateQualifier if none is given.
+	DefaultDateQualifier = "updated"
+)
+
+// DefaultClosedStatuses are the statuses that close an issue,
+// for projects not mentioned in the config.
+var DefaultClosedStatuses = []string{"Resolved", "Done", "Closed"}
+
+// Config is the content of the configuration file.
+type Config struct {
+	Jira      Jira      `yaml:"jira"`
+	GitHub    GitHub    `yaml:"github"`
+	Bitbucket Bitbucket `yaml:"bitbucket"`
+}
+
+// Section is an extra report section, listing the issues found by a search
+// the tool doesn't otherwise know about, e.g. "On-call Tickets".
+type Section struct {
+	// Name labels the section in the report.
+	Name string `yaml:"name"`
+	// Query is the search, which may hold the placeholders PlaceholderUser,
+	// PlaceholderStart and PlaceholderEnd.
+	Query string `yaml:"query"`
+}
+
+// Expand returns the Query with its placeholders replaced.
+func (s *Section) Expand(user string, start string, end string) string {
+	return strings.NewReplacer(
+		PlaceholderUser, user,
+		PlaceholderStart, start,
+		PlaceholderEnd, end,
+	).Replace(s.Query)
+}
+
+// GitHubSection is a Section whose Query is a GitHub issue search.
+type GitHubSection struct {
+	Section `yaml:",inline"`
+	// DateQualifier is the issue date restricted to the report's
+	// day range, e.g. "created", "closed" or "updated".
+	DateQualifier string `yaml:"dateQualifier"`
+}
+
+// GitHub holds settings for the GitHub instance.
+type GitHub struct {
+	// Sections are extra report sections made from GitHub issue searches.
+	Sections []GitHubSection `yaml:"sections"`
+	// Domains register GitHub Enterprise instances snips may log in to.
+	Domains []GitHubDomain `yaml:"domains"`
+}
+
+// GitHubDomain registers a GitHub instance, e.g. an enterprise server,
+// with the OAuth app that snips logs in to it with.
+type GitHubDomain struct {
+	// Domain is the instance's host, as given to --gh-domain,
+	// e.g. "github.acmecorp.com".
+	Domain string `yaml:"domain"`
+	// ClientId is the client id of the OAuth app registered for
+	// snips on the instance, with device flow enabled.
+	ClientId string `yaml:"clientId"`
+	// ApiUrl is the root of the instance's REST API, if it isn't
+	// the usual https://{domain}/api/v3/.
+	ApiUrl string `yaml:"apiUrl,omitempty"`
+}
+
+// Validate complains about a malformed domain.
+func (d *GitHubDomain) Validate() error {
+	if d.Domain == "" || strings.ContainsAny(d.Domain, "/ ") {
+		return fmt.Errorf("github domain %q should be a host, e.g. github.acmecorp.com", d.Domain)
+	}
+	if d.ClientId == "" {
+		return fmt.Errorf("github domain %s has no clientId", d.Domain)
+	}
+	if d.ApiUrl != "" {
+		u, err := url.Parse(d.ApiUrl)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("apiUrl %q of github domain %s isn't an http(s) url", d.ApiUrl, d.Domain)
+		}
+	}
+	return nil
+}
+
+// FindDomain returns the registration of the given domain, or nil.
+func (g *GitHub) FindDomain(domain string) *GitHubDomain {
+	for i := range g.Domains {
+		if strings.EqualFold(g.Domains[i].Domain, domain) {
+			return &g.Domains[i]
+		}
+	}
+	return nil
+}
+
+// Jira holds settings for the jira instance.
+type Jira struct {
+	// ClosedStatuses maps a project key (e.g. "MSFT") to the workflow statuses
+	// that mean an issue in that project is closed.  The AnyProject key
+	// applies to projects not otherwise listed.
+	ClosedStatuses map[string][]string `yaml:"closedStatuses"`
+	// SprintField is the id of the custom field holding an issue's
+	// sprints, e.g. "customfield_10020".
+	SprintField string `yaml:"sprintField"`
+	// EpicLinkField is the id of the custom field holding the key of
+	// an issue's epic, e.g. "customfield_10014".  If empty, or if an
+	// issue doesn't have it, the issue's parent is used if it's an epic.
+	EpicLinkField string `yaml:"epicLinkField"`
+	// Sections are extra report sections made from JQL searches.
+	Sections []Section `yaml:"sections"`
+}
+
+// Bitbucket holds settings for the Bitbucket Server or Data Center instance.
+type Bitbucket struct {
+	// Repos are the repositories searched for activity, each either
+	// "{projectKey}/{repoSlug}" or "{projectKey}" for all the repos
+	// in a project.  If empty, all repos the token can see are searched.
+	Repos []string `yaml:"repos"`
+}
+
+// IsClosedStatus is true if moving an issue in the given project
+// to the given status closes it.
+func (j *Jira) IsClosedStatus(projectKey string, status string) bool {
+	statuses, ok := j.ClosedStatuses[projectKey]
+	if !ok {
+		statuses, ok = j.ClosedStatuses[AnyProject]
+	}
+	if !ok {
+		statuses = DefaultClosedStatuses
+	}
+	return slices.ContainsFunc(statuses, func(s string) bool {
+		return strings.EqualFold(s, status)
+	})
+}
+
+// DefaultPath returns where the config file lives if not specified,
+// e.g. ~/.config/snips/config.yaml on linux.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, dirName, fileName), nil
+}
+
+// Load reads the config file at the given path.  If the path is empty,
+// the file at DefaultPath is read if it exists, else the config is empty.
+func Load(path string) (*Config, error) {
+	if path == "" {
+		var err error
+		if path, err = DefaultPath(); err != nil {
+			return &Config{}, nil
+		}
+		if _, err = os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			return &Config{}, nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("trouble reading config; %w", err)
+	}
+	return parse(path, data)
+}
+
+// parse returns the config in data, read from path.
+func parse(path string, data []byte) (*Config, error) {
+	var result Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&result); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("trouble parsing config %s; %w", path, err)
+	}
+	if err := result.validate(); err != nil {
+		return nil, fmt.Errorf("bad config %s; %w", path, err)
+	}
+	return &result, nil
+}
+
+// validate complains about sections that can't be searched or told apart,
+// and about malformed repos and domains.
+func (c *Config) validate() error {
+	domains := make(map[string]bool)
+	for i := range c.GitHub.Domains {
+		d := &c.GitHub.Domains[i]
+		if err := d.Validate(); err != nil {
+			return err
+		}
+		if domains[strings.ToLower(d.Domain)] {
+			return fmt.Errorf("github domain %s is registered more than once", d.Domain)
+		}
+		domains[strings.ToLower(d.Domain)] = true
+	}
+	for _, r := range c.Bitbucket.Repos {
+		project, slug, hasSlug := strings.Cut(r, "/")
+		if project == "" || (hasSlug && (slug == "" || strings.Contains(slug, "/"))) {
+			return fmt.Errorf("bitbucket repo %q isn't of the form PROJECT or PROJECT/repo", r)
+		}
+	}
+	sections := slices.Clone(c.Jira.Sections)
+	for i := range c.GitHub.Sections {
+		s := &c.GitHub.Sections[i]
+		if s.DateQualifier == "" {
+			s.DateQualifier = DefaultDateQualifier
+		}
+		sections = append(sections, s.Section)
+	}
+	seen := make(map[string]bool)
+	for _, s := range sections {
+		if s.Name == "" {
+			return fmt.Errorf("section with query %q has no name", s.Query)
+		}
+		if s.Query == "" {
+			return fmt.Errorf("section %q has no query", s.Name)
+		}
+		if seen[strings.ToLower(s.Name)] {
+			return fmt.Errorf("more than one section named %q", s.Name)
+		}
+		seen[strings.ToLower(s.Name)] = true
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/monopole/snips/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		want    *Config
+		errMsg  string
+	}{
+		"empty": {
+			content: "",
+			want:    &Config{},
+		},
+		"closedStatuses": {
+			content: `
+jira:
+  closedStatuses:
+    MSFT: [Shipped, "Won't Do"]
+    "*": [Done]
+`,
+			want: &Config{Jira: Jira{ClosedStatuses: map[string][]string{
+				"MSFT": {"Shipped", "Won't Do"},
+				"*":    {"Done"},
+			}}},
+		},
+		"sections": {
+			content: `
+jira:
+  sections:
+  - name: On-call Tickets
+    query: labels = oncall and assignee = "{user}"
+github:
+  sections:
+  - name: Security PRs
+    query: involves:{user} label:security
+  - name: Releases
+    query: author:{user} label:release
+    dateQualifier: closed
+`,
+			want: &Config{
+				Jira: Jira{Sections: []Section{
+					{Name: "On-call Tickets", Query: `labels = oncall and assignee = "{user}"`},
+				}},
+				GitHub: GitHub{Sections: []GitHubSection{
+					{
+						Section:       Section{Name: "Security PRs", Query: "involves:{user} label:security"},
+						DateQualifier: DefaultDateQualifier,
+					},
+					{
+						Section:       Section{Name: "Releases", Query: "author:{user} label:release"},
+						DateQualifier: "closed",
+					},
+				}},
+			},
+		},
+		"section without query": {
+			content: `
+jira:
+  sections:
+  - name: On-call Tickets
+`,
+			errMsg: `section "On-call Tickets" has no query`,
+		},
+		"section without name": {
+			content: `
+github:
+  sections:
+  - query: involves:{user}
+`,
+			errMsg: "has no name",
+		},
+		"duplicate sections": {
+			content: `
+jira:
+  sections:
+  - name: On-call
+    query: labels = oncall
+github:
+  sections:
+  - name: on-call
+    query: label:oncall
+`,
+			errMsg: `more than one section named "on-call"`,
+		},
+		"bitbucket repos": {
+			content: `
+bitbucket:
+  repos: [PLAT/build, OPS]
+`,
+			want: &Config{Bitbucket: Bitbucket{Repos: []string{"PLAT/build", "OPS"}}},
+		},
+		"bad bitbucket repo": {
+			content: `
+bitbucket:
+  repos: [PLAT/build/x]
+`,
+			errMsg: `bitbucket repo "PLAT/build/x" isn't of the form`,
+		},
+		"github domains": {
+			content: `
+github:
+  domains:
+  - domain: github.acmecorp.com
+    clientId: Iv1.abc
+  - domain: code.example.org
+    clientId: Iv1.def
+    apiUrl: https://code.example.org/github/api/v3/
+`,
+			want: &Config{GitHub: GitHub{Domains: []GitHubDomain{
+				{Domain: "github.acmecorp.com", ClientId: "Iv1.abc"},
+				{Domain: "code.example.org", ClientId: "Iv1.def", ApiUrl: "https://code.example.org/github/api/v3/"},
+			}}},
+		},
+		"github domain without client id": {
+			content: `
+github:
+  domains:
+  - domain: github.acmecorp.com
+`,
+			errMsg: "github domain github.acmecorp.com has no clientId",
+		},
+		"github domain with scheme": {
+			content: `
+github:
+  domains:
+  - domain: https://github.acmecorp.com
+    clientId: Iv1.abc
+`,
+			errMsg: "should be a host",
+		},
+		"github domain with bad api url": {
+			content: `
+github:
+  domains:
+  - domain: github.acmecorp.com
+    clientId: Iv1.abc
+    apiUrl: github.acmecorp.com/api/v3
+`,
+			errMsg: "isn't an http(s) url",
+		},
+		"duplicate github domains": {
+			content: `
+github:
+  domains:
+  - domain: github.acmecorp.com
+    clientId: Iv1.abc
+  - domain: GitHub.AcmeCorp.com
+    clientId: Iv1.def
+`,
+			errMsg: "github domain GitHub.AcmeCorp.com is registered more than once",
+		},
+		"misspelled": {
+			content: `
+jira:
+  closedStatus:
+    MSFT: [Shipped]
+`,
+			errMsg: "field closedStatus not found",
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			assert.NoError(t, os.WriteFile(path, []byte(tt.content), 0o644))
+			got, err := Load(path)
+			if tt.errMsg != "" {
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "nope.yaml"))
+	assert.Error(t, err)
+}
+
+func TestGitHub_FindDomain(t *testing.T) {
+	g := GitHub{Domains: []GitHubDomain{{Domain: "github.acmecorp.com", ClientId: "Iv1.abc"}}}
+	assert.Equal(t, "Iv1.abc", g.FindDomain("GitHub.acmecorp.com").ClientId)
+	assert.Nil(t, g.FindDomain("github.com"))
+}
+
+func TestJira_IsClosedStatus(t *testing.T) {
+	configured := Jira{ClosedStatuses: map[string][]string{
+		"MSFT": {"Shipped"},
+		"*":    {"Done", "Closed"},
+	}}
+	tests := map[string]struct {
+		j       Jira
+		project string
+		status  string
+		want    bool
+	}{
+		"default resolved":      {project: "MSFT", status: "Resolved", want: true},
+		"default ignores case":  {project: "MSFT", status: "done", want: true},
+		"default in progress":   {project: "MSFT", status: "In Progress"},
+		"project specific":      {j: configured, project: "MSFT", status: "Shipped", want: true},
+		"project overrides any": {j: configured, project: "MSFT", status: "Done"},
+		"any project":           {j: configured, project: "PLM", status: "Closed", want: true},
+		"any project resolved":  {j: configured, project: "PLM", status: "Resolved"},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.j.IsClosedStatus(tt.project, tt.status))
+		})
+	}
+}
+
+func TestSection_Expand(t *testing.T) {
+	s := Section{Query: `assignee = "{user}" and updated >= '{start}' and updated < '{end}'`}
+	assert.Equal(t,
+		`assignee = "bob" and updated >= '2023/01/03' and updated < '2023/01/18'`,
+		s.Expand("bob", "2023/01/03", "2023/01/18"))
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CmdAddDomain registers a GitHub domain in the config file,
+// e.g. "snips auth add-domain github.acmecorp.com Iv1.0123456789abcdef".
+const CmdAddDomain = "add-domain"
+
+// RunAddDomain registers the GitHub domain described by args,
+// "{domain} {clientId} [{apiUrl}]", in the config file at path
+// (if empty, DefaultPath), and says so to w.
+func RunAddDomain(w io.Writer, path string, args []string) error {
+	if len(args) < 2 || len(args) > 3 {
+		return fmt.Errorf("usage: %s {domain} {clientId} [{apiUrl}]", CmdAddDomain)
+	}
+	d := GitHubDomain{Domain: args[0], ClientId: args[1]}
+	if len(args) > 2 {
+		d.ApiUrl = args[2]
+	}
+	path, err := AddGitHubDomain(path, d)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "Registered %s in %s\n", d.Domain, path)
+	return err
+}
+
+// AddGitHubDomain adds the domain to the config file at path (if
+// empty, DefaultPath), replacing any registration it already has, and
+// returns the file's path.  The rest of the file, comments included,
+// is kept.
+func AddGitHubDomain(path string, d GitHubDomain) (string, error) {
+	if err := d.Validate(); err != nil {
+		return "", err
+	}
+	if path == "" {
+		var err error
+		if path, err = DefaultPath(); err != nil {
+			return "", err
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("trouble reading config; %w", err)
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return "", fmt.Errorf("trouble parsing config %s; %w", path, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return "", fmt.Errorf("config %s isn't a mapping", path)
+	}
+	gh, err := mappingValue(root, "github", yaml.MappingNode)
+	if err != nil {
+		return "", err
+	}
+	domains, err := mappingValue(gh, "domains", yaml.SequenceNode)
+	if err != nil {
+		return "", err
+	}
+	var entry yaml.Node
+	if err = entry.Encode(d); err != nil {
+		return "", err
+	}
+	replaced := false
+	for i, n := range domains.Content {
+		var old GitHubDomain
+		if n.Decode(&old) == nil && strings.EqualFold(old.Domain, d.Domain) {
+			domains.Content[i] = &entry
+			replaced = true
+		}
+	}
+	if !replaced {
+		domains.Content = append(domains.Content, &entry)
+	}
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err = enc.Encode(&doc); err != nil {
+		return "", err
+	}
+	if err = enc.Close(); err != nil {
+		return "", err
+	}
+	// Don't write what can't be read back.
+	if _, err = parse(path, b.Bytes()); err != nil {
+		return "", err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err = os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		return "", fmt.Errorf("trouble writing config; %w", err)
+	}
+	return path, nil
+}
+
+// mappingValue returns the value of the key in the mapping node,
+// adding an empty one of the given kind if it's missing or null.
+func mappingValue(m *yaml.Node, key string, kind yaml.Kind) (*yaml.Node, error) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value != key {
+			continue
+		}
+		v := m.Content[i+1]
+		if v.Kind == yaml.ScalarNode && v.Tag == "!!null" {
+			*v = yaml.Node{Kind: kind}
+		}
+		if v.Kind != kind {
+			return nil, fmt.Errorf("%s in config has an unexpected type", key)
+		}
+		return v, nil
+	}
+	v := &yaml.Node{Kind: kind}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, v)
+	return v, nil
+}
@@ -0,0 +1,121 @@
+package config_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/monopole/snips/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddGitHubDomain(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		domain  GitHubDomain
+		want    string
+		errMsg  string
+	}{
+		"new file": {
+			domain: GitHubDomain{Domain: "github.acmecorp.com", ClientId: "Iv1.abc"},
+			want: `github:
+  domains:
+    - domain: github.acmecorp.com
+      clientId: Iv1.abc
+`,
+		},
+		"keeps the rest": {
+			content: `# My settings.
+jira:
+  sprintField: customfield_10020 # from the admin
+github:
+  sections:
+    - name: Security PRs
+      query: involves:{user} label:security
+`,
+			domain: GitHubDomain{Domain: "github.acmecorp.com", ClientId: "Iv1.abc"},
+			want: `# My settings.
+jira:
+  sprintField: customfield_10020 # from the admin
+github:
+  sections:
+    - name: Security PRs
+      query: involves:{user} label:security
+  domains:
+    - domain: github.acmecorp.com
+      clientId: Iv1.abc
+`,
+		},
+		"replaces": {
+			content: `github:
+  domains:
+    - domain: github.acmecorp.com
+      clientId: Iv1.old
+    - domain: code.example.org
+      clientId: Iv1.def
+`,
+			domain: GitHubDomain{
+				Domain: "GitHub.AcmeCorp.com", ClientId: "Iv1.new", ApiUrl: "https://api.acmecorp.com/"},
+			want: `github:
+  domains:
+    - domain: GitHub.AcmeCorp.com
+      clientId: Iv1.new
+      apiUrl: https://api.acmecorp.com/
+    - domain: code.example.org
+      clientId: Iv1.def
+`,
+		},
+		"empty github": {
+			content: "github:\n",
+			domain:  GitHubDomain{Domain: "github.acmecorp.com", ClientId: "Iv1.abc"},
+			want: `github:
+  domains:
+    - domain: github.acmecorp.com
+      clientId: Iv1.abc
+`,
+		},
+		"bad domain": {
+			domain: GitHubDomain{Domain: "github.acmecorp.com"},
+			errMsg: "has no clientId",
+		},
+		"bad file": {
+			content: "github: [1, 2]\n",
+			domain:  GitHubDomain{Domain: "github.acmecorp.com", ClientId: "Iv1.abc"},
+			errMsg:  "github in config has an unexpected type",
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "snips", "config.yaml")
+			if tt.content != "" {
+				assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+				assert.NoError(t, os.WriteFile(path, []byte(tt.content), 0o644))
+			}
+			got, err := AddGitHubDomain(path, tt.domain)
+			if tt.errMsg != "" {
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, path, got)
+			data, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+			_, err = Load(path)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestRunAddDomain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	var b bytes.Buffer
+	assert.NoError(t, RunAddDomain(&b, path, []string{"github.acmecorp.com", "Iv1.abc"}))
+	assert.Equal(t, "Registered github.acmecorp.com in "+path+"\n", b.String())
+	cfg, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []GitHubDomain{{Domain: "github.acmecorp.com", ClientId: "Iv1.abc"}}, cfg.GitHub.Domains)
+
+	assert.ErrorContains(t, RunAddDomain(&b, path, []string{"github.acmecorp.com"}), "usage: add-domain")
+}
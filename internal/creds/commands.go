@@ -0,0 +1,86 @@
+package creds
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// Commands managing saved tokens, e.g. "snips auth list".
+const (
+	CmdList       = "list"
+	CmdShowScopes = "show-scopes"
+	CmdForget     = "forget"
+	// CmdLogout is another name for CmdForget.  Neither revokes the token
+	// on GitHub; they only delete the saved copy, and say where to revoke it.
+	CmdLogout = "logout"
+)
+
+// AllCommands returns the commands RunCommand knows.
+func AllCommands() []string {
+	return []string{CmdList, CmdShowScopes, CmdForget, CmdLogout}
+}
+
+// RunCommand runs the command in args[0] on the store, writing what it
+// has to say to w.  Commands taking a domain use args[1] if present,
+// else defaultDomain.
+func (s *Store) RunCommand(w io.Writer, args []string, defaultDomain string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no command")
+	}
+	domain := defaultDomain
+	if len(args) > 1 {
+		domain = args[1]
+	}
+	switch args[0] {
+	case CmdList:
+		return s.list(w)
+	case CmdShowScopes:
+		t, err := s.Get(domain)
+		if err != nil {
+			return err
+		}
+		if t == nil {
+			return fmt.Errorf("no token saved for %s", domain)
+		}
+		scopes := t.Scopes
+		if scopes == "" {
+			scopes = "(unknown)"
+		}
+		_, err = fmt.Fprintf(w, "%s\n", scopes)
+		return err
+	case CmdForget, CmdLogout:
+		found, err := s.Forget(domain)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("no token saved for %s", domain)
+		}
+		_, err = fmt.Fprintf(w,
+			"Forgot the token for %s.\nTo revoke it as well, visit https://%s/settings/applications\n",
+			domain, domain)
+		return err
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// list writes a line for each saved token, without its value.
+func (s *Store) list(w io.Writer) error {
+	tokens, err := s.load()
+	if err != nil {
+		return err
+	}
+	domains := sortedDomains(tokens)
+	if len(domains) == 0 {
+		_, err = fmt.Fprintf(w, "No tokens saved in %s\n", s.path)
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DOMAIN\tSAVED\tSCOPES")
+	for _, d := range domains {
+		t := tokens[d]
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", d, t.Saved.Format("2006-01-02 15:04"), t.Scopes)
+	}
+	return tw.Flush()
+}
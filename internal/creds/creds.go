@@ -0,0 +1,282 @@
+// Package creds keeps access tokens, per domain, in a credentials file
+// readable only by its owner, so they needn't be echoed or exported.
+// If given a passphrase, the file's tokens are encrypted with it.
+package creds
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+)
+
+const (
+	dirName  = "snips"
+	fileName = "credentials.json"
+
+	// fileMode keeps the file private to its owner.
+	fileMode fs.FileMode = 0o600
+	dirMode  fs.FileMode = 0o700
+
+	// Key derivation parameters for passphrase encryption.
+	// https://cheatsheetseries.owasp.org/cheatsheets/Password_Storage_Cheat_Sheet.html#pbkdf2
+	kdfIterations = 600_000
+	keyLen        = 32
+	saltLen       = 16
+)
+
+// ErrNeedPassphrase means the file is encrypted and no passphrase was given.
+var ErrNeedPassphrase = errors.New("credentials are encrypted; a passphrase is needed")
+
+// Token is a stored access token.
+type Token struct {
+	Value string `json:"value"`
+	// Scopes are those granted to the token, comma separated as GitHub
+	// reports them, if known.
+	Scopes string    `json:"scopes,omitempty"`
+	Saved  time.Time `json:"saved"`
+}
+
+// fileData is the content of the credentials file.  Either Tokens or
+// Sealed is set; Sealed holds the JSON of Tokens, encrypted.
+type fileData struct {
+	Tokens map[string]Token `json:"tokens,omitempty"`
+	Sealed *sealed          `json:"sealed,omitempty"`
+}
+
+// sealed is data encrypted with AES-GCM, using a key derived
+// from a passphrase with PBKDF2.
+type sealed struct {
+	Salt       []byte `json:"salt"`
+	Iterations int    `json:"iterations"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// Store is a credentials file.
+type Store struct {
+	path string
+	// passphrase, if not empty, encrypts the tokens when saved.
+	passphrase string
+}
+
+// DefaultPath returns where the credentials file lives if not specified,
+// e.g. ~/.config/snips/credentials.json on linux.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, dirName, fileName), nil
+}
+
+// MakeStore returns the store at the given path, or at DefaultPath if
+// the path is empty.  If the passphrase isn't empty, tokens are encrypted.
+func MakeStore(path string, passphrase string) (*Store, error) {
+	if path == "" {
+		var err error
+		if path, err = DefaultPath(); err != nil {
+			return nil, fmt.Errorf("no place for credentials; %w", err)
+		}
+	}
+	return &Store{path: path, passphrase: passphrase}, nil
+}
+
+// Path returns the location of the credentials file.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Get returns the token for the domain, or nil if there isn't one.
+func (s *Store) Get(domain string) (*Token, error) {
+	tokens, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := tokens[domain]; ok {
+		return &t, nil
+	}
+	return nil, nil
+}
+
+// Put saves the token for the domain, replacing any other.
+func (s *Store) Put(domain string, t Token) error {
+	tokens, err := s.load()
+	if err != nil {
+		return err
+	}
+	if t.Saved.IsZero() {
+		t.Saved = time.Now()
+	}
+	tokens[domain] = t
+	return s.save(tokens)
+}
+
+// Forget removes the token for the domain, returning false if there wasn't one.
+func (s *Store) Forget(domain string) (bool, error) {
+	tokens, err := s.load()
+	if err != nil {
+		return false, err
+	}
+	if _, ok := tokens[domain]; !ok {
+		return false, nil
+	}
+	delete(tokens, domain)
+	return true, s.save(tokens)
+}
+
+// Domains returns the domains having tokens, sorted.
+func (s *Store) Domains() ([]string, error) {
+	tokens, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return sortedDomains(tokens), nil
+}
+
+func sortedDomains(tokens map[string]Token) []string {
+	result := make([]string, 0, len(tokens))
+	for d := range tokens {
+		result = append(result, d)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// load returns the tokens in the file, which needn't exist.
+func (s *Store) load() (map[string]Token, error) {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return make(map[string]Token), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("trouble reading credentials; %w", err)
+	}
+	// Windows doesn't have unix permissions.
+	if runtime.GOOS != "windows" && info.Mode().Perm()&^fileMode != 0 {
+		return nil, fmt.Errorf(
+			"credentials file %s has mode %v; it must be readable only by you (chmod 600)",
+			s.path, info.Mode().Perm())
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("trouble reading credentials; %w", err)
+	}
+	var fd fileData
+	if err = json.Unmarshal(data, &fd); err != nil {
+		return nil, fmt.Errorf("trouble parsing credentials %s; %w", s.path, err)
+	}
+	if fd.Sealed != nil {
+		if s.passphrase == "" {
+			return nil, fmt.Errorf("%w for %s", ErrNeedPassphrase, s.path)
+		}
+		if data, err = fd.Sealed.open(s.passphrase); err != nil {
+			return nil, fmt.Errorf("trouble decrypting credentials %s; %w", s.path, err)
+		}
+		if err = json.Unmarshal(data, &fd.Tokens); err != nil {
+			return nil, fmt.Errorf("trouble parsing credentials %s; %w", s.path, err)
+		}
+	}
+	if fd.Tokens == nil {
+		fd.Tokens = make(map[string]Token)
+	}
+	return fd.Tokens, nil
+}
+
+// save writes the tokens to the file, replacing it atomically so that
+// a crash can't leave it half written.
+func (s *Store) save(tokens map[string]Token) error {
+	var fd fileData
+	if s.passphrase == "" {
+		fd.Tokens = tokens
+	} else {
+		data, err := json.Marshal(tokens)
+		if err != nil {
+			return err
+		}
+		if fd.Sealed, err = seal(s.passphrase, data); err != nil {
+			return fmt.Errorf("trouble encrypting credentials; %w", err)
+		}
+	}
+	data, err := json.MarshalIndent(&fd, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(s.path)
+	if err = os.MkdirAll(dir, dirMode); err != nil {
+		return fmt.Errorf("trouble saving credentials; %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, fileName+".*")
+	if err != nil {
+		return fmt.Errorf("trouble saving credentials; %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	// CreateTemp makes files with fileMode, but be sure.
+	if err = tmp.Chmod(fileMode); err == nil {
+		_, err = tmp.Write(data)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		return fmt.Errorf("trouble saving credentials; %w", err)
+	}
+	return nil
+}
+
+func seal(passphrase string, plain []byte) (*sealed, error) {
+	result := &sealed{
+		Salt:       make([]byte, saltLen),
+		Iterations: kdfIterations,
+	}
+	if _, err := rand.Read(result.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := result.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	result.Nonce = make([]byte, aead.NonceSize())
+	if _, err = rand.Read(result.Nonce); err != nil {
+		return nil, err
+	}
+	result.Data = aead.Seal(nil, result.Nonce, plain, nil)
+	return result, nil
+}
+
+func (sd *sealed) open(passphrase string) ([]byte, error) {
+	aead, err := sd.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := aead.Open(nil, sd.Nonce, sd.Data, nil)
+	if err != nil {
+		return nil, errors.New("wrong passphrase, or the file is damaged")
+	}
+	return plain, nil
+}
+
+func (sd *sealed) aead(passphrase string) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, sd.Salt, sd.Iterations, keyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
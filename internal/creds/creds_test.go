@@ -0,0 +1,135 @@
+package creds
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeTestStore(t *testing.T, passphrase string) *Store {
+	s, err := MakeStore(filepath.Join(t.TempDir(), "snips", fileName), passphrase)
+	assert.NoError(t, err)
+	return s
+}
+
+func TestStore(t *testing.T) {
+	tests := map[string]struct {
+		passphrase string
+	}{
+		"plain":     {},
+		"encrypted": {passphrase: "correct horse"},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			s := makeTestStore(t, tt.passphrase)
+			got, err := s.Get("github.com")
+			assert.NoError(t, err)
+			assert.Nil(t, got)
+
+			assert.NoError(t, s.Put("github.com", Token{Value: "gho_abc", Scopes: "repo,user"}))
+			assert.NoError(t, s.Put("github.acme.com", Token{Value: "gho_xyz"}))
+			got, err = s.Get("github.com")
+			assert.NoError(t, err)
+			if assert.NotNil(t, got) {
+				assert.Equal(t, "gho_abc", got.Value)
+				assert.Equal(t, "repo,user", got.Scopes)
+				assert.WithinDuration(t, time.Now(), got.Saved, time.Minute)
+			}
+			domains, err := s.Domains()
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"github.acme.com", "github.com"}, domains)
+
+			data, err := os.ReadFile(s.Path())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.passphrase == "", bytes.Contains(data, []byte("gho_abc")))
+			if runtime.GOOS != "windows" {
+				info, err := os.Stat(s.Path())
+				assert.NoError(t, err)
+				assert.Equal(t, fileMode, info.Mode().Perm())
+			}
+
+			found, err := s.Forget("github.com")
+			assert.NoError(t, err)
+			assert.True(t, found)
+			found, err = s.Forget("github.com")
+			assert.NoError(t, err)
+			assert.False(t, found)
+			domains, err = s.Domains()
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"github.acme.com"}, domains)
+		})
+	}
+}
+
+func TestStoreWrongPassphrase(t *testing.T) {
+	s := makeTestStore(t, "correct horse")
+	assert.NoError(t, s.Put("github.com", Token{Value: "gho_abc"}))
+
+	_, err := (&Store{path: s.Path()}).Get("github.com")
+	assert.ErrorIs(t, err, ErrNeedPassphrase)
+	_, err = (&Store{path: s.Path(), passphrase: "battery staple"}).Get("github.com")
+	assert.ErrorContains(t, err, "wrong passphrase")
+}
+
+func TestStoreLooseMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no unix permissions")
+	}
+	s := makeTestStore(t, "")
+	assert.NoError(t, s.Put("github.com", Token{Value: "gho_abc"}))
+	assert.NoError(t, os.Chmod(s.Path(), 0o644))
+	_, err := s.Get("github.com")
+	assert.ErrorContains(t, err, "chmod 600")
+}
+
+func TestRunCommand(t *testing.T) {
+	s := makeTestStore(t, "")
+	saved := time.Date(2024, 3, 4, 5, 6, 0, 0, time.Local)
+	assert.NoError(t, s.Put("github.com", Token{Value: "gho_abc", Scopes: "repo,read:org", Saved: saved}))
+	tests := map[string]struct {
+		args   []string
+		want   string
+		errMsg string
+	}{
+		"list": {
+			args: []string{CmdList},
+			want: "DOMAIN      SAVED             SCOPES\ngithub.com  2024-03-04 05:06  repo,read:org\n",
+		},
+		"scopes of default domain": {
+			args: []string{CmdShowScopes},
+			want: "repo,read:org\n",
+		},
+		"scopes of unknown domain": {
+			args:   []string{CmdShowScopes, "github.acme.com"},
+			errMsg: "no token saved for github.acme.com",
+		},
+		"unknown": {
+			args:   []string{"frob"},
+			errMsg: `unknown command "frob"`,
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			var b bytes.Buffer
+			err := s.RunCommand(&b, tt.args, "github.com")
+			if tt.errMsg != "" {
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, b.String())
+		})
+	}
+
+	var b bytes.Buffer
+	assert.NoError(t, s.RunCommand(&b, []string{CmdForget}, "github.com"))
+	assert.Contains(t, b.String(), "https://github.com/settings/applications")
+	b.Reset()
+	assert.NoError(t, s.RunCommand(&b, []string{CmdList}, "github.com"))
+	assert.Equal(t, "No tokens saved in "+s.Path()+"\n", b.String())
+}
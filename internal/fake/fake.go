@@ -1,6 +1,7 @@
 package fake
 
 import (
+	"fmt"
 	"math/rand"
 	"strings"
 	"time"
@@ -12,6 +13,29 @@ func MakeSliceOfFakeUserData() []*types.MyUser {
 	return []*types.MyUser{makeFakeUserData()}
 }
 
+// MakeUser returns a user who created issueCount issues in each of
+// repoCount repos, e.g. to test how reports cope with many items.
+func MakeUser(login string, repoCount, issueCount int) *types.MyUser {
+	groups := make(map[types.RepoId][]types.MyIssue)
+	for i := 0; i < repoCount; i++ {
+		id := types.RepoId{Org: "org", Name: fmt.Sprintf("repo%03d", i)}
+		for j := 0; j < issueCount; j++ {
+			groups[id] = append(groups[id], types.MyIssue{
+				RepoId:  id,
+				Number:  j,
+				Title:   "fix [the] bananas & cheese",
+				HtmlUrl: fmt.Sprintf("https://github.com/org/%s/issues/%d", id.Name, j),
+				Updated: time.Date(2023, 6, 8, 0, 0, 0, 0, time.UTC),
+			})
+		}
+	}
+	return &types.MyUser{
+		Name:          "Bob " + login,
+		Login:         login,
+		IssuesCreated: &types.IssueSet{Domain: "github.com", Groups: groups},
+	}
+}
+
 func makeFakeUserData() *types.MyUser {
 	repos := makeRandomRepoIds(6, 12)
 	return &types.MyUser{
@@ -34,7 +58,28 @@ func makeFakeUserData() *types.MyUser {
 			makeRandomRepoIdGenerator(repos), 3+rand.Intn(8)),
 		Commits: makeCommitMap(
 			makeRandomRepoIdGenerator(repos), 3+rand.Intn(8)),
+		TimeLogged: makeWorklogSet(
+			makeRandomRepoIdGenerator(repos), 1+rand.Intn(3)),
+	}
+}
+
+func makeWorklogSet(repoIdGen *randomRepoIdGenerator, count int) *types.WorklogSet {
+	result := types.WorklogSet{
+		Domain: "jira.acme.com",
+		Groups: make(map[types.RepoId][]types.MyWorklog),
+	}
+	for i := 0; i < count; i++ {
+		issues := makeSliceOfIssues(1 + rand.Intn(5))
+		repoId := repoIdGen.get()
+		for _, issue := range issues {
+			issue.RepoId = *repoId
+			result.Groups[*repoId] = append(result.Groups[*repoId], types.MyWorklog{
+				Issue: issue,
+				Spent: time.Duration(1+rand.Intn(32)) * 15 * time.Minute,
+			})
+		}
 	}
+	return &result
 }
 
 func makeCommitMap(repoIdGen *randomRepoIdGenerator, count int) map[types.RepoId][]*types.MyCommit {
@@ -0,0 +1,410 @@
+// Package mybitbucket finds the pull requests and commits of users
+// on a Bitbucket Server or Data Center instance.
+// https://developer.atlassian.com/server/bitbucket/rest/v906/intro/
+package mybitbucket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monopole/snips/internal/myhttp"
+	"github.com/monopole/snips/internal/pgmargs"
+	"github.com/monopole/snips/internal/progress"
+	"github.com/monopole/snips/internal/types"
+)
+
+const (
+	usersEndpoint           = "rest/api/1.0/users"
+	reposEndpoint           = "rest/api/1.0/repos"
+	projectReposEndpointFmt = "rest/api/1.0/projects/%s/repos"
+	pullRequestsEndpointFmt = "rest/api/1.0/projects/%s/repos/%s/pull-requests"
+	commitsEndpointFmt      = "rest/api/1.0/projects/%s/repos/%s/commits"
+	activitiesEndpointFmt   = "rest/api/1.0/projects/%s/repos/%s/pull-requests/%d/activities"
+
+	// avatarSize is the width in pixels of the avatar asked for.
+	avatarSize = 64
+
+	// LabelAuthored labels the section holding pull requests a user created.
+	LabelAuthored = "Pull Requests Authored"
+	// LabelMerged labels the section holding a user's pull requests that were merged.
+	LabelMerged = "Pull Requests Merged"
+)
+
+type bitbucketBoss struct {
+	// ctx bounds every request, so searches stop when the run is cancelled.
+	ctx      context.Context
+	htCl     *http.Client
+	args     *pgmargs.BitbucketArgs
+	dayRange *types.DayRange
+	types.WarningList
+}
+
+func MakeBitbucketBoss(
+	ctx context.Context, htCl *http.Client, args *pgmargs.BitbucketArgs, dayRange *types.DayRange) *bitbucketBoss {
+	return &bitbucketBoss{
+		ctx:      ctx,
+		htCl:     htCl,
+		args:     args,
+		dayRange: dayRange,
+	}
+}
+
+// account pairs a report user with their bitbucket user.
+type account struct {
+	u  *types.MyUser
+	bu *user
+}
+
+// DoSearch adds each user's bitbucket activity in the configured repos
+// to their report sections: pull requests they created or had merged
+// to sections of their own, pull requests they approved or marked as
+// needing work to PrsReviewed, and commits they authored to Commits.
+// If the boss's context ends first, it returns a types.StoppedError.
+func (bb *bitbucketBoss) DoSearch(users []*types.MyUser) error {
+	// The repos are scanned once for all the users, so all are incomplete if stopped.
+	return types.Stopped(bb.ctx, bb.search(users), users)
+}
+
+func (bb *bitbucketBoss) search(users []*types.MyUser) error {
+	var accounts []account
+	for _, u := range users {
+		bu, err := bb.lookupUser(u)
+		if errors.Is(err, types.ErrUnknownUser) {
+			bb.Warn("%s; skipping bitbucket searches for %s", err.Error(), u.Login)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		u.AddProfile(bu.DisplayName, bu.EmailAddress, bb.avatarUrl(bu))
+		accounts = append(accounts, account{u: u, bu: bu})
+	}
+	if len(accounts) == 0 {
+		return nil
+	}
+	repos, err := bb.findRepos()
+	if err != nil {
+		return err
+	}
+	authored := make([]*types.IssueSet, len(accounts))
+	merged := make([]*types.IssueSet, len(accounts))
+	for i := range accounts {
+		authored[i] = &types.IssueSet{Domain: bb.args.Domain}
+		merged[i] = &types.IssueSet{Domain: bb.args.Domain}
+	}
+	// The repos are scanned once for all the users.
+	progress.Task("bitbucket", fmt.Sprintf("%d users", len(accounts)))
+	for i, r := range repos {
+		progress.Query(fmt.Sprintf("repo %d/%d %s/%s", i+1, len(repos), r.Project.Key, r.Slug))
+		slog.Info("scanning bitbucket repo", "project", r.Project.Key, "repo", r.Slug)
+		if err = bb.scanRepo(r, accounts, authored, merged); err != nil {
+			if bb.ctx.Err() != nil {
+				// Keep what the repos scanned so far found, e.g. for a partial report.
+				break
+			}
+			// One bad repo, e.g. one the token can't read, shouldn't sink the report.
+			bb.Warn("unable to scan bitbucket repo %s, so its activity is missing; %s", repoName(r), err.Error())
+			err = nil
+		}
+	}
+	for i, a := range accounts {
+		for _, iSet := range []*types.IssueSet{authored[i], merged[i], a.u.PrsReviewed} {
+			if iSet != nil {
+				iSet.SortByUpdated()
+			}
+		}
+		a.u.Custom = append(a.u.Custom,
+			types.CustomIssueSet{Label: LabelAuthored, Issues: authored[i]},
+			types.CustomIssueSet{Label: LabelMerged, Issues: merged[i]})
+	}
+	return err
+}
+
+// scanRepo adds the repo's pull requests and commits to the accounts' activity.
+func (bb *bitbucketBoss) scanRepo(r repository, accounts []account, authored, merged []*types.IssueSet) error {
+	prs, err := bb.findPullRequests(r)
+	if err != nil {
+		return err
+	}
+	for i := range prs {
+		pr := &prs[i]
+		reviewed, err := bb.findReviews(r, pr, accounts)
+		if err != nil {
+			return err
+		}
+		for j, a := range accounts {
+			bb.addPullRequest(a, pr, reviewed, authored[j], merged[j])
+		}
+	}
+	commits, err := bb.findCommits(r)
+	if err != nil {
+		return err
+	}
+	for i := range commits {
+		for _, a := range accounts {
+			bb.addCommit(a, r, &commits[i])
+		}
+	}
+	return nil
+}
+
+// lookupUser returns the bitbucket user matching the user's email if
+// known, else their login.
+func (bb *bitbucketBoss) lookupUser(u *types.MyUser) (*user, error) {
+	var filters []string
+	if u.Email != "" {
+		filters = append(filters, u.Email)
+	}
+	filters = append(filters, u.Login)
+	for _, f := range filters {
+		var found *user
+		err := getPages(bb, usersEndpoint, url.Values{
+			"filter":     {f},
+			"avatarSize": {strconv.Itoa(avatarSize)},
+		}, func(values []user) bool {
+			// The filter matches substrings of names and emails.
+			for i := range values {
+				if strings.EqualFold(values[i].EmailAddress, f) ||
+					strings.EqualFold(values[i].Name, f) || strings.EqualFold(values[i].Slug, f) {
+					found = &values[i]
+					return false
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return nil, fmt.Errorf("trouble looking up bitbucket user %q; %w", f, err)
+		}
+		if found != nil {
+			return found, nil
+		}
+	}
+	return nil, types.UnknownUser("bitbucket", u.Login)
+}
+
+// avatarUrl returns the absolute url of the user's avatar, if any.
+func (bb *bitbucketBoss) avatarUrl(bu *user) string {
+	if strings.HasPrefix(bu.AvatarUrl, "/") {
+		return myhttp.Scheme + bb.args.Domain + bu.AvatarUrl
+	}
+	return bu.AvatarUrl
+}
+
+// findRepos returns the repos named in the config, or, if none are
+// named, all the repos visible to the token.
+func (bb *bitbucketBoss) findRepos() ([]repository, error) {
+	var result []repository
+	collect := func(values []repository) bool {
+		for _, r := range values {
+			if !slices.Contains(result, r) {
+				result = append(result, r)
+			}
+		}
+		return true
+	}
+	if len(bb.args.Config.Repos) == 0 {
+		if err := getPages(bb, reposEndpoint, nil, collect); err != nil {
+			return nil, fmt.Errorf("trouble listing bitbucket repos; %w", err)
+		}
+		return result, nil
+	}
+	for _, name := range bb.args.Config.Repos {
+		key, slug, hasSlug := strings.Cut(name, "/")
+		if hasSlug {
+			collect([]repository{{Slug: slug, Project: project{Key: key}}})
+			continue
+		}
+		err := getPages(bb, fmt.Sprintf(projectReposEndpointFmt, url.PathEscape(key)), nil, collect)
+		if err != nil {
+			return nil, fmt.Errorf("trouble listing repos in bitbucket project %s; %w", key, err)
+		}
+	}
+	return result, nil
+}
+
+// findPullRequests returns the repo's pull requests updated in the day range.
+func (bb *bitbucketBoss) findPullRequests(r repository) ([]pullRequest, error) {
+	var result []pullRequest
+	err := getPages(bb, fmt.Sprintf(pullRequestsEndpointFmt, url.PathEscape(r.Project.Key), url.PathEscape(r.Slug)),
+		url.Values{"state": {"ALL"}, "order": {"NEWEST"}},
+		func(values []pullRequest) bool {
+			// NEWEST order is by update date, so stop at the first one updated before the range.
+			for _, pr := range values {
+				when := time.UnixMilli(pr.UpdatedDate)
+				if when.Before(bb.dayRange.StartAsTime()) {
+					return false
+				}
+				if bb.dayRange.Contains(when) {
+					result = append(result, pr)
+				}
+			}
+			return true
+		})
+	if err != nil {
+		return nil, fmt.Errorf("trouble listing pull requests in bitbucket repo %s; %w", repoName(r), err)
+	}
+	return result, nil
+}
+
+// findCommits returns the commits on the repo's default branch authored in the day range.
+func (bb *bitbucketBoss) findCommits(r repository) ([]commit, error) {
+	var result []commit
+	err := getPages(bb, fmt.Sprintf(commitsEndpointFmt, url.PathEscape(r.Project.Key), url.PathEscape(r.Slug)), nil,
+		func(values []commit) bool {
+			// Commits come newest first, but in topological order, so a merged
+			// branch's commits may follow older ones.  Rather than stop at the
+			// first commit made before the range, stop at the first page of them.
+			recent := false
+			for _, c := range values {
+				if !time.UnixMilli(c.CommitterTimestamp).Before(bb.dayRange.StartAsTime()) {
+					recent = true
+				}
+				if bb.dayRange.Contains(time.UnixMilli(c.AuthorTimestamp)) {
+					result = append(result, c)
+				}
+			}
+			return recent
+		})
+	if err != nil {
+		return nil, fmt.Errorf("trouble listing commits in bitbucket repo %s; %w", repoName(r), err)
+	}
+	return result, nil
+}
+
+// findReviews returns when, in the day range, each of the accounts last
+// approved the pull request or marked it as needing work, by bitbucket
+// user slug.  Only reviewers whose status is one of those are looked for.
+func (bb *bitbucketBoss) findReviews(
+	r repository, pr *pullRequest, accounts []account) (map[string]time.Time, error) {
+	var slugs []string
+	for _, p := range pr.Reviewers {
+		if p.Status != statusApproved && p.Status != statusNeedsWork {
+			continue
+		}
+		if slices.ContainsFunc(accounts, func(a account) bool { return a.bu.Slug == p.User.Slug }) {
+			slugs = append(slugs, p.User.Slug)
+		}
+	}
+	if len(slugs) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]time.Time)
+	err := getPages(bb,
+		fmt.Sprintf(activitiesEndpointFmt, url.PathEscape(r.Project.Key), url.PathEscape(r.Slug), pr.Id), nil,
+		func(values []activity) bool {
+			// Activities come newest first, so stop at the first one before the range.
+			for _, act := range values {
+				when := time.UnixMilli(act.CreatedDate)
+				if when.Before(bb.dayRange.StartAsTime()) {
+					return false
+				}
+				if act.Action != actionApproved && act.Action != actionReviewed ||
+					!slices.Contains(slugs, act.User.Slug) || !bb.dayRange.Contains(when) {
+					continue
+				}
+				if _, ok := result[act.User.Slug]; !ok {
+					result[act.User.Slug] = when
+				}
+			}
+			return true
+		})
+	if err != nil {
+		return nil, fmt.Errorf("trouble listing activities of bitbucket pull request %s#%d; %w",
+			repoName(r), pr.Id, err)
+	}
+	return result, nil
+}
+
+// addPullRequest adds the pull request to the user's sections it belongs in,
+// if any.  reviewed holds when reviewers reviewed it in the day range, by slug.
+func (bb *bitbucketBoss) addPullRequest(
+	a account, pr *pullRequest, reviewed map[string]time.Time, authored, merged *types.IssueSet) {
+	issue := bb.convertPullRequest(pr)
+	if a.bu.Slug == pr.Author.User.Slug {
+		if bb.dayRange.Contains(time.UnixMilli(pr.CreatedDate)) {
+			authored.Add(issue.RepoId, issue)
+		}
+		if pr.State == stateMerged && bb.dayRange.Contains(time.UnixMilli(pr.ClosedDate)) {
+			merged.Add(issue.RepoId, issue)
+		}
+		return
+	}
+	for _, p := range pr.Reviewers {
+		if p.User.Slug != a.bu.Slug {
+			continue
+		}
+		if _, ok := reviewed[p.User.Slug]; !ok {
+			return
+		}
+		switch p.Status {
+		case statusApproved:
+			issue.Votes = []string{"Approved"}
+		case statusNeedsWork:
+			issue.Votes = []string{"Needs work"}
+		default:
+			return
+		}
+		if a.u.PrsReviewed == nil {
+			a.u.PrsReviewed = &types.IssueSet{Domain: bb.args.Domain}
+		}
+		a.u.PrsReviewed.Add(issue.RepoId, issue)
+		return
+	}
+}
+
+// addCommit adds the commit to the user's commits if they authored it.
+func (bb *bitbucketBoss) addCommit(a account, r repository, c *commit) {
+	if c.Author.Slug != a.bu.Slug &&
+		(c.Author.EmailAddress == "" || !strings.EqualFold(c.Author.EmailAddress, a.bu.EmailAddress)) {
+		return
+	}
+	rid := bb.repoId(r)
+	if a.u.Commits == nil {
+		a.u.Commits = make(map[types.RepoId][]*types.MyCommit)
+	}
+	a.u.Commits[rid] = append(a.u.Commits[rid], &types.MyCommit{
+		RepoId:           rid,
+		Sha:              c.Id,
+		Url:              bb.repoUrl(r) + "/commits/" + c.Id,
+		MessageFirstLine: types.FirstLine(c.Message),
+		Committed:        time.UnixMilli(c.AuthorTimestamp),
+		Author:           a.bu.Slug,
+	})
+}
+
+func (bb *bitbucketBoss) convertPullRequest(pr *pullRequest) types.MyIssue {
+	r := pr.ToRef.Repository
+	htmlUrl := bb.repoUrl(r) + "/pull-requests/" + strconv.Itoa(pr.Id)
+	if len(pr.Links.Self) > 0 {
+		htmlUrl = pr.Links.Self[0].Href
+	}
+	return types.MyIssue{
+		RepoId:  bb.repoId(r),
+		Number:  pr.Id,
+		Title:   pr.Title,
+		HtmlUrl: htmlUrl,
+		Updated: time.UnixMilli(pr.UpdatedDate),
+		State:   strings.ToLower(pr.State),
+	}
+}
+
+func (bb *bitbucketBoss) repoId(r repository) types.RepoId {
+	return types.RepoId{Org: bb.args.Domain, Name: repoName(r), Host: types.HostBitbucket}
+}
+
+// repoUrl returns the location of the repo in bitbucket's UI.
+func (bb *bitbucketBoss) repoUrl(r repository) string {
+	return myhttp.Scheme + bb.args.Domain + "/projects/" + r.Project.Key + "/repos/" + r.Slug
+}
+
+func repoName(r repository) string {
+	return r.Project.Key + "/" + r.Slug
+}
@@ -0,0 +1,202 @@
+package mybitbucket
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/monopole/snips/internal/config"
+	"github.com/monopole/snips/internal/pgmargs"
+	"github.com/monopole/snips/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBitbucket serves the canned responses in testdata,
+// expecting the given bearer token.
+type fakeBitbucket struct {
+	t     *testing.T
+	token string
+	// requests holds the path and start parameter of each request received.
+	requests []string
+	// cancel, if not nil, is called on a request for the runbooks repo's pull requests.
+	cancel context.CancelFunc
+	// fail, if not empty, is a path answered with an internal server error.
+	fail string
+}
+
+func (fb *fakeBitbucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+fb.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	q := r.URL.Query()
+	start := q.Get("start")
+	fb.requests = append(fb.requests, r.URL.Path+"?start="+start)
+	if fb.cancel != nil && strings.HasSuffix(r.URL.Path, "/runbooks/pull-requests") {
+		fb.cancel()
+	}
+	if fb.fail != "" && r.URL.Path == fb.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/pull-requests") {
+		assert.Equal(fb.t, "ALL", q.Get("state"))
+		assert.Equal(fb.t, "NEWEST", q.Get("order"))
+	}
+	var file string
+	switch strings.TrimPrefix(r.URL.Path, "/rest/api/1.0/") {
+	case "users":
+		file = "empty.json"
+		if q.Get("filter") == "bob" {
+			file = "users-bob.json"
+		}
+	case "projects/OPS/repos":
+		file = "repos-OPS.json"
+	case "projects/PLAT/repos/build/pull-requests":
+		file = "prs-PLAT-build-" + start + ".json"
+	case "projects/OPS/repos/runbooks/pull-requests":
+		file = "prs-OPS-runbooks-" + start + ".json"
+	case "projects/PLAT/repos/build/commits":
+		file = "commits-PLAT-build-" + start + ".json"
+	case "projects/OPS/repos/runbooks/commits":
+		file = "empty.json"
+	case "projects/PLAT/repos/build/pull-requests/8/activities":
+		file = "activities-PLAT-build-8.json"
+	case "projects/PLAT/repos/build/pull-requests/9/activities":
+		file = "activities-PLAT-build-9.json"
+	case "projects/OPS/repos/runbooks/pull-requests/4/activities":
+		file = "activities-OPS-runbooks-4.json"
+	}
+	data, err := os.ReadFile(filepath.Join("testdata", file))
+	if file == "" || err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+func makeTestBoss(t *testing.T, fb *fakeBitbucket, token string) (*bitbucketBoss, string) {
+	srv := httptest.NewTLSServer(fb)
+	t.Cleanup(srv.Close)
+	dr, err := types.MakeDayRange("2023-06-01", "2023-06-30", 0)
+	assert.NoError(t, err)
+	domain := strings.TrimPrefix(srv.URL, "https://")
+	return MakeBitbucketBoss(context.Background(), srv.Client(), &pgmargs.BitbucketArgs{
+		ServiceArgs: pgmargs.ServiceArgs{Domain: domain, Token: token},
+		Config:      config.Bitbucket{Repos: []string{"PLAT/build", "OPS"}},
+	}, dr), domain
+}
+
+func Test_DoSearch(t *testing.T) {
+	fb := &fakeBitbucket{t: t, token: "sekret"}
+	bb, domain := makeTestBoss(t, fb, "sekret")
+	u := &types.MyUser{Login: "bob", Name: "bob", Email: "bob@acme.com"}
+	ghost := &types.MyUser{Login: "ghost"}
+	assert.NoError(t, bb.DoSearch([]*types.MyUser{u, ghost}))
+
+	assert.Equal(t, "Bob Bobface", u.Name)
+	assert.Equal(t, "https://"+domain+"/users/bob/avatar.png?s=64", u.AvatarUrl)
+	assert.Equal(t, []string{`unknown bitbucket user "ghost"; skipping bitbucket searches for ghost`}, bb.Warnings())
+	assert.Contains(t, fb.requests, "/rest/api/1.0/projects/PLAT/repos/build/pull-requests?start=2")
+	// Paging stops at the first pull request or commit older than the range.
+	assert.NotContains(t, fb.requests, "/rest/api/1.0/projects/PLAT/repos/build/pull-requests?start=4")
+	// Commits are listed in topological order, so paging stops
+	// only at a page of commits all older than the range.
+	assert.Contains(t, fb.requests, "/rest/api/1.0/projects/PLAT/repos/build/commits?start=6")
+	assert.NotContains(t, fb.requests, "/rest/api/1.0/projects/PLAT/repos/build/commits?start=8")
+	// Activities are only asked for where the users' reviews count.
+	assert.Contains(t, fb.requests, "/rest/api/1.0/projects/OPS/repos/runbooks/pull-requests/4/activities?start=0")
+	assert.NotContains(t, fb.requests, "/rest/api/1.0/projects/PLAT/repos/build/pull-requests/7/activities?start=0")
+
+	build := types.RepoId{Org: domain, Name: "PLAT/build", Host: types.HostBitbucket}
+	if assert.Len(t, u.Custom, 2) {
+		assert.Equal(t, LabelAuthored, u.Custom[0].Label)
+		assert.Equal(t, 1, u.Custom[0].Issues.Count())
+		assert.Equal(t, 7, u.Custom[0].Issues.Groups[build][0].Number)
+		assert.Equal(t, LabelMerged, u.Custom[1].Label)
+		if assert.Equal(t, 1, u.Custom[1].Issues.Count()) {
+			merged := u.Custom[1].Issues.Groups[build][0]
+			assert.Equal(t, "merged", merged.State)
+			assert.Equal(t, "https://bitbucket.acme.com/projects/PLAT/repos/build/pull-requests/7", merged.HtmlUrl)
+		}
+	}
+	// Not OPS/runbooks#4, approved before the range, though updated in it.
+	if assert.Equal(t, 2, u.PrsReviewed.Count()) {
+		reviewed := u.PrsReviewed.Groups[build]
+		assert.Equal(t, 8, reviewed[0].Number)
+		assert.Equal(t, []string{"Approved"}, reviewed[0].Votes)
+		assert.Equal(t, 9, reviewed[1].Number)
+		assert.Equal(t, []string{"Needs work"}, reviewed[1].Votes)
+		assert.Equal(t, "https://"+domain+"/projects/PLAT/repos/build/pull-requests/9", reviewed[1].HtmlUrl)
+	}
+	if assert.Len(t, u.Commits[build], 3) {
+		c := u.Commits[build][0]
+		assert.Equal(t, "Cache the toolchain", c.MessageFirstLine)
+		assert.Equal(t, "https://"+domain+"/projects/PLAT/repos/build/commits/"+c.Sha, c.Url)
+		// Matched by email.
+		assert.Equal(t, "Fix the cache key", u.Commits[build][1].MessageFirstLine)
+		// Listed after an older commit.
+		assert.Equal(t, "Warm the cache, on a merged branch", u.Commits[build][2].MessageFirstLine)
+	}
+	assert.Len(t, u.Commits, 1)
+}
+
+func Test_DoSearchBadToken(t *testing.T) {
+	bb, _ := makeTestBoss(t, &fakeBitbucket{t: t, token: "sekret"}, "wrong")
+	err := bb.DoSearch([]*types.MyUser{{Login: "bob"}})
+	assert.ErrorContains(t, err, "status code 401")
+}
+
+func Test_DoSearchNobodyKnown(t *testing.T) {
+	fb := &fakeBitbucket{t: t, token: "sekret"}
+	bb, _ := makeTestBoss(t, fb, "sekret")
+	assert.NoError(t, bb.DoSearch([]*types.MyUser{{Login: "ghost"}}))
+	// No repos are searched.
+	assert.Len(t, fb.requests, 1)
+}
+
+func Test_DoSearchCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fb := &fakeBitbucket{t: t, token: "sekret", cancel: cancel}
+	bb, domain := makeTestBoss(t, fb, "sekret")
+	bb.ctx = ctx
+	u := &types.MyUser{Login: "bob"}
+	err := bb.DoSearch([]*types.MyUser{u})
+	assert.ErrorIs(t, err, context.Canceled)
+	var sErr *types.StoppedError
+	if assert.ErrorAs(t, err, &sErr) {
+		assert.Equal(t, []string{"bob"}, sErr.Logins)
+	}
+	// What the first repo held is kept.
+	build := types.RepoId{Org: domain, Name: "PLAT/build", Host: types.HostBitbucket}
+	if assert.Len(t, u.Custom, 2) {
+		assert.Equal(t, 7, u.Custom[0].Issues.Groups[build][0].Number)
+	}
+	assert.Len(t, u.Commits[build], 3)
+	assert.NotContains(t, fb.requests, "/rest/api/1.0/projects/OPS/repos/runbooks/commits?start=")
+}
+
+func Test_DoSearchBadRepo(t *testing.T) {
+	fb := &fakeBitbucket{t: t, token: "sekret", fail: "/rest/api/1.0/projects/OPS/repos/runbooks/pull-requests"}
+	bb, domain := makeTestBoss(t, fb, "sekret")
+	// The bad repo comes first, so the scan must go on past it.
+	bb.args.Config.Repos = []string{"OPS/runbooks", "PLAT/build"}
+	u := &types.MyUser{Login: "bob"}
+	assert.NoError(t, bb.DoSearch([]*types.MyUser{u}))
+	if assert.Len(t, bb.Warnings(), 1) {
+		assert.Contains(t, bb.Warnings()[0], "unable to scan bitbucket repo OPS/runbooks")
+		assert.Contains(t, bb.Warnings()[0], "status code 500")
+	}
+	build := types.RepoId{Org: domain, Name: "PLAT/build", Host: types.HostBitbucket}
+	if assert.Len(t, u.Custom, 2) {
+		assert.Equal(t, 7, u.Custom[0].Issues.Groups[build][0].Number)
+	}
+	assert.Equal(t, 2, u.PrsReviewed.Count())
+	assert.Len(t, u.Commits[build], 3)
+}
@@ -0,0 +1,84 @@
+package mybitbucket
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/monopole/snips/internal/myhttp"
+	"github.com/monopole/snips/internal/progress"
+)
+
+const (
+	// pageSize is the number of values asked for per request.
+	pageSize = 100
+)
+
+// makeUrl returns the location of the given endpoint on the bitbucket domain.
+func (bb *bitbucketBoss) makeUrl(endpoint string, query url.Values) (*url.URL, error) {
+	loc, err := url.Parse(myhttp.Scheme + bb.args.Domain + "/" + endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if query != nil {
+		loc.RawQuery = query.Encode()
+	}
+	return loc, nil
+}
+
+// doBitbucketRequest GETs the location, and unmarshals the JSON response into resp.
+func (bb *bitbucketBoss) doBitbucketRequest(loc *url.URL, resp any) error {
+	req, err := http.NewRequestWithContext(bb.ctx, http.MethodGet, loc.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(myhttp.HeaderAccept, myhttp.ContentTypeJson)
+	if bb.args.Token != "" {
+		req.Header.Set(myhttp.HeaderAAuthorization, "Bearer "+bb.args.Token)
+	}
+	ans, err := bb.htCl.Do(req)
+	if err != nil {
+		return err
+	}
+	progress.Page()
+	defer ans.Body.Close()
+	if ans.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code %d from GET %s: %s",
+			ans.StatusCode, loc.Path, myhttp.ErrBody(ans.Body))
+	}
+	data, err := io.ReadAll(ans.Body)
+	if err != nil {
+		return fmt.Errorf("ReadAll failure: %w", err)
+	}
+	if err = json.Unmarshal(data, resp); err != nil {
+		return fmt.Errorf("trouble unmarshaling data from response; %w", err)
+	}
+	return nil
+}
+
+// getPages GETs the pages of values at the endpoint, handing each page to
+// f until f returns false or there are no more pages.
+func getPages[T any](bb *bitbucketBoss, endpoint string, query url.Values, f func([]T) bool) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("limit", strconv.Itoa(pageSize))
+	for start := 0; ; {
+		query.Set("start", strconv.Itoa(start))
+		loc, err := bb.makeUrl(endpoint, query)
+		if err != nil {
+			return err
+		}
+		var resp page[T]
+		if err = bb.doBitbucketRequest(loc, &resp); err != nil {
+			return err
+		}
+		if !f(resp.Values) || resp.IsLastPage || len(resp.Values) == 0 {
+			return nil
+		}
+		start = resp.NextPageStart
+	}
+}
@@ -0,0 +1,124 @@
+package mybitbucket
+
+// page is one page of a paged response.
+// https://developer.atlassian.com/server/bitbucket/rest/v906/intro/#paged-apis
+type page[T any] struct {
+	Size          int  `json:"size"`
+	Limit         int  `json:"limit"`
+	IsLastPage    bool `json:"isLastPage"`
+	Start         int  `json:"start"`
+	NextPageStart int  `json:"nextPageStart"`
+	Values        []T  `json:"values"`
+}
+
+type link struct {
+	Href string `json:"href"`
+}
+
+type links struct {
+	Self []link `json:"self,omitempty"`
+}
+
+// https://developer.atlassian.com/server/bitbucket/rest/v906/api-group-system-maintenance/#api-api-latest-users-get
+type user struct {
+	Id           int    `json:"id"`
+	Name         string `json:"name"`
+	Slug         string `json:"slug"`
+	EmailAddress string `json:"emailAddress,omitempty"`
+	DisplayName  string `json:"displayName,omitempty"`
+	Active       bool   `json:"active"`
+	// Type is "NORMAL" for people, "SERVICE" for bots.
+	Type string `json:"type,omitempty"`
+	// AvatarUrl is present if an avatarSize was asked for,
+	// and may be relative to the domain.
+	AvatarUrl string `json:"avatarUrl,omitempty"`
+}
+
+type project struct {
+	Key string `json:"key"`
+}
+
+type repository struct {
+	Slug    string  `json:"slug"`
+	Project project `json:"project"`
+}
+
+type ref struct {
+	Repository repository `json:"repository"`
+}
+
+// participant is someone involved in a pull request.
+type participant struct {
+	User user `json:"user"`
+	// Role is one of "AUTHOR", "REVIEWER" or "PARTICIPANT".
+	Role     string `json:"role"`
+	Approved bool   `json:"approved"`
+	// Status is one of the status* values.
+	Status string `json:"status"`
+}
+
+// Reviewer statuses.
+const (
+	statusApproved   = "APPROVED"
+	statusNeedsWork  = "NEEDS_WORK"
+	statusUnapproved = "UNAPPROVED"
+)
+
+// Pull request states.
+const (
+	stateMerged = "MERGED"
+)
+
+// https://developer.atlassian.com/server/bitbucket/rest/v906/api-group-pull-requests/#api-api-latest-projects-projectkey-repos-repositoryslug-pull-requests-get
+type pullRequest struct {
+	Id          int    `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	// State is one of "OPEN", "MERGED" or "DECLINED".
+	State string `json:"state"`
+	// Dates are milliseconds since the epoch.
+	CreatedDate  int64         `json:"createdDate"`
+	UpdatedDate  int64         `json:"updatedDate"`
+	ClosedDate   int64         `json:"closedDate,omitempty"`
+	ToRef        ref           `json:"toRef"`
+	Author       participant   `json:"author"`
+	Reviewers    []participant `json:"reviewers,omitempty"`
+	Participants []participant `json:"participants,omitempty"`
+	Links        links         `json:"links"`
+}
+
+// Pull request activity actions, among others, e.g. "COMMENTED".
+const (
+	actionApproved = "APPROVED"
+	// actionReviewed is marking a pull request as needing work.
+	actionReviewed = "REVIEWED"
+)
+
+// https://developer.atlassian.com/server/bitbucket/rest/v906/api-group-pull-requests/#api-api-latest-projects-projectkey-repos-repositoryslug-pull-requests-pullrequestid-activities-get
+type activity struct {
+	Id int `json:"id"`
+	// CreatedDate is milliseconds since the epoch.
+	CreatedDate int64 `json:"createdDate"`
+	User        user  `json:"user"`
+	// Action is one of the action* values, among others.
+	Action string `json:"action"`
+}
+
+// commitAuthor is the git identity of a commit's author, with the
+// name and slug of the matching bitbucket user, if any.
+type commitAuthor struct {
+	Name         string `json:"name"`
+	EmailAddress string `json:"emailAddress,omitempty"`
+	Slug         string `json:"slug,omitempty"`
+}
+
+// https://developer.atlassian.com/server/bitbucket/rest/v906/api-group-repository/#api-api-latest-projects-projectkey-repos-repositoryslug-commits-get
+type commit struct {
+	Id        string       `json:"id"`
+	DisplayId string       `json:"displayId"`
+	Author    commitAuthor `json:"author"`
+	// Timestamps are milliseconds since the epoch.
+	AuthorTimestamp    int64  `json:"authorTimestamp"`
+	CommitterTimestamp int64  `json:"committerTimestamp"`
+	Message            string `json:"message"`
+}
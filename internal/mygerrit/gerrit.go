@@ -0,0 +1,351 @@
+// Package mygerrit finds the code review activity of users on a Gerrit instance.
+// https://gerrit-review.googlesource.com/Documentation/rest-api.html
+package mygerrit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monopole/snips/internal/myhttp"
+	"github.com/monopole/snips/internal/pgmargs"
+	"github.com/monopole/snips/internal/progress"
+	"github.com/monopole/snips/internal/types"
+)
+
+const (
+	// https://gerrit-review.googlesource.com/Documentation/rest-api-accounts.html#query-account
+	accountsEndpoint = "accounts/"
+	// https://gerrit-review.googlesource.com/Documentation/rest-api-changes.html#list-changes
+	changesEndpoint = "changes/"
+
+	// pageSize is the number of changes asked for per request.
+	pageSize = 100
+
+	// dateFormatGerrit is the format of timestamps, which are in UTC.
+	dateFormatGerrit = "2006-01-02 15:04:05.000000000"
+
+	// autogeneratedTag starts the tag of messages made by tools, e.g. CI bots.
+	autogeneratedTag = "autogenerated:"
+
+	// LabelOwned labels the section holding changes a user owns but hasn't merged.
+	LabelOwned = "Changes Owned"
+)
+
+// changeOptions ask for the votes and messages of changes, and the sha of their latest patch set.
+// https://gerrit-review.googlesource.com/Documentation/rest-api-changes.html#query-options
+var changeOptions = []string{"DETAILED_LABELS", "DETAILED_ACCOUNTS", "MESSAGES", "CURRENT_REVISION"}
+
+type gerritBoss struct {
+	// ctx bounds every request, so searches stop when the run is cancelled.
+	ctx      context.Context
+	htCl     *http.Client
+	args     *pgmargs.GerritArgs
+	dayRange *types.DayRange
+	types.WarningList
+}
+
+func MakeGerritBoss(
+	ctx context.Context, htCl *http.Client, args *pgmargs.GerritArgs, dayRange *types.DayRange) *gerritBoss {
+	return &gerritBoss{
+		ctx:      ctx,
+		htCl:     htCl,
+		args:     args,
+		dayRange: dayRange,
+	}
+}
+
+// DoSearch adds each user's gerrit changes to their report sections:
+// merged changes to Commits, changes voted on to PrsReviewed, changes
+// only commented on to IssuesCommented, and other changes they own
+// to a section of their own.  If the boss's context ends first,
+// it returns a types.StoppedError.
+func (gb *gerritBoss) DoSearch(users []*types.MyUser) error {
+	for i, u := range users {
+		if err := gb.collectUser(u); err != nil {
+			return types.Stopped(gb.ctx, err, users[i:])
+		}
+	}
+	return nil
+}
+
+func (gb *gerritBoss) collectUser(u *types.MyUser) error {
+	progress.Task("gerrit", u.Login)
+	slog.Info("searching gerrit", "user", u.Login)
+	acct, err := gb.lookupAccount(u)
+	if errors.Is(err, types.ErrUnknownUser) {
+		gb.Warn("%s; skipping gerrit searches for %s", err.Error(), u.Login)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	u.AddProfile(acct.Name, acct.Email, avatarUrl(acct))
+	if err = gb.findMerged(u, acct); err != nil {
+		return err
+	}
+	if err = gb.findOwned(u, acct); err != nil {
+		return err
+	}
+	return gb.findReviewed(u, acct)
+}
+
+// lookupAccount returns the gerrit account of the user, searching
+// by email if known, then by login.
+func (gb *gerritBoss) lookupAccount(u *types.MyUser) (*accountInfo, error) {
+	var queries []string
+	if u.Email != "" {
+		queries = append(queries, "email:"+u.Email)
+	}
+	queries = append(queries, "username:"+u.Login)
+	for _, q := range queries {
+		loc, err := gb.makeUrl(accountsEndpoint, url.Values{"q": {q}, "o": {"DETAILS"}})
+		if err != nil {
+			return nil, err
+		}
+		var found []accountInfo
+		if err = gb.doGerritRequest(loc, &found); err != nil {
+			return nil, fmt.Errorf("trouble looking up gerrit user %q; %w", q, err)
+		}
+		if len(found) == 1 {
+			return &found[0], nil
+		}
+	}
+	return nil, types.UnknownUser("gerrit", u.Login)
+}
+
+// avatarUrl returns the url of the account's largest avatar, if any.
+func avatarUrl(acct *accountInfo) string {
+	if len(acct.Avatars) == 0 {
+		return ""
+	}
+	return acct.Avatars[len(acct.Avatars)-1].Url
+}
+
+// makeQuery returns the gerrit search for the given terms, restricted to
+// changes touched in the day range.  Dates without times mean midnight,
+// so the end is the day after the range.
+// https://gerrit-review.googlesource.com/Documentation/user-search.html
+func makeQuery(dateQualifier string, terms string, dayRange *types.DayRange) string {
+	before, after := "before", "after"
+	if dateQualifier != "" {
+		before, after = dateQualifier+"before", dateQualifier+"after"
+	}
+	return fmt.Sprintf(
+		"%s %s:%q %s:%q",
+		terms,
+		after,
+		dayRange.StartAsTime().Format(types.DayFormatGitHub),
+		before,
+		dayRange.EndAsTime().AddDate(0, 0, 1).Format(types.DayFormatGitHub),
+	)
+}
+
+// findMerged adds the user's changes merged in the day range to their commits.
+func (gb *gerritBoss) findMerged(u *types.MyUser, acct *accountInfo) error {
+	changes, err := gb.queryChanges(makeQuery(
+		"merged", fmt.Sprintf("owner:%d status:merged", acct.AccountId), gb.dayRange))
+	if err != nil {
+		return err
+	}
+	for i := range changes {
+		c := &changes[i]
+		issue, err := gb.convertChange(c)
+		if err != nil {
+			return err
+		}
+		when, err := parseTime(c.Submitted)
+		if err != nil {
+			return err
+		}
+		if u.Commits == nil {
+			u.Commits = make(map[types.RepoId][]*types.MyCommit)
+		}
+		u.Commits[issue.RepoId] = append(u.Commits[issue.RepoId], &types.MyCommit{
+			RepoId:           issue.RepoId,
+			Sha:              c.CurrentRevision,
+			Url:              issue.HtmlUrl,
+			MessageFirstLine: c.Subject,
+			Committed:        when,
+			Author:           c.Owner.Username,
+			Pr:               &issue,
+		})
+	}
+	return nil
+}
+
+// findOwned finds the user's changes, other than merged ones, updated in the day range.
+func (gb *gerritBoss) findOwned(u *types.MyUser, acct *accountInfo) error {
+	changes, err := gb.queryChanges(makeQuery(
+		"", fmt.Sprintf("owner:%d -status:merged", acct.AccountId), gb.dayRange))
+	if err != nil {
+		return err
+	}
+	iSet := &types.IssueSet{Domain: gb.args.Domain}
+	for i := range changes {
+		issue, err := gb.convertChange(&changes[i])
+		if err != nil {
+			return err
+		}
+		iSet.Add(issue.RepoId, issue)
+	}
+	iSet.SortByUpdated()
+	u.Custom = append(u.Custom, types.CustomIssueSet{Label: LabelOwned, Issues: iSet})
+	return nil
+}
+
+// findReviewed finds the changes of others that the user voted on or
+// commented on in the day range.
+func (gb *gerritBoss) findReviewed(u *types.MyUser, acct *accountInfo) error {
+	changes, err := gb.queryChanges(makeQuery(
+		"", fmt.Sprintf("reviewedby:%d -owner:%d", acct.AccountId, acct.AccountId), gb.dayRange))
+	if err != nil {
+		return err
+	}
+	for i := range changes {
+		c := &changes[i]
+		issue, err := gb.convertChange(c)
+		if err != nil {
+			return err
+		}
+		issue.Votes = gb.votes(c, acct)
+		issue.Comments = gb.comments(c, acct)
+		switch {
+		case len(issue.Votes) > 0:
+			u.PrsReviewed = gb.addTo(u.PrsReviewed, issue)
+		case len(issue.Comments) > 0:
+			u.IssuesCommented = gb.addTo(u.IssuesCommented, issue)
+		}
+	}
+	for _, iSet := range []*types.IssueSet{u.PrsReviewed, u.IssuesCommented} {
+		if iSet != nil {
+			iSet.SortByUpdated()
+		}
+	}
+	return nil
+}
+
+// addTo adds the issue to the set, making the set if need be.
+func (gb *gerritBoss) addTo(iSet *types.IssueSet, issue types.MyIssue) *types.IssueSet {
+	if iSet == nil {
+		iSet = &types.IssueSet{Domain: gb.args.Domain}
+	}
+	iSet.Add(issue.RepoId, issue)
+	return iSet
+}
+
+// votes returns the user's non-zero votes cast in the day range, e.g. "Code-Review+2".
+func (gb *gerritBoss) votes(c *changeInfo, acct *accountInfo) []string {
+	var result []string
+	for label, info := range c.Labels {
+		for _, a := range info.All {
+			if a.AccountId != acct.AccountId || a.Value == 0 {
+				continue
+			}
+			// Votes carried over from earlier patch sets may lack a date.
+			if a.Date != "" && !gb.inDayRange(a.Date) {
+				continue
+			}
+			result = append(result, fmt.Sprintf("%s%+d", label, a.Value))
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// comments returns the user's messages on the change in the day range.
+func (gb *gerritBoss) comments(c *changeInfo, acct *accountInfo) []types.MyComment {
+	var result []types.MyComment
+	for _, m := range c.Messages {
+		if m.Author.AccountId != acct.AccountId || strings.HasPrefix(m.Tag, autogeneratedTag) {
+			continue
+		}
+		when, err := parseTime(m.Date)
+		if err != nil || !gb.dayRange.Contains(when) {
+			continue
+		}
+		result = append(result, types.MyComment{
+			Url:       gb.changeUrl(c) + "#message-" + url.PathEscape(m.Id),
+			When:      when,
+			FirstLine: firstLine(m.Message),
+		})
+	}
+	return result
+}
+
+// queryChanges returns all the changes matching the query, page by page.
+func (gb *gerritBoss) queryChanges(q string) ([]changeInfo, error) {
+	progress.Query(q)
+	slog.Debug("gerrit query", "query", q)
+	var result []changeInfo
+	for start := 0; ; {
+		loc, err := gb.makeUrl(changesEndpoint, url.Values{
+			"q": {q},
+			"o": changeOptions,
+			"n": {strconv.Itoa(pageSize)},
+			"S": {strconv.Itoa(start)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		var page []changeInfo
+		if err = gb.doGerritRequest(loc, &page); err != nil {
+			return nil, fmt.Errorf("trouble with gerrit query [%s]; %w", q, err)
+		}
+		result = append(result, page...)
+		if len(page) == 0 || !page[len(page)-1].MoreChanges {
+			return result, nil
+		}
+		start += len(page)
+	}
+}
+
+func (gb *gerritBoss) convertChange(c *changeInfo) (types.MyIssue, error) {
+	updated, err := parseTime(c.Updated)
+	if err != nil {
+		return types.MyIssue{}, err
+	}
+	return types.MyIssue{
+		RepoId:  types.RepoId{Org: gb.args.Domain, Name: c.Project, Host: types.HostGerrit},
+		Number:  c.Number,
+		Title:   c.Subject,
+		HtmlUrl: gb.changeUrl(c),
+		Updated: updated,
+		State:   strings.ToLower(c.Status),
+	}, nil
+}
+
+// changeUrl returns the location of the change in gerrit's UI.
+func (gb *gerritBoss) changeUrl(c *changeInfo) string {
+	return myhttp.Scheme + gb.args.Domain + "/c/" + c.Project + "/+/" + strconv.Itoa(c.Number)
+}
+
+func parseTime(raw string) (time.Time, error) {
+	t, err := time.Parse(dateFormatGerrit, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("trouble parsing gerrit time %q; %w", raw, err)
+	}
+	return t, nil
+}
+
+// inDayRange is true if the given gerrit timestamp falls in the day range.
+func (gb *gerritBoss) inDayRange(raw string) bool {
+	t, err := parseTime(raw)
+	return err == nil && gb.dayRange.Contains(t)
+}
+
+// patchSetPrefix starts messages about a patch set, e.g. "Patch Set 2: Code-Review+1".
+var patchSetPrefix = regexp.MustCompile(`(?m)^Patch Set \d+:[ \t]*`)
+
+// firstLine returns the first non-blank line of a message, minus any patch set prefix.
+func firstLine(msg string) string {
+	return types.FirstLine(patchSetPrefix.ReplaceAllString(msg, ""))
+}
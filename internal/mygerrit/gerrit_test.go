@@ -0,0 +1,250 @@
+package mygerrit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/monopole/snips/internal/pgmargs"
+	"github.com/monopole/snips/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+const bobId = 1000096
+
+var bob = accountInfo{AccountId: bobId, Name: "Bob Bobface", Email: "bob@acme.com", Username: "bob"}
+
+// fakeGerrit stands in for a Gerrit instance, expecting
+// bob's HTTP password if authenticated is true.
+type fakeGerrit struct {
+	t             *testing.T
+	authenticated bool
+	// queries holds the q parameter of each change query received.
+	queries []string
+	// cancel, if not nil, is called on looking up carol, whose lookup
+	// then waits for the client to give up.
+	cancel context.CancelFunc
+}
+
+func (fg *fakeGerrit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	if fg.authenticated {
+		name, pass, ok := r.BasicAuth()
+		if !ok || name != "bob" || pass != "sekret" || !strings.HasPrefix(path, "/a/") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		path = strings.TrimPrefix(path, "/a")
+	}
+	q := r.URL.Query().Get("q")
+	if fg.cancel != nil && q == "username:carol" {
+		fg.cancel()
+		<-r.Context().Done()
+		return
+	}
+	switch path {
+	case "/accounts/":
+		if q == "username:bob" {
+			fg.write(w, []accountInfo{bob})
+			return
+		}
+		fg.write(w, []accountInfo{})
+	case "/changes/":
+		assert.ElementsMatch(fg.t, changeOptions, r.URL.Query()["o"])
+		fg.queries = append(fg.queries, q)
+		fg.write(w, fg.changes(q, r.URL.Query().Get("S")))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// write sends the value as gerrit does, behind the XSSI prefix.
+func (fg *fakeGerrit) write(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(")]}'\n"))
+	assert.NoError(fg.t, json.NewEncoder(w).Encode(v))
+}
+
+func makeChange(num int, project string, owner accountInfo) changeInfo {
+	return changeInfo{
+		Project: project,
+		Subject: "change " + strings.Repeat("x", num%3+1),
+		Status:  "NEW",
+		Updated: "2023-06-08 13:47:00.000000000",
+		Number:  num,
+		Owner:   owner,
+	}
+}
+
+func (fg *fakeGerrit) changes(q string, start string) []changeInfo {
+	alice := accountInfo{AccountId: 1000001, Username: "alice"}
+	switch {
+	case strings.Contains(q, "status:merged") && !strings.Contains(q, "-status:merged"):
+		c := makeChange(1, "tools", bob)
+		c.Status = "MERGED"
+		c.Submitted = "2023-06-09 10:00:00.000000000"
+		c.CurrentRevision = "fc25519428f4f91813d5a8c324c73ada2d94b578"
+		// Two pages.
+		if start == "0" {
+			c.MoreChanges = true
+			return []changeInfo{c}
+		}
+		c.Number = 2
+		return []changeInfo{c}
+	case strings.Contains(q, "-status:merged"):
+		return []changeInfo{makeChange(3, "platform/build", bob)}
+	case strings.Contains(q, "reviewedby:"):
+		voted := makeChange(10, "tools", alice)
+		voted.Labels = map[string]labelInfo{
+			"Code-Review": {All: []approvalInfo{
+				{accountInfo: bob, Value: 2, Date: "2023-06-08 10:00:00.000000000"},
+				{accountInfo: alice, Value: 1, Date: "2023-06-08 10:00:00.000000000"},
+			}},
+			"Verified": {All: []approvalInfo{
+				// Too late.
+				{accountInfo: bob, Value: 1, Date: "2023-07-08 10:00:00.000000000"},
+			}},
+		}
+		voted.Messages = []changeMessageInfo{
+			{Id: "m1", Author: bob, Date: "2023-06-08 10:00:00.000000000", Message: "Patch Set 1: Code-Review+2\n\nShip it"},
+		}
+		commented := makeChange(11, "platform/build", alice)
+		commented.Messages = []changeMessageInfo{
+			{Id: "m2", Author: bob, Date: "2023-06-09 10:00:00.000000000", Message: "Patch Set 3:\n\n(1 comment)\n"},
+			{Id: "m3", Author: bob, Date: "2023-05-07 10:00:00.000000000", Message: "Patch Set 1:\n\nWhy?"},
+		}
+		botOnly := makeChange(12, "tools", alice)
+		botOnly.Messages = []changeMessageInfo{
+			{Id: "m4", Author: bob, Date: "2023-06-09 10:00:00.000000000", Message: "Build started", Tag: "autogenerated:ci"},
+		}
+		return []changeInfo{voted, commented, botOnly}
+	}
+	return nil
+}
+
+func Test_DoSearch(t *testing.T) {
+	fg := &fakeGerrit{t: t, authenticated: true}
+	srv := httptest.NewTLSServer(fg)
+	defer srv.Close()
+	dr, err := types.MakeDayRange("2023-06-01", "2023-06-30", 0)
+	assert.NoError(t, err)
+	domain := strings.TrimPrefix(srv.URL, "https://")
+	gb := MakeGerritBoss(context.Background(), srv.Client(), &pgmargs.GerritArgs{
+		ServiceArgs: pgmargs.ServiceArgs{Domain: domain, Token: "sekret"},
+		User:        "bob",
+	}, dr)
+	u := &types.MyUser{Login: "bob", Name: "bob", Email: "bob@home.org"}
+	ghost := &types.MyUser{Login: "ghost"}
+	assert.NoError(t, gb.DoSearch([]*types.MyUser{u, ghost}))
+
+	assert.Equal(t, "Bob Bobface", u.Name)
+	assert.Equal(t, []string{`unknown gerrit user "ghost"; skipping gerrit searches for ghost`}, gb.Warnings())
+
+	// Two pages of merged changes, then owned and reviewed.
+	if assert.Len(t, fg.queries, 4) {
+		assert.Equal(t, `owner:1000096 status:merged mergedafter:"2023-06-01" mergedbefore:"2023-07-01"`, fg.queries[0])
+		assert.Equal(t, `owner:1000096 -status:merged after:"2023-06-01" before:"2023-07-01"`, fg.queries[2])
+		assert.Equal(t, `reviewedby:1000096 -owner:1000096 after:"2023-06-01" before:"2023-07-01"`, fg.queries[3])
+	}
+
+	tools := types.RepoId{Org: domain, Name: "tools", Host: types.HostGerrit}
+	build := types.RepoId{Org: domain, Name: "platform/build", Host: types.HostGerrit}
+	if assert.Len(t, u.Commits[tools], 2) {
+		c := u.Commits[tools][0]
+		assert.Equal(t, "fc25519428f4f91813d5a8c324c73ada2d94b578", c.Sha)
+		assert.Equal(t, "https://"+domain+"/c/tools/+/1", c.Url)
+		assert.Equal(t, 9, c.Committed.Day())
+		assert.Equal(t, "merged", c.Pr.State)
+	}
+	if assert.Len(t, u.Custom, 1) {
+		assert.Equal(t, LabelOwned, u.Custom[0].Label)
+		assert.Equal(t, "https://"+domain+"/c/platform/build/+/3", u.Custom[0].Issues.Groups[build][0].HtmlUrl)
+	}
+	if assert.Len(t, u.PrsReviewed.Groups[tools], 1) {
+		reviewed := u.PrsReviewed.Groups[tools][0]
+		assert.Equal(t, 10, reviewed.Number)
+		assert.Equal(t, []string{"Code-Review+2"}, reviewed.Votes)
+		if assert.Len(t, reviewed.Comments, 1) {
+			assert.Equal(t, "Code-Review+2", reviewed.Comments[0].FirstLine)
+		}
+	}
+	assert.Equal(t, 1, u.PrsReviewed.Count())
+	if assert.Len(t, u.IssuesCommented.Groups[build], 1) {
+		commented := u.IssuesCommented.Groups[build][0]
+		assert.Equal(t, 11, commented.Number)
+		assert.Empty(t, commented.Votes)
+		if assert.Len(t, commented.Comments, 1) {
+			assert.Equal(t, "(1 comment)", commented.Comments[0].FirstLine)
+			assert.Equal(t, "https://"+domain+"/c/platform/build/+/11#message-m2", commented.Comments[0].Url)
+		}
+	}
+	assert.Equal(t, 1, u.IssuesCommented.Count())
+}
+
+func Test_DoSearchAnonymous(t *testing.T) {
+	fg := &fakeGerrit{t: t}
+	srv := httptest.NewTLSServer(fg)
+	defer srv.Close()
+	dr, err := types.MakeDayRange("2023-06-01", "2023-06-30", 0)
+	assert.NoError(t, err)
+	gb := MakeGerritBoss(context.Background(), srv.Client(), &pgmargs.GerritArgs{
+		ServiceArgs: pgmargs.ServiceArgs{Domain: strings.TrimPrefix(srv.URL, "https://")},
+	}, dr)
+	u := &types.MyUser{Login: "bob"}
+	assert.NoError(t, gb.DoSearch([]*types.MyUser{u}))
+	assert.Len(t, fg.queries, 4)
+	assert.Equal(t, 1, u.PrsReviewed.Count())
+}
+
+func Test_DoSearchBadPassword(t *testing.T) {
+	srv := httptest.NewTLSServer(&fakeGerrit{t: t, authenticated: true})
+	defer srv.Close()
+	dr, _ := types.MakeDayRange("2023-06-01", "2023-06-30", 0)
+	gb := MakeGerritBoss(context.Background(), srv.Client(), &pgmargs.GerritArgs{
+		ServiceArgs: pgmargs.ServiceArgs{Domain: strings.TrimPrefix(srv.URL, "https://"), Token: "wrong"},
+		User:        "bob",
+	}, dr)
+	err := gb.DoSearch([]*types.MyUser{{Login: "bob"}})
+	assert.ErrorContains(t, err, "status code 401")
+}
+
+func Test_DoSearchCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fg := &fakeGerrit{t: t, cancel: cancel}
+	srv := httptest.NewTLSServer(fg)
+	defer srv.Close()
+	dr, _ := types.MakeDayRange("2023-06-01", "2023-06-30", 0)
+	gb := MakeGerritBoss(ctx, srv.Client(), &pgmargs.GerritArgs{
+		ServiceArgs: pgmargs.ServiceArgs{Domain: strings.TrimPrefix(srv.URL, "https://")},
+	}, dr)
+	bob := &types.MyUser{Login: "bob"}
+	err := gb.DoSearch([]*types.MyUser{bob, {Login: "carol"}, {Login: "dave"}})
+	assert.ErrorIs(t, err, context.Canceled)
+	var sErr *types.StoppedError
+	if assert.ErrorAs(t, err, &sErr) {
+		// bob was finished.
+		assert.Equal(t, []string{"carol", "dave"}, sErr.Logins)
+	}
+	assert.Equal(t, 1, bob.PrsReviewed.Count())
+}
+
+func Test_firstLine(t *testing.T) {
+	tests := map[string]struct {
+		msg  string
+		want string
+	}{
+		"empty":         {},
+		"vote":          {msg: "Patch Set 2: Code-Review+1", want: "Code-Review+1"},
+		"comment count": {msg: "Patch Set 12:\n\n(3 comments)", want: "(3 comments)"},
+		"plain":         {msg: "\n  Abandoned\nbecause", want: "Abandoned"},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			assert.Equal(t, tt.want, firstLine(tt.msg))
+		})
+	}
+}
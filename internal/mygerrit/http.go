@@ -0,0 +1,70 @@
+package mygerrit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/monopole/snips/internal/myhttp"
+	"github.com/monopole/snips/internal/progress"
+)
+
+const (
+
+	// authPrefix starts the path of authenticated requests.
+	// https://gerrit-review.googlesource.com/Documentation/rest-api.html#authentication
+	authPrefix = "a/"
+)
+
+// xssiPrefix starts every JSON response, to defeat cross site script inclusion.
+// https://gerrit-review.googlesource.com/Documentation/rest-api.html#output
+var xssiPrefix = []byte(")]}'")
+
+// makeUrl returns the location of the given endpoint on the gerrit domain.
+func (gb *gerritBoss) makeUrl(endpoint string, query url.Values) (*url.URL, error) {
+	path := endpoint
+	if gb.args.Token != "" {
+		path = authPrefix + endpoint
+	}
+	loc, err := url.Parse(myhttp.Scheme + gb.args.Domain + "/" + path)
+	if err != nil {
+		return nil, err
+	}
+	if query != nil {
+		loc.RawQuery = query.Encode()
+	}
+	return loc, nil
+}
+
+// doGerritRequest GETs the location, and unmarshals the JSON response into resp.
+func (gb *gerritBoss) doGerritRequest(loc *url.URL, resp any) error {
+	req, err := http.NewRequestWithContext(gb.ctx, http.MethodGet, loc.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(myhttp.HeaderAccept, myhttp.ContentTypeJson)
+	if gb.args.Token != "" {
+		req.SetBasicAuth(gb.args.User, gb.args.Token)
+	}
+	ans, err := gb.htCl.Do(req)
+	if err != nil {
+		return err
+	}
+	progress.Page()
+	defer ans.Body.Close()
+	if ans.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code %d from GET %s: %s",
+			ans.StatusCode, loc.Path, myhttp.ErrBody(ans.Body))
+	}
+	data, err := io.ReadAll(ans.Body)
+	if err != nil {
+		return fmt.Errorf("ReadAll failure: %w", err)
+	}
+	if err = json.Unmarshal(bytes.TrimPrefix(data, xssiPrefix), resp); err != nil {
+		return fmt.Errorf("trouble unmarshaling data from response; %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package mygerrit
+
+// https://gerrit-review.googlesource.com/Documentation/rest-api-accounts.html#account-info
+type accountInfo struct {
+	AccountId int    `json:"_account_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Email     string `json:"email,omitempty"`
+	Username  string `json:"username,omitempty"`
+	Avatars   []struct {
+		Url    string `json:"url"`
+		Height int    `json:"height,omitempty"`
+	} `json:"avatars,omitempty"`
+}
+
+// approvalInfo is a vote on a label.
+// https://gerrit-review.googlesource.com/Documentation/rest-api-changes.html#approval-info
+type approvalInfo struct {
+	accountInfo
+	Value int    `json:"value,omitempty"`
+	Date  string `json:"date,omitempty"`
+}
+
+// labelInfo holds the votes on a label, e.g. "Code-Review",
+// given the DETAILED_LABELS option.
+type labelInfo struct {
+	All []approvalInfo `json:"all,omitempty"`
+}
+
+// https://gerrit-review.googlesource.com/Documentation/rest-api-changes.html#change-message-info
+type changeMessageInfo struct {
+	Id     string      `json:"id"`
+	Author accountInfo `json:"author"`
+	Date   string      `json:"date"`
+	// Message is something like "Patch Set 2: Code-Review+1\n\n(1 comment)".
+	Message string `json:"message"`
+	// Tag starting with "autogenerated:" marks messages made by tools.
+	Tag string `json:"tag,omitempty"`
+}
+
+// https://gerrit-review.googlesource.com/Documentation/rest-api-changes.html#change-info
+type changeInfo struct {
+	Id              string               `json:"id"`
+	Project         string               `json:"project"`
+	Branch          string               `json:"branch"`
+	Subject         string               `json:"subject"`
+	Status          string               `json:"status"`
+	Created         string               `json:"created"`
+	Updated         string               `json:"updated"`
+	Submitted       string               `json:"submitted,omitempty"`
+	Number          int                  `json:"_number"`
+	Owner           accountInfo          `json:"owner"`
+	Labels          map[string]labelInfo `json:"labels,omitempty"`
+	Messages        []changeMessageInfo  `json:"messages,omitempty"`
+	CurrentRevision string               `json:"current_revision,omitempty"`
+	// MoreChanges, on the last change of a page, means there's another page.
+	MoreChanges bool `json:"_more_changes,omitempty"`
+}
@@ -0,0 +1,214 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v52/github"
+	"github.com/monopole/snips/internal/myhttp"
+	"github.com/monopole/snips/internal/pgmargs"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// jwtLifetime is how long an app's JWT is good for; GitHub allows at most ten minutes.
+	jwtLifetime = 9 * time.Minute
+	// jwtClockSkew backdates a JWT, in case our clock is ahead of GitHub's.
+	jwtClockSkew = time.Minute
+
+	// tokenRefreshMargin is how long before an installation token
+	// expires (an hour after it's made) that it's replaced.
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// appTokenSource makes installation access tokens for a GitHub App.
+// https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app/authenticating-as-a-github-app-installation
+type appTokenSource struct {
+	// ctx bounds the token requests, which may come late in a run.
+	ctx    context.Context
+	htCl   *http.Client
+	apiUrl string
+	appId  int64
+	key    *rsa.PrivateKey
+	// installationId is the installation whose tokens are made.
+	installationId int64
+	now            func() time.Time
+}
+
+// MakeAppTokenSource returns a source of installation access tokens
+// for the given app, from the REST API rooted at apiUrl (see ApiBaseUrl),
+// each replaced shortly before it expires.
+func MakeAppTokenSource(
+	ctx context.Context, htCl *http.Client, apiUrl string, app *pgmargs.GhAppArgs) (oauth2.TokenSource, error) {
+	key, err := loadPrivateKey(app.KeyPath)
+	if err != nil {
+		return nil, err
+	}
+	src := &appTokenSource{
+		ctx:            ctx,
+		htCl:           htCl,
+		apiUrl:         apiUrl,
+		appId:          app.Id,
+		key:            key,
+		installationId: app.InstallationId,
+		now:            time.Now,
+	}
+	if src.installationId == 0 {
+		if src.installationId, err = src.findInstallation(); err != nil {
+			return nil, err
+		}
+	}
+	return oauth2.ReuseTokenSourceWithExpiry(nil, src, tokenRefreshMargin), nil
+}
+
+// MakeCheckedAppClient returns a client of the domain's API, rooted at
+// apiUrl if not empty, authenticated as the given app, whose installation
+// token works.  Apps have permissions rather than scopes, so installation
+// tokens report no scopes worth checking.
+func MakeCheckedAppClient(
+	ctx context.Context, htCl *http.Client,
+	domain string, apiUrl string, app *pgmargs.GhAppArgs) (*github.Client, error) {
+	ts, err := MakeAppTokenSource(ctx, htCl, ApiBaseUrl(domain, apiUrl), app)
+	if err != nil {
+		return nil, err
+	}
+	cl, err := MakeGhApiClient(ctx, htCl, domain, apiUrl, ts)
+	if err != nil {
+		return nil, fmt.Errorf("trouble making github client: %w", err)
+	}
+	if _, err = CheckToken(ctx, cl); err != nil {
+		return nil, err
+	}
+	return cl, nil
+}
+
+// loadPrivateKey reads an RSA private key from a PEM file, as made in
+// an app's settings (PKCS #1), or converted to PKCS #8.
+func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load github app key; %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in github app key %s", path)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("trouble parsing github app key %s; %w", path, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("github app key %s isn't an RSA key", path)
+	}
+	return key, nil
+}
+
+// makeJwt returns a JSON web token identifying the app, signed with its key.
+// https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app/generating-a-json-web-token-jwt-for-a-github-app
+func (s *appTokenSource) makeJwt() (string, error) {
+	now := s.now()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-jwtClockSkew).Unix(),
+		"exp": now.Add(jwtLifetime).Unix(),
+		"iss": strconv.FormatInt(s.appId, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("trouble signing github app jwt; %w", err)
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// findInstallation returns the id of the app's only installation.
+func (s *appTokenSource) findInstallation() (int64, error) {
+	var installations []struct {
+		Id      int64 `json:"id"`
+		Account struct {
+			Login string `json:"login"`
+		} `json:"account"`
+	}
+	if err := s.doAppRequest(http.MethodGet, "app/installations", &installations); err != nil {
+		return 0, fmt.Errorf("trouble listing github app installations; %w", err)
+	}
+	switch len(installations) {
+	case 0:
+		return 0, fmt.Errorf("github app %d isn't installed anywhere", s.appId)
+	case 1:
+		return installations[0].Id, nil
+	}
+	var b bytes.Buffer
+	for _, inst := range installations {
+		fmt.Fprintf(&b, " %d (%s)", inst.Id, inst.Account.Login)
+	}
+	return 0, fmt.Errorf(
+		"github app %d has %d installations; pick one of%s", s.appId, len(installations), b.String())
+}
+
+// Token makes a new installation access token.
+// https://docs.github.com/en/rest/apps/apps#create-an-installation-access-token-for-an-app
+func (s *appTokenSource) Token() (*oauth2.Token, error) {
+	var resp struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	endpoint := fmt.Sprintf("app/installations/%d/access_tokens", s.installationId)
+	if err := s.doAppRequest(http.MethodPost, endpoint, &resp); err != nil {
+		return nil, fmt.Errorf("trouble getting github app installation token; %w", err)
+	}
+	return &oauth2.Token{AccessToken: resp.Token, Expiry: resp.ExpiresAt}, nil
+}
+
+// doAppRequest sends a request authenticated as the app,
+// and unmarshals the JSON response into resp.
+func (s *appTokenSource) doAppRequest(method string, endpoint string, resp any) error {
+	jwt, err := s.makeJwt()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(s.ctx, method, s.apiUrl+endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(myhttp.HeaderAccept, "application/vnd.github+json")
+	req.Header.Set(myhttp.HeaderAAuthorization, "Bearer "+jwt)
+	ans, err := s.htCl.Do(req)
+	if err != nil {
+		return err
+	}
+	defer ans.Body.Close()
+	if ans.StatusCode != http.StatusOK && ans.StatusCode != http.StatusCreated {
+		return fmt.Errorf("status code %d from %s %s: %s",
+			ans.StatusCode, method, req.URL.Path, myhttp.ErrBody(ans.Body))
+	}
+	if err = json.NewDecoder(ans.Body).Decode(resp); err != nil {
+		return fmt.Errorf("trouble unmarshaling data from response; %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package client
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monopole/snips/internal/pgmargs"
+	"github.com/stretchr/testify/assert"
+)
+
+const testAppId = 1234
+
+// fakeGhApp stands in for the app endpoints of a GitHub Enterprise instance.
+type fakeGhApp struct {
+	t   *testing.T
+	key *rsa.PublicKey
+	// installations are the ids of the app's installations.
+	installations []int64
+	// lifetime is how long the installation tokens made are good for.
+	lifetime time.Duration
+	// exchanges counts the installation tokens made.
+	exchanges int
+}
+
+func (fa *fakeGhApp) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/v3/rate_limit" {
+		// Called with an installation token, rather than the app's JWT.
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ghs_") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// Installation tokens have no scopes.
+		w.Header().Set(headerScopes, "")
+		_, _ = w.Write([]byte(`{"resources": {}}`))
+		return
+	}
+	if !fa.validJwt(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v3/app/installations":
+		var result []map[string]any
+		for _, id := range fa.installations {
+			result = append(result, map[string]any{"id": id, "account": map[string]string{"login": "acme"}})
+		}
+		assert.NoError(fa.t, json.NewEncoder(w).Encode(result))
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v3/app/installations/42/access_tokens":
+		fa.exchanges++
+		w.WriteHeader(http.StatusCreated)
+		assert.NoError(fa.t, json.NewEncoder(w).Encode(map[string]any{
+			"token":      "ghs_" + strings.Repeat("x", fa.exchanges),
+			"expires_at": time.Now().Add(fa.lifetime).UTC().Format(time.RFC3339),
+		}))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// validJwt checks the token's signature and claims.
+func (fa *fakeGhApp) validJwt(jwt string) bool {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if rsa.VerifyPKCS1v15(fa.key, crypto.SHA256, digest[:], sig) != nil {
+		return false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	var claims struct {
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+		Iss string `json:"iss"`
+	}
+	if err = json.Unmarshal(data, &claims); err != nil {
+		return false
+	}
+	now := time.Now().Unix()
+	return claims.Iss == "1234" && claims.Iat <= now && now < claims.Exp && claims.Exp-claims.Iat <= 600
+}
+
+// writeKey writes the key to a PEM file, returning its path.
+func writeKey(t *testing.T, key *rsa.PrivateKey) string {
+	path := filepath.Join(t.TempDir(), "app.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestMakeAppTokenSource(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	keyPath := writeKey(t, key)
+	tests := map[string]struct {
+		installations  []int64
+		installationId int64
+		lifetime       time.Duration
+		wantExchanges  int
+		errMsg         string
+	}{
+		"reused": {
+			installations: []int64{42},
+			lifetime:      time.Hour,
+			wantExchanges: 1,
+		},
+		"refreshed before expiry": {
+			installations: []int64{42},
+			lifetime:      tokenRefreshMargin - time.Minute,
+			wantExchanges: 2,
+		},
+		"chosen installation": {
+			installations:  []int64{41, 42},
+			installationId: 42,
+			lifetime:       time.Hour,
+			wantExchanges:  1,
+		},
+		"ambiguous installation": {
+			installations: []int64{41, 42},
+			errMsg:        "has 2 installations; pick one of 41 (acme) 42 (acme)",
+		},
+		"not installed": {
+			errMsg: "isn't installed anywhere",
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			fa := &fakeGhApp{t: t, key: &key.PublicKey, installations: tt.installations, lifetime: tt.lifetime}
+			srv := httptest.NewTLSServer(fa)
+			defer srv.Close()
+			ts, err := MakeAppTokenSource(context.Background(), srv.Client(), ApiBaseUrl(strings.TrimPrefix(srv.URL, "https://"), ""), &pgmargs.GhAppArgs{
+				Id:             testAppId,
+				KeyPath:        keyPath,
+				InstallationId: tt.installationId,
+			})
+			if tt.errMsg != "" {
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+			assert.NoError(t, err)
+			for range 2 {
+				tok, err := ts.Token()
+				assert.NoError(t, err)
+				assert.True(t, strings.HasPrefix(tok.AccessToken, "ghs_"))
+			}
+			assert.Equal(t, tt.wantExchanges, fa.exchanges)
+		})
+	}
+}
+
+func TestLoadPrivateKeyPkcs8(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "app.pem")
+	assert.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+	got, err := loadPrivateKey(path)
+	assert.NoError(t, err)
+	assert.True(t, key.Equal(got))
+
+	assert.NoError(t, os.WriteFile(path, []byte("nope"), 0o600))
+	_, err = loadPrivateKey(path)
+	assert.ErrorContains(t, err, "no PEM data")
+}
+
+func TestMakeCheckedAppClient(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	fa := &fakeGhApp{t: t, key: &key.PublicKey, installations: []int64{42}, lifetime: time.Hour}
+	srv := httptest.NewTLSServer(fa)
+	defer srv.Close()
+	cl, err := MakeCheckedAppClient(context.Background(), srv.Client(),
+		strings.TrimPrefix(srv.URL, "https://"), "", &pgmargs.GhAppArgs{Id: testAppId, KeyPath: writeKey(t, key)})
+	assert.NoError(t, err)
+	if assert.NotNil(t, cl) {
+		// The empty scopes header would fail a scope check.
+		missing, err := CheckToken(context.Background(), cl)
+		assert.NoError(t, err)
+		assert.Equal(t, RequiredScopes, missing)
+	}
+	assert.Equal(t, 1, fa.exchanges)
+
+	_, err = MakeCheckedAppClient(context.Background(), srv.Client(),
+		strings.TrimPrefix(srv.URL, "https://"), "", &pgmargs.GhAppArgs{Id: testAppId, KeyPath: "/nope.pem"})
+	assert.ErrorContains(t, err, "unable to load github app key")
+}
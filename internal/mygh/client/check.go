@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v52/github"
+)
+
+// headerScopes lists the scopes granted to a classic or OAuth token.
+// https://docs.github.com/en/apps/oauth-apps/building-oauth-apps/scopes-for-oauth-apps#checking-granted-scopes
+const headerScopes = "X-OAuth-Scopes"
+
+// RequiredScopes are the scopes snips needs to see pull requests,
+// organization membership and user profiles.
+var RequiredScopes = []string{"repo", "read:org", "user"}
+
+// impliedBy maps a scope to the broader scopes that include it.
+var impliedBy = map[string][]string{
+	"read:org": {"write:org", "admin:org"},
+}
+
+// ErrBadToken means GitHub rejected the token.
+var ErrBadToken = errors.New("github rejected the token")
+
+// CheckToken makes a cheap authenticated call, returning ErrBadToken if
+// the token doesn't work, else the RequiredScopes it lacks.  Tokens that
+// don't report scopes, e.g. fine-grained tokens, are assumed sufficient.
+func CheckToken(ctx context.Context, cl *github.Client) ([]string, error) {
+	// Unlike most calls, this works for any kind of token, and is free.
+	_, resp, err := cl.RateLimits(ctx)
+	if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("%w; it may be expired or revoked", ErrBadToken)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("trouble checking github token; %w", err)
+	}
+	granted, ok := resp.Header[http.CanonicalHeaderKey(headerScopes)]
+	if !ok {
+		return nil, nil
+	}
+	return MissingScopes(strings.Join(granted, ",")), nil
+}
+
+// MissingScopes returns the RequiredScopes not among the given
+// comma separated scopes, e.g. "repo, user".
+func MissingScopes(granted string) []string {
+	var have []string
+	for _, s := range strings.Split(granted, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			have = append(have, s)
+		}
+	}
+	var result []string
+	for _, need := range RequiredScopes {
+		if slices.Contains(have, need) ||
+			slices.ContainsFunc(impliedBy[need], func(s string) bool { return slices.Contains(have, s) }) {
+			continue
+		}
+		result = append(result, need)
+	}
+	return result
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-github/v52/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMissingScopes(t *testing.T) {
+	tests := map[string]struct {
+		granted string
+		want    []string
+	}{
+		"none":          {want: []string{"repo", "read:org", "user"}},
+		"all":           {granted: "repo, read:org, user", want: nil},
+		"broader org":   {granted: "admin:org,repo,user", want: nil},
+		"missing some":  {granted: "repo, gist", want: []string{"read:org", "user"}},
+		"narrower user": {granted: "repo, read:org, read:user", want: []string{"user"}},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			assert.Equal(t, tt.want, MissingScopes(tt.granted))
+		})
+	}
+}
+
+func TestCheckToken(t *testing.T) {
+	tests := map[string]struct {
+		status  int
+		scopes  []string
+		want    []string
+		wantErr error
+	}{
+		"good":         {status: http.StatusOK, scopes: []string{"repo, read:org, user"}},
+		"under-scoped": {status: http.StatusOK, scopes: []string{"repo"}, want: []string{"read:org", "user"}},
+		"fine-grained": {status: http.StatusOK},
+		"bad":          {status: http.StatusUnauthorized, wantErr: ErrBadToken},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/api/v3/rate_limit", r.URL.Path)
+				for _, s := range tt.scopes {
+					w.Header().Add(headerScopes, s)
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(`{"resources": {}}`))
+			}))
+			defer srv.Close()
+			cl, err := github.NewEnterpriseClient(srv.URL, srv.URL, srv.Client())
+			assert.NoError(t, err)
+			got, err := CheckToken(context.Background(), cl)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -2,16 +2,55 @@ package client
 
 import (
 	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
 	"github.com/google/go-github/v52/github"
+	"github.com/monopole/snips/internal/myhttp"
 	"github.com/monopole/snips/internal/pgmargs"
 	"golang.org/x/oauth2"
 )
 
-func MakeGhApiClient(ctx context.Context, domain string, token string) (*github.Client, error) {
-	oaCl := oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}))
+// MakeGhApiClient returns a client of the domain's API, rooted at apiUrl
+// if not empty, authenticated with tokens from the given source, and
+// using the transport (and so the TLS settings) of htCl.
+func MakeGhApiClient(
+	ctx context.Context, htCl *http.Client,
+	domain string, apiUrl string, ts oauth2.TokenSource) (*github.Client, error) {
+	oaCl := oauth2.NewClient(context.WithValue(ctx, oauth2.HTTPClient, htCl), ts)
+	if apiUrl != "" {
+		base, err := url.Parse(ApiBaseUrl(domain, apiUrl))
+		if err != nil {
+			return nil, err
+		}
+		cl := github.NewClient(oaCl)
+		// snips never uploads, but keep the token away from github.com.
+		cl.BaseURL, cl.UploadURL = base, base
+		return cl, nil
+	}
 	if domain == pgmargs.GithubPublic {
 		return github.NewClient(oaCl), nil
 	}
-	const scheme = "https://"
-	return github.NewEnterpriseClient(scheme+domain, scheme+domain, oaCl)
+	return github.NewEnterpriseClient(myhttp.Scheme+domain, myhttp.Scheme+domain, oaCl)
+}
+
+// StaticTokenSource returns a source of just the given token.
+func StaticTokenSource(token string) oauth2.TokenSource {
+	return oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+}
+
+// ApiBaseUrl returns the root of the domain's REST API,
+// apiUrl if that's not empty.
+func ApiBaseUrl(domain string, apiUrl string) string {
+	if apiUrl != "" {
+		if !strings.HasSuffix(apiUrl, "/") {
+			apiUrl += "/"
+		}
+		return apiUrl
+	}
+	if domain == pgmargs.GithubPublic {
+		return "https://api.github.com/"
+	}
+	return myhttp.Scheme + domain + "/api/v3/"
 }
@@ -2,12 +2,14 @@ package oauth
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
 	"time"
 
 	"github.com/monopole/snips/internal/myhttp"
@@ -16,11 +18,40 @@ import (
 const (
 	scopes = "repo read:org user"
 
-	defaultWaitingInterval = 8 * time.Second
-	maxAttempts            = 10
-	WarningPrefix          = " ***** "
+	// Defaults and adjustments from RFC 8628, in seconds.
+	// https://datatracker.ietf.org/doc/html/rfc8628#section-3.5
+	defaultIntervalSeconds  = 5
+	slowDownIncreaseSeconds = 5
+	// defaultExpiresInSeconds is used if the server doesn't say how long
+	// its codes last; GitHub's last fifteen minutes.
+	defaultExpiresInSeconds = 900
+	// maxBackoffSeconds caps the wait between polls that keep failing,
+	// e.g. during a network outage.
+	maxBackoffSeconds = 60
+
+	WarningPrefix = " ***** "
+)
+
+// Errors from the token endpoint while polling.
+// https://datatracker.ietf.org/doc/html/rfc8628#section-3.5
+const (
+	errAuthorizationPending = "authorization_pending"
+	errSlowDown             = "slow_down"
+	errExpiredToken         = "expired_token"
+	errAccessDenied         = "access_denied"
 )
 
+var (
+	// ErrAccessDenied means the user declined to authorize the login.
+	ErrAccessDenied = errors.New("login was denied")
+	// ErrExpired means the user didn't enter the code in time.
+	ErrExpired = errors.New("the login code expired before it was entered")
+)
+
+// second is the unit of the intervals and lifetimes from the server;
+// tests shrink it.
+var second = time.Second
+
 type Params struct {
 	// GhDomain is the GitHub domain, likely "github.com", or "github.company.com"
 	GhDomain string
@@ -29,8 +60,16 @@ type Params struct {
 	ClientId string
 	// HttpCl is used to communicate with the GhDomain.
 	HttpCl *http.Client
-	// Verbose being true yields more print statements.
-	Verbose bool
+	// Out, if not nil, gets the instructions the user must follow to log in.
+	Out io.Writer
+}
+
+// Token is an access token granted by the device flow.
+type Token struct {
+	Value string `json:"access_token"`
+	Type  string `json:"token_type"`
+	// Scope holds the scopes granted, comma separated.
+	Scope string `json:"scope"`
 }
 
 type devCodeData struct {
@@ -39,61 +78,77 @@ type devCodeData struct {
 	VerifyUri          string `json:"verification_uri"`
 	ExpiresIn          int    `json:"expires_in"`
 	MinIntervalSeconds int    `json:"interval"`
+	Error              string `json:"error"`
+	ErrorDescription   string `json:"error_description"`
+}
+
+// tokenResponse is a response from the token endpoint, holding either
+// a token or an error.
+type tokenResponse struct {
+	Token
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
 }
 
 // GetAccessToken implements the instructions at
 // https://docs.github.com/en/apps/oauth-apps/building-oauth-apps/authorizing-oauth-apps#device-flow
-func GetAccessToken(params *Params) (accessCode string, err error) {
+// following RFC 8628.  It gives up when the context is done.
+func GetAccessToken(ctx context.Context, params *Params) (*Token, error) {
 	loc, err := url.Parse(myhttp.Scheme + params.GhDomain + "/login/device/code")
 	if err != nil {
-		return
+		return nil, err
 	}
 
 	postData := url.Values{}
 	postData.Add("client_id", params.ClientId)
 	postData.Add("scope", scopes)
-	if params.Verbose {
-		fmt.Printf("posting to %s\n", loc.String())
-	}
-	r, err := sendPost(params.HttpCl, loc, postData, params.Verbose)
+	slog.Debug("asking for a device code", "url", loc.String())
+	var codes devCodeData
+	status, err := sendPost(ctx, params.HttpCl, loc, postData, &codes)
 	if err != nil {
-		return
+		return nil, err
 	}
-
-	var codes devCodeData
-	p := json.NewDecoder(r)
-	if err = p.Decode(&codes); err != nil {
-		err = fmt.Errorf("failed to parse device code from %+v\n; %w", codes, err)
-		return
+	if codes.Error != "" {
+		return nil, fmt.Errorf("failed to get a device code from %s: %s %s",
+			params.GhDomain, codes.Error, codes.ErrorDescription)
 	}
-	if params.Verbose {
-		fmt.Printf("CODES %+v\n", codes)
+	if codes.DeviceCode == "" {
+		return nil, fmt.Errorf("failed to get a device code from %s (status code %d)", params.GhDomain, status)
 	}
-	waitingInterval := computeWaitingInterval(codes.MinIntervalSeconds)
-	warnUser(waitingInterval, &codes)
-	return pollForTheUsersApproval(params.HttpCl, params, waitingInterval, codes.DeviceCode)
+	expiresIn := computeDuration(codes.ExpiresIn, defaultExpiresInSeconds)
+	if params.Out != nil {
+		warnUser(params.Out, expiresIn, &codes)
+	}
+	return pollForTheUsersApproval(
+		ctx, params, computeDuration(codes.MinIntervalSeconds, defaultIntervalSeconds),
+		time.Now().Add(expiresIn), codes.DeviceCode)
 }
 
-func warnUser(waitingInterval time.Duration, codes *devCodeData) {
-	fmt.Fprintf(os.Stderr, `
+func warnUser(w io.Writer, expiresIn time.Duration, codes *devCodeData) {
+	fmt.Fprintf(w, `
 %s You have %s to visit  %s  and enter this code:  %s
-`, WarningPrefix, time.Duration(maxAttempts)*waitingInterval, codes.VerifyUri, codes.UserCode)
+`, WarningPrefix, expiresIn, codes.VerifyUri, codes.UserCode)
 }
 
-func computeWaitingInterval(minIntervalSeconds int) time.Duration {
-	minInterval := time.Duration(minIntervalSeconds) + time.Second
-	if defaultWaitingInterval > minInterval {
-		return defaultWaitingInterval
+// computeDuration returns the given number of seconds, or the default if
+// the server didn't give a number.
+func computeDuration(seconds int, defaultSeconds int) time.Duration {
+	if seconds <= 0 {
+		seconds = defaultSeconds
 	}
-	return minInterval
+	return time.Duration(seconds) * second
 }
 
+// pollForTheUsersApproval asks for the token every interval until it's
+// granted, the user denies it, the code expires at the deadline, or the
+// context is done.  While polls fail to get a response, the wait between
+// them doubles, up to maxBackoffSeconds, as RFC 8628 section 3.5 advises.
 func pollForTheUsersApproval(
-	cl *http.Client, params *Params,
-	pollInterval time.Duration, devCode string) (accessCode string, err error) {
+	ctx context.Context, params *Params,
+	interval time.Duration, deadline time.Time, devCode string) (*Token, error) {
 	loc, err := url.Parse(myhttp.Scheme + params.GhDomain + "/login/oauth/access_token")
 	if err != nil {
-		return
+		return nil, err
 	}
 
 	postData := url.Values{}
@@ -101,63 +156,86 @@ func pollForTheUsersApproval(
 	postData.Add("device_code", devCode)
 	postData.Add("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
 
-	attempts := 0
-	for attempts < maxAttempts {
-		time.Sleep(pollInterval)
-		var r io.ReadCloser
-		r, err = sendPost(cl, loc, postData, params.Verbose)
-		if err != nil {
-			if params.Verbose {
-				fmt.Printf("attempt %2d: post error %s\n", attempts, err.Error())
-			}
-			continue
+	// wait is the time until the next poll.
+	wait := interval
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("gave up waiting for login; %w", ctx.Err())
+		case <-timer.C:
 		}
-		var token struct {
-			Value string `json:"access_token"`
-			Type  string `json:"token_type"`
-			Scope string `json:"scope"`
+		if !time.Now().Before(deadline) {
+			return nil, ErrExpired
 		}
-		p := json.NewDecoder(r)
-		if err = p.Decode(&token); err != nil {
-			if params.Verbose {
-				fmt.Printf("attempt %2d: response parse error %s\n", attempts, err.Error())
+		slog.Debug("polling for login approval", "interval", interval)
+		var resp tokenResponse
+		status, err := sendPost(ctx, params.HttpCl, loc, postData, &resp)
+		if err != nil {
+			// Possibly a network blip; try again until the deadline.
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("gave up waiting for login; %w", ctx.Err())
 			}
+			wait = backOff(wait, interval)
+			slog.Warn("trouble polling for login approval; will retry", "err", err, "wait", wait)
+			timer.Reset(wait)
 			continue
 		}
-		if params.Verbose {
-			fmt.Printf("TOKEN %+v\n", token)
-		}
-		if token.Value != "" {
-			accessCode = token.Value
-			return
+		switch resp.Error {
+		case "":
+			if resp.Value == "" {
+				return nil, fmt.Errorf("no token in response from %s (status code %d)", params.GhDomain, status)
+			}
+			return &resp.Token, nil
+		case errAuthorizationPending:
+		case errSlowDown:
+			interval += slowDownIncreaseSeconds * second
+			slog.Info("login server asked to slow down", "interval", interval)
+		case errExpiredToken:
+			return nil, ErrExpired
+		case errAccessDenied:
+			return nil, ErrAccessDenied
+		default:
+			return nil, fmt.Errorf("login failed: %s %s", resp.Error, resp.ErrorDescription)
 		}
-		attempts++
+		wait = interval
+		timer.Reset(wait)
 	}
-	err = fmt.Errorf("exhausted %d attempts", maxAttempts)
-	return
 }
 
-func sendPost(cl *http.Client, loc *url.URL, postData url.Values, debug bool) (ans io.ReadCloser, err error) {
-	var (
-		req  *http.Request
-		resp *http.Response
-	)
-	req, err = http.NewRequest(http.MethodPost, loc.String(), bytes.NewBufferString(postData.Encode()))
+// backOff returns the wait after a failed poll: double the last wait,
+// capped at maxBackoffSeconds unless the interval itself is longer.
+func backOff(wait, interval time.Duration) time.Duration {
+	return min(2*wait, max(interval, maxBackoffSeconds*second))
+}
+
+// sendPost posts the form, and unmarshals the JSON response into resp,
+// returning the response's status code.  Per RFC 8628, errors come
+// with status 400, but GitHub sends them with status 200.
+func sendPost(
+	ctx context.Context, cl *http.Client, loc *url.URL, postData url.Values, resp any) (int, error) {
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, loc.String(), bytes.NewBufferString(postData.Encode()))
 	if err != nil {
-		return
+		return 0, err
 	}
 	req.Header.Set(myhttp.HeaderAccept, myhttp.ContentTypeJson)
 	req.Header.Set(myhttp.HeaderContentType, myhttp.ContentTypeFormURLEncoded)
-	resp, err = cl.Do(req)
+	ans, err := cl.Do(req)
 	if err != nil {
-		return
+		return 0, err
 	}
-	if debug {
-		myhttp.PrintResponse(resp, myhttp.PrArgs{Headers: true})
+	defer ans.Body.Close()
+	data, err := io.ReadAll(ans.Body)
+	if err != nil {
+		return 0, fmt.Errorf("ReadAll failure: %w", err)
 	}
-	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf("status code %d", resp.StatusCode)
-		return
+	if err = json.Unmarshal(data, resp); err != nil {
+		if len(data) > myhttp.MaxErrBody {
+			data = data[:myhttp.MaxErrBody]
+		}
+		return 0, fmt.Errorf("status code %d from POST %s: %s", ans.StatusCode, loc.Path, bytes.TrimSpace(data))
 	}
-	return resp.Body, nil
+	return ans.StatusCode, nil
 }
@@ -0,0 +1,278 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testClientId = "Iv1.test"
+
+// reply is a canned response from the fake authorization server.
+type reply struct {
+	status int
+	body   map[string]any
+}
+
+func pending() reply {
+	return reply{status: http.StatusBadRequest, body: map[string]any{"error": errAuthorizationPending}}
+}
+
+// fakeAuthServer stands in for GitHub's device flow endpoints.
+type fakeAuthServer struct {
+	t *testing.T
+	// codeReply answers the device code request.
+	codeReply reply
+	// tokenReplies answer successive token requests, the last one repeating.
+	tokenReplies []reply
+	mu           sync.Mutex
+	// polls holds the time of each token request.
+	polls []time.Time
+	// drop holds the numbers, from 1, of the token requests whose
+	// connections are closed unanswered, as in a network outage.
+	drop []int
+}
+
+func (fs *fakeAuthServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	assert.NoError(fs.t, r.ParseForm())
+	assert.Equal(fs.t, testClientId, r.PostForm.Get("client_id"))
+	var rep reply
+	switch r.URL.Path {
+	case "/login/device/code":
+		assert.Equal(fs.t, scopes, r.PostForm.Get("scope"))
+		rep = fs.codeReply
+	case "/login/oauth/access_token":
+		assert.Equal(fs.t, "dev123", r.PostForm.Get("device_code"))
+		assert.Equal(fs.t, "urn:ietf:params:oauth:grant-type:device_code", r.PostForm.Get("grant_type"))
+		fs.mu.Lock()
+		fs.polls = append(fs.polls, time.Now())
+		rep = fs.tokenReplies[min(len(fs.polls), len(fs.tokenReplies))-1]
+		drop := slices.Contains(fs.drop, len(fs.polls))
+		fs.mu.Unlock()
+		if drop {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			assert.NoError(fs.t, err)
+			_ = conn.Close()
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(rep.status)
+	assert.NoError(fs.t, json.NewEncoder(w).Encode(rep.body))
+}
+
+func (fs *fakeAuthServer) pollCount() int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return len(fs.polls)
+}
+
+func codes(intervalSeconds, expiresInSeconds int) reply {
+	return reply{status: http.StatusOK, body: map[string]any{
+		"device_code":      "dev123",
+		"user_code":        "ABCD-1234",
+		"verification_uri": "https://github.com/login/device",
+		"expires_in":       expiresInSeconds,
+		"interval":         intervalSeconds,
+	}}
+}
+
+func granted() reply {
+	return reply{status: http.StatusOK, body: map[string]any{
+		"access_token": "gho_abc", "token_type": "bearer", "scope": "repo,read:org,user",
+	}}
+}
+
+func TestGetAccessToken(t *testing.T) {
+	// Make a server "second" a few milliseconds.
+	defer func(s time.Duration) { second = s }(second)
+	second = 4 * time.Millisecond
+
+	tests := map[string]struct {
+		codeReply    reply
+		tokenReplies []reply
+		// cancelAfter, if not zero, cancels the context after that many polls.
+		cancelAfter int
+		wantPolls   int
+		wantErr     error
+		errMsg      string
+	}{
+		"granted after pending": {
+			codeReply:    codes(1, 900),
+			tokenReplies: []reply{pending(), pending(), granted()},
+			wantPolls:    3,
+		},
+		"github style errors with status 200": {
+			codeReply: codes(1, 900),
+			tokenReplies: []reply{
+				{status: http.StatusOK, body: map[string]any{"error": errAuthorizationPending}},
+				granted(),
+			},
+			wantPolls: 2,
+		},
+		"denied": {
+			codeReply: codes(1, 900),
+			tokenReplies: []reply{
+				pending(),
+				{status: http.StatusBadRequest, body: map[string]any{"error": errAccessDenied}},
+			},
+			wantPolls: 2,
+			wantErr:   ErrAccessDenied,
+		},
+		"expired by server": {
+			codeReply: codes(1, 900),
+			tokenReplies: []reply{
+				{status: http.StatusBadRequest, body: map[string]any{"error": errExpiredToken}},
+			},
+			wantPolls: 1,
+			wantErr:   ErrExpired,
+		},
+		"expired by expires_in": {
+			codeReply:    codes(2, 7),
+			tokenReplies: []reply{pending()},
+			// Polls at 2, 4 and 6; the deadline passes before the fourth.
+			wantPolls: 3,
+			wantErr:   ErrExpired,
+		},
+		"unexpected error": {
+			codeReply: codes(1, 900),
+			tokenReplies: []reply{
+				{status: http.StatusBadRequest, body: map[string]any{
+					"error": "incorrect_device_code", "error_description": "The device_code provided is not valid."}},
+			},
+			wantPolls: 1,
+			errMsg:    "login failed: incorrect_device_code The device_code provided is not valid.",
+		},
+		"no device code": {
+			codeReply: reply{status: http.StatusOK, body: map[string]any{
+				"error": "device_flow_disabled", "error_description": "Device Flow must be explicitly enabled."}},
+			errMsg: "failed to get a device code from",
+		},
+		"cancelled": {
+			codeReply:    codes(1, 900),
+			tokenReplies: []reply{pending()},
+			cancelAfter:  2,
+			wantErr:      context.Canceled,
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			fs := &fakeAuthServer{t: t, codeReply: tt.codeReply, tokenReplies: tt.tokenReplies}
+			srv := httptest.NewTLSServer(fs)
+			defer srv.Close()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelAfter > 0 {
+				go func() {
+					for fs.pollCount() < tt.cancelAfter {
+						time.Sleep(second / 4)
+					}
+					cancel()
+				}()
+			}
+			tok, err := GetAccessToken(ctx, &Params{
+				GhDomain: strings.TrimPrefix(srv.URL, "https://"),
+				ClientId: testClientId,
+				HttpCl:   srv.Client(),
+			})
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			case tt.errMsg != "":
+				assert.ErrorContains(t, err, tt.errMsg)
+			default:
+				assert.NoError(t, err)
+				if assert.NotNil(t, tok) {
+					assert.Equal(t, "gho_abc", tok.Value)
+					assert.Equal(t, "repo,read:org,user", tok.Scope)
+				}
+			}
+			if tt.cancelAfter == 0 {
+				assert.Equal(t, tt.wantPolls, fs.pollCount())
+			}
+		})
+	}
+}
+
+func TestGetAccessTokenSlowDown(t *testing.T) {
+	defer func(s time.Duration) { second = s }(second)
+	second = 4 * time.Millisecond
+
+	fs := &fakeAuthServer{t: t, codeReply: codes(1, 900), tokenReplies: []reply{
+		pending(),
+		{status: http.StatusBadRequest, body: map[string]any{"error": errSlowDown}},
+		pending(),
+		granted(),
+	}}
+	srv := httptest.NewTLSServer(fs)
+	defer srv.Close()
+	var out strings.Builder
+	_, err := GetAccessToken(context.Background(), &Params{
+		GhDomain: strings.TrimPrefix(srv.URL, "https://"),
+		ClientId: testClientId,
+		HttpCl:   srv.Client(),
+		Out:      &out,
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "visit  https://github.com/login/device  and enter this code:  ABCD-1234")
+	if assert.Len(t, fs.polls, 4) {
+		// The interval grows from 1 to 1+5 seconds after slow_down, and stays there.
+		assert.Less(t, fs.polls[1].Sub(fs.polls[0]), 5*second)
+		assert.GreaterOrEqual(t, fs.polls[2].Sub(fs.polls[1]), 6*second)
+		assert.GreaterOrEqual(t, fs.polls[3].Sub(fs.polls[2]), 6*second)
+	}
+}
+
+func TestGetAccessTokenBackoff(t *testing.T) {
+	defer func(s time.Duration) { second = s }(second)
+	second = 10 * time.Millisecond
+
+	// Polls 1, 2 and 4 get no response.
+	fs := &fakeAuthServer{t: t, codeReply: codes(1, 900), drop: []int{1, 2, 4},
+		tokenReplies: []reply{pending(), pending(), pending(), pending(), granted()}}
+	srv := httptest.NewTLSServer(fs)
+	defer srv.Close()
+	tok, err := GetAccessToken(context.Background(), &Params{
+		GhDomain: strings.TrimPrefix(srv.URL, "https://"),
+		ClientId: testClientId,
+		HttpCl:   srv.Client(),
+	})
+	assert.NoError(t, err)
+	if assert.NotNil(t, tok) {
+		assert.Equal(t, "gho_abc", tok.Value)
+	}
+	if assert.Len(t, fs.polls, 5) {
+		// The wait doubles from 1 second with each failure in a row...
+		assert.GreaterOrEqual(t, fs.polls[1].Sub(fs.polls[0]), 2*second)
+		assert.GreaterOrEqual(t, fs.polls[2].Sub(fs.polls[1]), 4*second)
+		// ...and goes back to the interval after a response.
+		assert.Less(t, fs.polls[3].Sub(fs.polls[2]), 2*second)
+		assert.GreaterOrEqual(t, fs.polls[4].Sub(fs.polls[3]), 2*second)
+		assert.Less(t, fs.polls[4].Sub(fs.polls[3]), 4*second)
+	}
+}
+
+func TestBackOff(t *testing.T) {
+	assert.Equal(t, 10*time.Second, backOff(5*time.Second, 5*time.Second))
+	assert.Equal(t, 40*time.Second, backOff(20*time.Second, 5*time.Second))
+	assert.Equal(t, time.Minute, backOff(40*time.Second, 5*time.Second))
+	assert.Equal(t, time.Minute, backOff(time.Minute, 5*time.Second))
+	// A server asking for long intervals is obliged.
+	assert.Equal(t, 90*time.Second, backOff(90*time.Second, 90*time.Second))
+}
+
+func TestComputeDuration(t *testing.T) {
+	assert.Equal(t, 7*time.Second, computeDuration(7, defaultIntervalSeconds))
+	assert.Equal(t, 5*time.Second, computeDuration(0, defaultIntervalSeconds))
+}
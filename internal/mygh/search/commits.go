@@ -1,11 +1,13 @@
 package search
 
 import (
-	"log"
+	"fmt"
+	"log/slog"
 	"sort"
 	"time"
 
 	"github.com/google/go-github/v52/github"
+	"github.com/monopole/snips/internal/progress"
 	"github.com/monopole/snips/internal/types"
 )
 
@@ -49,15 +51,19 @@ func (se *Engine) findPrsThenFindCommits(myUser *types.MyUser) (commits []*types
 
 	for repo, prList := range prsMerged {
 		for i := range prList {
-			var commitsForPr []*types.MyCommit
-			time.Sleep(prLookupWait)
-			commitsForPr, err = se.getCommitsForPr(repo, &prList[i])
-			if err == nil {
+			if err = se.pause(prLookupWait); err != nil {
+				return
+			}
+			commitsForPr, prErr := se.getCommitsForPr(repo, &prList[i])
+			if prErr == nil {
 				commits = append(commits, commitsForPr...)
-			} else {
-				log.Printf("    Trouble with user %s, pr %s", myUser.Login, prList[i].HtmlUrl)
-				log.Printf("    Error: %s", err.Error())
+				continue
+			}
+			if err = se.ctx.Err(); err != nil {
+				return
 			}
+			slog.Warn("trouble listing the commits of a pull request",
+				"user", myUser.Login, "pr", prList[i].HtmlUrl, "err", prErr)
 		}
 	}
 	return
@@ -70,6 +76,7 @@ func (se *Engine) getCommitsForPr(repo types.RepoId, prIssue *types.MyIssue) (re
 		resp         *github.Response
 		commits, lst []*github.RepositoryCommit
 	)
+	progress.Query(fmt.Sprintf("commits of %s/%s#%d", prIssue.RepoId.Org, prIssue.RepoId.Name, prIssue.Number))
 	opts := makeListOptions()
 	for {
 		lst, resp, err = se.client.PullRequests.ListCommits(
@@ -77,6 +84,7 @@ func (se *Engine) getCommitsForPr(repo types.RepoId, prIssue *types.MyIssue) (re
 		if err != nil {
 			return nil, err
 		}
+		notePage(resp)
 		commits = append(commits, lst...)
 		if resp.NextPage == 0 {
 			break
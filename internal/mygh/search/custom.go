@@ -0,0 +1,35 @@
+package search
+
+import (
+	"fmt"
+
+	"github.com/monopole/snips/internal/types"
+)
+
+// findCustomIssues runs the searches of the GitHub sections in the config file.
+// Each search is restricted to the day range by its date qualifier, as in
+// makeQuery, so the query itself needs only the user placeholder, e.g.
+//
+//	involves:{user} label:security
+func (se *Engine) findCustomIssues(login string) ([]types.CustomIssueSet, error) {
+	var result []types.CustomIssueSet
+	for i := range se.sections {
+		s := &se.sections[i]
+		lst, err := se.searchIssues(s.DateQualifier, "%s", s.Expand(
+			login,
+			se.dayRange.StartAsTime().Format(types.DayFormatGitHub),
+			se.dayRange.EndAsTime().Format(types.DayFormatGitHub)))
+		if err != nil {
+			return nil, fmt.Errorf("trouble with section %q; %w", s.Name, err)
+		}
+		m, err := makeMapOfRepoToIssueList(lst)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, types.CustomIssueSet{
+			Label:  s.Name,
+			Issues: &types.IssueSet{Domain: se.domain, Groups: m},
+		})
+	}
+	return result, nil
+}
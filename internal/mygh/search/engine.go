@@ -3,11 +3,12 @@ package search
 import (
 	"context"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"time"
 
 	"github.com/google/go-github/v52/github"
+	"github.com/monopole/snips/internal/config"
+	"github.com/monopole/snips/internal/progress"
 	"github.com/monopole/snips/internal/types"
 )
 
@@ -17,40 +18,79 @@ type Engine struct {
 	client   *github.Client
 	domain   string
 	dayRange *types.DayRange
+	// sections are extra searches from the config file.
+	sections []config.GitHubSection
 }
 
 // MakeEngine returns an instance of a GitHub search engine.
-func MakeEngine(ctx context.Context, cl *github.Client, d string) *Engine {
-	return &Engine{ctx: ctx, client: cl, domain: d}
+func MakeEngine(
+	ctx context.Context, cl *github.Client, d string, sections []config.GitHubSection) *Engine {
+	return &Engine{ctx: ctx, client: cl, domain: d, sections: sections}
 }
 
 const pauseApiUs = 15 * time.Second
 
 // LookupPeeps gathers data about the given usernames in the given day range.
+// If the engine's context ends first, it returns the users gathered so far,
+// and stubs for the rest, along with a types.StoppedError naming the rest.
 func (se *Engine) LookupPeeps(names []string, dayRange *types.DayRange) ([]*types.MyUser, error) {
 	var result []*types.MyUser
 	se.dayRange = dayRange
 	for i, n := range names {
+		progress.Task("github", n)
+		var err error
 		if i > 0 {
 			// Avoid hitting API rate limit.
-			time.Sleep(pauseApiUs)
+			progress.Query("pausing for the rate limit")
+			err = se.pause(pauseApiUs)
 		}
-		fmt.Fprintf(os.Stderr, "Working on user %s...\n", n)
-		if rec, err := se.doQueriesOnUser(n); err == nil {
-			result = append(result, rec)
-		} else {
-			log.Printf("trouble with user %s: %s\n", n, err.Error())
+		if err == nil {
+			slog.Info("searching github", "user", n)
+			var rec *types.MyUser
+			if rec, err = se.doQueriesOnUser(n); err == nil {
+				result = append(result, rec)
+				continue
+			}
 		}
+		if se.ctx.Err() != nil {
+			rest := makeStubs(names[i:])
+			return append(result, rest...), types.Stopped(se.ctx, err, rest)
+		}
+		slog.Error("github searches failed; leaving the user out", "user", n, "err", err)
 	}
 	return result, nil
 }
 
+// makeStubs returns users known only by the given names, so they appear
+// in the report even though nothing was found about them.
+func makeStubs(names []string) []*types.MyUser {
+	result := make([]*types.MyUser, len(names))
+	for i, n := range names {
+		result[i] = &types.MyUser{Name: n, Login: n}
+	}
+	return result
+}
+
+// pause waits for the given duration, or until the engine's context ends,
+// returning the context's error in the latter case.
+func (se *Engine) pause(d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-se.ctx.Done():
+		return se.ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
 // searchIssues uses the "search" endpoint, not the "issues" endpoint, because the goal is to
 // discover what the user has been doing with issues, rather than manage issues.
 // https://docs.github.com/en/rest/search?apiVersion=2022-11-28#search-issues-and-pull-requests
 // https://docs.github.com/en/rest/issues/issues?apiVersion=2022-11-28
 func (se *Engine) searchIssues(dateQualifier, qFmt string, args ...any) ([]*github.Issue, error) {
 	query := se.makeQuery(dateQualifier, qFmt, args)
+	progress.Query(query)
 	opts := makeSearchOptions()
 	var lst []*github.Issue
 	for {
@@ -58,6 +98,9 @@ func (se *Engine) searchIssues(dateQualifier, qFmt string, args ...any) ([]*gith
 		if err != nil {
 			return nil, err
 		}
+		notePage(resp)
+		slog.Debug("github issue search",
+			"query", query, "page", opts.Page, "issues", len(results.Issues), "total", results.GetTotal())
 		lst = append(lst, results.Issues...)
 		if resp.NextPage == 0 {
 			break
@@ -71,6 +114,7 @@ func (se *Engine) searchIssues(dateQualifier, qFmt string, args ...any) ([]*gith
 // It doesn't use https://docs.github.com/en/rest/commits/commits?apiVersion=2022-11-28
 func (se *Engine) searchCommits(dateQualifier, qFmt string, args ...any) ([]*github.CommitResult, error) {
 	query := se.makeQuery(dateQualifier, qFmt, args)
+	progress.Query(query)
 	opts := makeSearchOptions()
 	var lst []*github.CommitResult
 	for {
@@ -78,6 +122,9 @@ func (se *Engine) searchCommits(dateQualifier, qFmt string, args ...any) ([]*git
 		if err != nil {
 			return nil, err
 		}
+		notePage(resp)
+		slog.Debug("github commit search",
+			"query", query, "page", opts.Page, "commits", len(results.Commits), "total", results.GetTotal())
 		lst = append(lst, results.Commits...)
 		if resp.NextPage == 0 {
 			break
@@ -87,6 +134,14 @@ func (se *Engine) searchCommits(dateQualifier, qFmt string, args ...any) ([]*git
 	return lst, nil
 }
 
+// notePage notes a page of results, and the quota left, in the progress display.
+func notePage(resp *github.Response) {
+	progress.Page()
+	if resp != nil && resp.Rate.Limit > 0 {
+		progress.Quota(resp.Rate.Remaining, resp.Rate.Limit)
+	}
+}
+
 func (se *Engine) makeQuery(dateQualifier string, qFmt string, args []any) string {
 	return fmt.Sprintf(
 		"%s:%s..%s %s",
@@ -135,6 +190,9 @@ func (se *Engine) doQueriesOnUser(userName string) (*types.MyUser, error) {
 	if myUser.Commits, err = se.findCommits(myUser); err != nil {
 		return nil, err
 	}
+	if myUser.Custom, err = se.findCustomIssues(myUser.Login); err != nil {
+		return nil, err
+	}
 	return myUser, nil
 }
 
@@ -144,10 +202,11 @@ func (se *Engine) loadUserData(n string) (*types.MyUser, error) {
 		return nil, err
 	}
 	return &types.MyUser{
-		Name:    user.GetName(),
-		Company: user.GetCompany(),
-		Login:   user.GetLogin(),
-		Email:   user.GetEmail(),
+		Name:      user.GetName(),
+		Company:   user.GetCompany(),
+		Login:     user.GetLogin(),
+		Email:     user.GetEmail(),
+		AvatarUrl: user.GetAvatarURL(),
 	}, nil
 }
 
@@ -0,0 +1,47 @@
+package search
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v52/github"
+	"github.com/monopole/snips/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPause(t *testing.T) {
+	se := MakeEngine(context.Background(), nil, "github.com", nil)
+	assert.NoError(t, se.pause(time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	se = MakeEngine(ctx, nil, "github.com", nil)
+	start := time.Now()
+	assert.ErrorIs(t, se.pause(time.Hour), context.Canceled)
+	assert.Less(t, time.Since(start), time.Second)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	se = MakeEngine(ctx, nil, "github.com", nil)
+	assert.ErrorIs(t, se.pause(time.Hour), context.DeadlineExceeded)
+}
+
+func TestLookupPeepsStopped(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	cl, err := github.NewEnterpriseClient(srv.URL, srv.URL, srv.Client())
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	users, err := MakeEngine(ctx, cl, "github.com", nil).LookupPeeps([]string{"alice", "bob"}, nil)
+	var sErr *types.StoppedError
+	if assert.ErrorAs(t, err, &sErr) {
+		assert.Equal(t, []string{"alice", "bob"}, sErr.Logins)
+	}
+	assert.ErrorIs(t, err, context.Canceled)
+	// Everyone named is still in the report.
+	assert.Equal(t, []*types.MyUser{{Name: "alice", Login: "alice"}, {Name: "bob", Login: "bob"}}, users)
+}
@@ -55,6 +55,7 @@ func makeMapOfRepoToIssueList(issues []*github.Issue) (map[types.RepoId][]types.
 				Title:   x.GetTitle(),
 				HtmlUrl: x.GetHTMLURL(),
 				Updated: x.GetUpdatedAt().Time,
+				State:   x.GetState(),
 			}
 		}
 		result[id] = lst
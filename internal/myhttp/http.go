@@ -1,15 +1,18 @@
 package myhttp
 
 import (
+	"bytes"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/cookiejar"
 	"os"
-	"strings"
 	"time"
+
+	"github.com/monopole/snips/internal/pgmargs"
 )
 
 const (
@@ -19,14 +22,25 @@ const (
 	ContentTypeFormURLEncoded = "application/x-www-form-urlencoded"
 	ContentTypeJson           = "application/json"
 	Scheme                    = "https://"
+
+	// MaxErrBody limits how much of a failed response is quoted in an error.
+	MaxErrBody = 512
 )
 
-// MakeHttpClient returns client ready to make HTTP requests.
-// It's primed with certs loaded from the given caPath.
-// If no caPath provided, TLS will be unauthenticated.
-// The certs are used to establish that the servers are who they say they are.
-func MakeHttpClient(caPath string) (*http.Client, error) {
-	pool, err := loadCertPoolFromFile(caPath)
+// ErrBody returns the start of a failed response's body, trimmed,
+// for quoting in an error.
+func ErrBody(body io.Reader) []byte {
+	msg, _ := io.ReadAll(io.LimitReader(body, MaxErrBody))
+	return bytes.TrimSpace(msg)
+}
+
+// MakeHttpClient returns a client ready to make HTTP requests, shared
+// by everything snips talks to.  Servers are verified against the system's
+// root certs plus any in the args' CaPath, unless verification is
+// explicitly disabled.  If the args have a client cert, it's offered to
+// servers (e.g. corporate gateways) that ask for one.
+func MakeHttpClient(args *pgmargs.TlsArgs) (*http.Client, error) {
+	tlsConfig, err := makeTlsConfig(args)
 	if err != nil {
 		return nil, err
 	}
@@ -35,27 +49,30 @@ func MakeHttpClient(caPath string) (*http.Client, error) {
 		return nil, err
 	}
 	return &http.Client{
-		Transport: makeTransport(makeTlsConfig(pool)),
+		Transport: makeTransport(tlsConfig),
 		Timeout:   8 * time.Second,
-		// Don't automatically follow redirects; we want debug mode to expose redirect hops.
-		// CheckRedirect: func(req *http.Request, via []*http.Request) error {
-		//	return http.ErrUseLastResponse
-		// },
-		Jar: jar,
+		Jar:       jar,
 	}, nil
 }
 
-// loadCertPoolFromFile returns a pool containing the certs read from the given file.
-func loadCertPoolFromFile(path string) (*x509.CertPool, error) {
+// loadCertPool returns the system's cert pool, plus the certs read
+// from the given file, if any.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		// E.g. no system roots to be found; only the file's certs are trusted.
+		pool = x509.NewCertPool()
+	}
 	if path == "" {
-		return nil, nil
+		return pool, nil
 	}
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("unable to load certs from %q; %w", path, err)
 	}
-	pool := x509.NewCertPool()
-	pool.AppendCertsFromPEM(data)
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certs found in %q", path)
+	}
 	return pool, nil
 }
 
@@ -67,75 +84,32 @@ func makeTransport(tlsConfig *tls.Config) *http.Transport {
 	}
 }
 
-// makeTlsConfig returns a TLS config that uses a cert pool if provided, falling
-// back to no cert check if no cert pool provided.
-func makeTlsConfig(pool *x509.CertPool) *tls.Config {
-	if pool == nil {
-		return &tls.Config{
-			InsecureSkipVerify: true,
-		}
-	}
-	return &tls.Config{
-		RootCAs: pool,
-	}
-}
-
-type PrArgs struct {
-	Headers bool
-	Body    bool
-}
-
-// PrintRequest prints a request.
-func PrintRequest(r *http.Request, args PrArgs) error {
-	fmt.Printf("==== Request to %s\n", r.URL)
-	if args.Headers {
-		for k, v := range r.Header {
-			fmt.Printf("%50s : %s\n", k, v)
-		}
-	}
-	if args.Body {
-		const bodyDelim = "---------------------------------"
-		// Doing this makes the body unavailable elsewhere.
-		defer r.Body.Close()
-		b, err := io.ReadAll(r.Body)
+// makeTlsConfig returns a TLS config that verifies servers against the
+// system's certs plus those at the CaPath, unless told not to verify,
+// and presents the client cert, if any.
+func makeTlsConfig(args *pgmargs.TlsArgs) (*tls.Config, error) {
+	result := &tls.Config{MinVersion: tls.VersionTLS12}
+	if args.Insecure {
+		slog.Warn("TLS verification is disabled; servers aren't checked and tokens may be stolen")
+		result.InsecureSkipVerify = true
+	} else {
+		pool, err := loadCertPool(args.CaPath)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		fmt.Println(bodyDelim)
-		if body := strings.TrimSpace(string(b)); body != "" {
-			fmt.Println(body)
-		}
-		fmt.Println(bodyDelim)
-	}
-	return nil
-}
-
-// PrintResponse prints a response.
-func PrintResponse(r *http.Response, args PrArgs) error {
-	fmt.Printf("==== Response Code %d ==================\n", r.StatusCode)
-	if args.Headers {
-		printResponseHeaders(r)
+		result.RootCAs = pool
 	}
-	if args.Body {
-		const bodyDelim = "---------------------------------"
-		// Doing this makes the body unavailable elsewhere.
-		defer r.Body.Close()
-		b, err := io.ReadAll(r.Body)
-		if err != nil {
-			return err
+	if args.CertPath != "" {
+		keyPath := args.KeyPath
+		if keyPath == "" {
+			// The key may be in the same file as the cert.
+			keyPath = args.CertPath
 		}
-		fmt.Println(bodyDelim)
-		if body := strings.TrimSpace(string(b)); body != "" {
-			fmt.Println(body)
+		cert, err := tls.LoadX509KeyPair(args.CertPath, keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client cert %q; %w", args.CertPath, err)
 		}
-		fmt.Println(bodyDelim)
-	}
-	return nil
-}
-
-// printResponseHeaders prints response headers.
-func printResponseHeaders(r *http.Response) {
-	for k, v := range r.Header {
-		fmt.Printf("%50s : %s\n", k, v)
+		result.Certificates = []tls.Certificate{cert}
 	}
+	return result, nil
 }
@@ -0,0 +1,175 @@
+package myhttp_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/monopole/snips/internal/myhttp"
+	"github.com/monopole/snips/internal/pgmargs"
+	"github.com/stretchr/testify/assert"
+)
+
+// testPki is a CA, and a server cert and a client cert signed by it.
+type testPki struct {
+	ca         *x509.Certificate
+	server     tls.Certificate
+	caPath     string
+	certPath   string
+	keyPath    string
+	bundlePath string
+}
+
+func makeTestPki(t *testing.T) *testPki {
+	dir := t.TempDir()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "snips test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	assert.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDer)
+	assert.NoError(t, err)
+
+	sign := func(serial int64, usage x509.ExtKeyUsage) ([]byte, []byte, tls.Certificate) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NoError(t, err)
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "snips test"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		assert.NoError(t, err)
+		keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+		assert.NoError(t, err)
+		certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+		keyPem := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer})
+		pair, err := tls.X509KeyPair(certPem, keyPem)
+		assert.NoError(t, err)
+		return certPem, keyPem, pair
+	}
+	_, _, server := sign(2, x509.ExtKeyUsageServerAuth)
+	certPem, keyPem, _ := sign(3, x509.ExtKeyUsageClientAuth)
+
+	write := func(name string, data ...[]byte) string {
+		path := filepath.Join(dir, name)
+		var all []byte
+		for _, d := range data {
+			all = append(all, d...)
+		}
+		assert.NoError(t, os.WriteFile(path, all, 0o600))
+		return path
+	}
+	return &testPki{
+		ca:         ca,
+		server:     server,
+		caPath:     write("ca.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDer})),
+		certPath:   write("client.pem", certPem),
+		keyPath:    write("client-key.pem", keyPem),
+		bundlePath: write("client-bundle.pem", certPem, keyPem),
+	}
+}
+
+func TestMakeHttpClient(t *testing.T) {
+	pki := makeTestPki(t)
+	tests := map[string]struct {
+		args pgmargs.TlsArgs
+		// mTls means the server requires a client cert.
+		mTls      bool
+		makeErr   string
+		wantErrIn string
+	}{
+		"unknown ca": {
+			wantErrIn: "certificate signed by unknown authority",
+		},
+		"extra ca": {
+			args: pgmargs.TlsArgs{CaPath: pki.caPath},
+		},
+		"insecure": {
+			args: pgmargs.TlsArgs{Insecure: true},
+		},
+		"no client cert": {
+			args:      pgmargs.TlsArgs{CaPath: pki.caPath},
+			mTls:      true,
+			wantErrIn: "certificate required",
+		},
+		"client cert": {
+			args: pgmargs.TlsArgs{CaPath: pki.caPath, CertPath: pki.certPath, KeyPath: pki.keyPath},
+			mTls: true,
+		},
+		"client cert and key in one file": {
+			args: pgmargs.TlsArgs{CaPath: pki.caPath, CertPath: pki.bundlePath},
+			mTls: true,
+		},
+		"client cert without key": {
+			args:    pgmargs.TlsArgs{CaPath: pki.caPath, CertPath: pki.certPath},
+			makeErr: "unable to load client cert",
+		},
+		"ca file without certs": {
+			args:    pgmargs.TlsArgs{CaPath: pki.keyPath},
+			makeErr: "no PEM certs found",
+		},
+		"missing ca file": {
+			args:    pgmargs.TlsArgs{CaPath: filepath.Join(t.TempDir(), "nope.pem")},
+			makeErr: "unable to load certs",
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+			srv.TLS = &tls.Config{Certificates: []tls.Certificate{pki.server}}
+			if tt.mTls {
+				srv.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+				srv.TLS.ClientCAs = x509.NewCertPool()
+				srv.TLS.ClientCAs.AddCert(pki.ca)
+			}
+			srv.StartTLS()
+			defer srv.Close()
+
+			cl, err := MakeHttpClient(&tt.args)
+			if tt.makeErr != "" {
+				assert.ErrorContains(t, err, tt.makeErr)
+				return
+			}
+			assert.NoError(t, err)
+			resp, err := cl.Get(srv.URL)
+			if tt.wantErrIn != "" {
+				assert.ErrorContains(t, err, tt.wantErrIn)
+				return
+			}
+			if assert.NoError(t, err) {
+				resp.Body.Close()
+				assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package myhttp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// redacted replaces secrets in traces.
+	redacted = "REDACTED"
+	// maxTraceBody limits how much of a body is traced.
+	maxTraceBody = 16 * 1024
+)
+
+// secretHeaders are headers whose values are never traced.
+var secretHeaders = []string{
+	"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key",
+}
+
+// secretParams are query, form or JSON fields whose values are never traced.
+// sig is the signature authorizing posts to Teams (Power Automate) webhooks.
+var secretParams = []string{
+	"access_token", "refresh_token", "token", "device_code", "client_secret", "password", "sig",
+}
+
+var (
+	secretJson = regexp.MustCompile(
+		`("(?:` + strings.Join(secretParams, "|") + `)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	secretForm = regexp.MustCompile(
+		`(^|[&?])((?:` + strings.Join(secretParams, "|") + `)=)[^&\s]*`)
+)
+
+// tracer is a RoundTripper that writes a summary of each exchange
+// it passes on, with secrets redacted.
+type tracer struct {
+	base http.RoundTripper
+	// bodies means headers and bodies are traced too.
+	bodies bool
+	mu     sync.Mutex
+	w      io.Writer
+}
+
+type secretUrlKey struct{}
+
+// WithSecretUrl returns a context marking requests made with it as having
+// a URL that's itself a secret, e.g. a Slack webhook's, whose path is the
+// credential; traces of such requests show only the URL's scheme and host.
+func WithSecretUrl(ctx context.Context) context.Context {
+	return context.WithValue(ctx, secretUrlKey{}, true)
+}
+
+// Trace makes the client write a line per request to w, giving its
+// method, URL, status, latency and any rate limit headers, and if
+// bodies is true, the request and response headers and bodies.
+// Authorization headers and tokens are redacted.
+func Trace(cl *http.Client, w io.Writer, bodies bool) {
+	base := cl.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	cl.Transport = &tracer{base: base, bodies: bodies, w: w}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *tracer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if t.bodies && req.Body != nil && req.Body != http.NoBody {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		// RoundTrippers must close the request body, even on errors.
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		// Don't disturb the caller's request.
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	latency := time.Since(start).Round(time.Millisecond)
+
+	var b bytes.Buffer
+	if err != nil {
+		fmt.Fprintf(&b, "trace: %s %s failed after %s: %v\n", req.Method, traceUrl(req), latency, err)
+		t.write(&b)
+		return resp, err
+	}
+	fmt.Fprintf(&b, "trace: %s %s %d %s%s\n",
+		req.Method, traceUrl(req), resp.StatusCode, latency, rateLimits(resp.Header))
+	if t.bodies {
+		writeHeaders(&b, "> ", req.Header)
+		writeBody(&b, "> ", reqBody)
+		var respBody []byte
+		respBody, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(respBody))
+		writeHeaders(&b, "< ", resp.Header)
+		writeBody(&b, "< ", respBody)
+	}
+	t.write(&b)
+	return resp, nil
+}
+
+func (t *tracer) write(b *bytes.Buffer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, _ = t.w.Write(b.Bytes())
+}
+
+// rateLimits returns the rate limit headers, e.g. GitHub's
+// X-Ratelimit-Remaining, formatted for a trace line.
+func rateLimits(h http.Header) string {
+	var result []string
+	for k, v := range h {
+		lk := strings.ToLower(k)
+		if strings.Contains(lk, "ratelimit") || lk == "retry-after" {
+			result = append(result, k+"="+strings.Join(v, ","))
+		}
+	}
+	if len(result) == 0 {
+		return ""
+	}
+	slices.Sort(result)
+	return " " + strings.Join(result, " ")
+}
+
+func writeHeaders(b *bytes.Buffer, prefix string, h http.Header) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		v := strings.Join(h[k], ", ")
+		if slices.ContainsFunc(secretHeaders, func(s string) bool { return strings.EqualFold(s, k) }) {
+			v = redacted
+		}
+		fmt.Fprintf(b, "%s%s: %s\n", prefix, k, v)
+	}
+}
+
+func writeBody(b *bytes.Buffer, prefix string, body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	truncated := len(body) > maxTraceBody
+	if truncated {
+		body = body[:maxTraceBody]
+	}
+	for _, line := range strings.Split(strings.TrimSpace(redactBody(string(body))), "\n") {
+		fmt.Fprintf(b, "%s%s\n", prefix, line)
+	}
+	if truncated {
+		fmt.Fprintf(b, "%s...\n", prefix)
+	}
+}
+
+// redactBody hides the values of secret JSON or form fields.
+func redactBody(body string) string {
+	body = secretJson.ReplaceAllString(body, `$1"`+redacted+`"`)
+	return secretForm.ReplaceAllString(body, "${1}${2}"+redacted)
+}
+
+// traceUrl returns the request's URL as traced, redacted.
+func traceUrl(req *http.Request) string {
+	if secret, _ := req.Context().Value(secretUrlKey{}).(bool); secret {
+		return req.URL.Scheme + "://" + req.URL.Host + "/" + redacted
+	}
+	return redactUrl(req.URL)
+}
+
+// redactUrl returns the URL without its password or secret query parameters.
+func redactUrl(u *url.URL) string {
+	if u.RawQuery != "" {
+		q := u.Query()
+		hidden := false
+		for _, p := range secretParams {
+			if q.Has(p) {
+				q.Set(p, redacted)
+				hidden = true
+			}
+		}
+		if hidden {
+			c := *u
+			c.RawQuery = q.Encode()
+			u = &c
+		}
+	}
+	return u.Redacted()
+}
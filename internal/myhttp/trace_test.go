@@ -0,0 +1,164 @@
+package myhttp_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	. "github.com/monopole/snips/internal/myhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrace(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Ratelimit-Remaining", "4999")
+		w.Header().Set("Set-Cookie", "session=s3cr3t")
+		w.Header().Set(HeaderContentType, ContentTypeJson)
+		_, _ = io.WriteString(w, `{"access_token": "gho_s3cr3t", "scope": "repo"}`)
+	}))
+	defer srv.Close()
+
+	tests := map[string]struct {
+		bodies bool
+		want   []string
+	}{
+		"summary": {
+			want: []string{
+				`trace: POST http://127\.0\.0\.1:\d+/login\?access_token=REDACTED&page=2 200 \S+ X-Ratelimit-Remaining=4999`,
+			},
+		},
+		"bodies": {
+			bodies: true,
+			want: []string{
+				`trace: POST http://127\.0\.0\.1:\d+/login\?access_token=REDACTED&page=2 200 \S+ X-Ratelimit-Remaining=4999`,
+				`> Authorization: REDACTED`,
+				`> client_id=abc&device_code=REDACTED`,
+				`< Set-Cookie: REDACTED`,
+				`< X-Ratelimit-Remaining: 4999`,
+				`< \{"access_token": "REDACTED", "scope": "repo"\}`,
+			},
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			var out bytes.Buffer
+			cl := srv.Client()
+			Trace(cl, &out, tt.bodies)
+			req, err := http.NewRequest(http.MethodPost, srv.URL+"/login?page=2&access_token=gho_s3cr3t",
+				strings.NewReader("client_id=abc&device_code=s3cr3t"))
+			assert.NoError(t, err)
+			req.Header.Set(HeaderAAuthorization, "Bearer gho_s3cr3t")
+			resp, err := cl.Do(req)
+			assert.NoError(t, err)
+			defer resp.Body.Close()
+			// The caller still gets the whole response.
+			body, err := io.ReadAll(resp.Body)
+			assert.NoError(t, err)
+			assert.Contains(t, string(body), "gho_s3cr3t")
+
+			assert.NotContains(t, out.String(), "s3cr3t")
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			for _, w := range tt.want {
+				assert.True(t, slicesAnyMatch(lines, w), "no line matching %s in\n%s", w, out.String())
+			}
+			if !tt.bodies {
+				assert.Len(t, lines, 1)
+			}
+		})
+	}
+}
+
+func TestTraceError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	var out bytes.Buffer
+	cl := &http.Client{}
+	Trace(cl, &out, false)
+	_, err := cl.Get(srv.URL)
+	assert.Error(t, err)
+	assert.Regexp(t, `^trace: GET http://127\.0\.0\.1:\d+ failed after \S+: `, out.String())
+}
+
+// roundTripFunc is a RoundTripper answering every request itself.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTraceSecretUrls(t *testing.T) {
+	tests := map[string]struct {
+		loc    string
+		secret bool
+		want   string
+	}{
+		"slack webhook": {
+			loc:    "https://hooks.slack.com/services/T0000/B0000/s3cr3t",
+			secret: true,
+			want:   "trace: POST https://hooks.slack.com/REDACTED 200 ",
+		},
+		"teams webhook signature": {
+			loc:  "https://prod.westus.logic.azure.com/workflows/1/triggers/manual/paths/invoke?api-version=1&sig=s3cr3t",
+			want: "trace: POST https://prod.westus.logic.azure.com/workflows/1/triggers/manual/paths/invoke?api-version=1&sig=REDACTED 200 ",
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			var out bytes.Buffer
+			cl := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+			})}
+			Trace(cl, &out, true)
+			ctx := context.Background()
+			if tt.secret {
+				ctx = WithSecretUrl(ctx)
+			}
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, tt.loc, strings.NewReader(`{"text": "hi"}`))
+			assert.NoError(t, err)
+			resp, err := cl.Do(req)
+			assert.NoError(t, err)
+			resp.Body.Close()
+			assert.True(t, strings.HasPrefix(out.String(), tt.want), out.String())
+			assert.NotContains(t, out.String(), "s3cr3t")
+		})
+	}
+}
+
+// failingBody fails to read, and notes being closed.
+type failingBody struct {
+	closed bool
+}
+
+func (b *failingBody) Read([]byte) (int, error) { return 0, io.ErrUnexpectedEOF }
+
+func (b *failingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestTraceBodyReadError(t *testing.T) {
+	body := &failingBody{}
+	cl := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: body, Request: req}, nil
+	})}
+	var out bytes.Buffer
+	Trace(cl, &out, true)
+	_, err := cl.Get("https://example.com/")
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.True(t, body.closed)
+}
+
+func slicesAnyMatch(lines []string, pattern string) bool {
+	re := regexp.MustCompile("^" + pattern + "$")
+	for _, l := range lines {
+		if re.MatchString(l) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package myjira
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// richText is a text field that Jira Data Center (api v2) sends as a string,
+// and Jira Cloud (api v3) sends as an Atlassian Document Format (ADF) tree.
+// Either way, it's held as plain text.
+// https://developer.atlassian.com/cloud/jira/platform/apis/document/structure/
+type richText string
+
+type adfNode struct {
+	Type    string    `json:"type"`
+	Text    string    `json:"text,omitempty"`
+	Content []adfNode `json:"content,omitempty"`
+	Attrs   struct {
+		Text string `json:"text,omitempty"`
+	} `json:"attrs"`
+}
+
+func (rt *richText) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*rt = richText(s)
+		return nil
+	}
+	var doc adfNode
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	var b strings.Builder
+	doc.writeText(&b)
+	*rt = richText(strings.TrimSpace(b.String()))
+	return nil
+}
+
+// writeText writes the node's text, separating blocks with newlines.
+func (n *adfNode) writeText(b *strings.Builder) {
+	switch n.Type {
+	case "text":
+		b.WriteString(n.Text)
+	case "hardBreak":
+		b.WriteString("\n")
+	case "mention", "emoji":
+		b.WriteString(n.Attrs.Text)
+	}
+	for i := range n.Content {
+		n.Content[i].writeText(b)
+	}
+	switch n.Type {
+	case "paragraph", "heading", "codeBlock", "blockquote", "listItem", "rule":
+		b.WriteString("\n")
+	}
+}
@@ -0,0 +1,48 @@
+package myjira
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	// https://docs.atlassian.com/software/jira/docs/api/REST/9.4.0/#api/2/myself-getUser
+	// https://developer.atlassian.com/cloud/jira/platform/rest/v3/api-group-myself/#api-rest-api-3-myself-get
+	myselfEndpointDataCenter = "rest/api/2/myself"
+	myselfEndpointCloud      = "rest/api/3/myself"
+)
+
+// ErrBadToken means jira rejected the credentials.
+var ErrBadToken = errors.New("jira rejected the token")
+
+// CheckToken makes a cheap authenticated call, to learn whether the
+// credentials work before starting searches that would fail later.
+func (jb *jiraBoss) CheckToken() error {
+	endpoint := myselfEndpointDataCenter
+	if jb.args.Cloud {
+		endpoint = myselfEndpointCloud
+	}
+	loc, err := jb.makeUrl(endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(jb.ctx, http.MethodGet, loc.String(), nil)
+	if err != nil {
+		return err
+	}
+	jb.authorize(req)
+	resp, err := jb.htCl.Do(req)
+	if err != nil {
+		return fmt.Errorf("trouble reaching jira at %s; %w", jb.args.Domain, err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w for %s (status code %d); it may be expired or revoked",
+			ErrBadToken, jb.args.Domain, resp.StatusCode)
+	}
+	return fmt.Errorf("status code %d from GET %s", resp.StatusCode, loc.Path)
+}
@@ -0,0 +1,20 @@
+package myjira
+
+import (
+	"fmt"
+
+	"github.com/monopole/snips/internal/types"
+)
+
+// makeIssuesUpdatedByJql finds issues the user updated in any way, including
+// by commenting.  It's a superset of the issues the user commented on.
+// https://support.atlassian.com/jira-software-cloud/docs/jql-functions/#updatedBy--
+func makeIssuesUpdatedByJql(accountId string, dayRange *types.DayRange) string {
+	return fmt.Sprintf(
+		"creator != %q and issue in updatedBy(%q, '%s', '%s')",
+		accountId,
+		accountId,
+		dayRange.StartAsTime().Format(types.DayFormatJira),
+		adjustEndDate(dayRange.EndAsTime()).Format(types.DayFormatJira),
+	)
+}
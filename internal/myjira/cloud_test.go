@@ -0,0 +1,311 @@
+package myjira
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monopole/snips/internal/pgmargs"
+	"github.com/monopole/snips/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	bobAccountId = "712020:bob"
+	bobEmail     = "bob@acme.com"
+)
+
+// fakeCloud stands in for a Jira Cloud instance.
+type fakeCloud struct {
+	t *testing.T
+	// searches holds the jql of each search request received.
+	searches []string
+}
+
+func (fc *fakeCloud) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, pass, ok := r.BasicAuth()
+	if !ok || name != bobEmail || pass != "sekret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case r.URL.Path == "/"+myselfEndpointCloud:
+		fc.write(w, user{AccountId: bobAccountId, EmailAddress: bobEmail})
+	case r.URL.Path == "/"+userSearchEndpointCloud:
+		if r.URL.Query().Get("query") != bobEmail {
+			fc.write(w, []user{})
+			return
+		}
+		fc.write(w, []user{
+			{AccountId: "557058:app", DisplayName: "Automation", AccountType: "app"},
+			{
+				AccountId:    bobAccountId,
+				DisplayName:  "Bob Bobface",
+				EmailAddress: bobEmail,
+				AccountType:  "atlassian",
+				Active:       true,
+				AvatarUrls:   map[string]string{"48x48": "https://avatar/bob.png"},
+			},
+		})
+	case r.URL.Path == "/"+searchJqlEndpoint:
+		assert.Equal(fc.t, http.MethodPost, r.Method)
+		var req jqlSearchRequest
+		assert.NoError(fc.t, json.NewDecoder(r.Body).Decode(&req))
+		fc.searches = append(fc.searches, req.Jql)
+		fc.write(w, fc.search(&req))
+	case r.URL.Path == "/rest/api/3/issue/MSFT-3/comment":
+		assert.Equal(fc.t, "renderedBody", r.URL.Query().Get("expand"))
+		fc.write(w, commentPage{Total: 2, Comments: []comment{
+			{Author: user{AccountId: "someoneElse"}, Created: "2023-06-08T10:00:00.000-0700"},
+			{
+				Id:           "31",
+				Author:       user{AccountId: bobAccountId},
+				Created:      "2023-06-09T10:00:00.000-0700",
+				Body:         "Fetched later",
+				RenderedBody: "<p>Fetched &lt;later&gt;</p>",
+			},
+		}})
+	case r.URL.Path == "/rest/api/3/issue/MSFT-9/changelog":
+		fc.write(w, changelogPage{Total: 1, IsLast: true, Values: []history{
+			{Author: user{AccountId: bobAccountId}, Created: "2023-06-09T10:00:00.000-0700", Items: []changeItem{
+				{Field: "status", FromString: "To Do", ToString: "In Progress"},
+			}},
+		}})
+	case r.URL.Path == "/rest/api/3/issue/MSFT-7/worklog":
+		fc.write(w, worklogPage{Total: 2, Worklogs: []worklog{
+			{Author: user{AccountId: bobAccountId}, Started: "2023-06-09T10:00:00.000-0700", TimeSpentSeconds: 1800},
+			{Author: user{AccountId: bobAccountId}, Started: "2023-06-10T10:00:00.000-0700", TimeSpentSeconds: 3600},
+		}})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (fc *fakeCloud) write(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	assert.NoError(fc.t, json.NewEncoder(w).Encode(v))
+}
+
+func makeRecord(num int, comments ...comment) issueRecord {
+	rec := issueRecord{
+		Id:  fmt.Sprintf("1000%d", num),
+		Key: fmt.Sprintf("MSFT-%d", num),
+		Fields: issueDetails{
+			Summary: fmt.Sprintf("issue %d", num),
+			Project: project{Key: "MSFT", Name: "microsoft developers"},
+			Updated: "2023-06-08T13:47:00.000-0700",
+			Status:  status{Name: "Done"},
+		},
+	}
+	if comments != nil {
+		rec.Fields.Comment = &commentPage{Total: len(comments), Comments: comments}
+	}
+	return rec
+}
+
+func (fc *fakeCloud) search(req *jqlSearchRequest) *jqlSearchResponse {
+	switch {
+	case strings.HasPrefix(req.Jql, "creator = "):
+		// Two pages.
+		if req.NextPageToken == "" {
+			return &jqlSearchResponse{Issues: []issueRecord{makeRecord(1)}, NextPageToken: "page2"}
+		}
+		return &jqlSearchResponse{Issues: []issueRecord{makeRecord(2)}, IsLast: true}
+	case strings.Contains(req.Jql, "updatedBy"):
+		assert.Contains(fc.t, req.Fields, "comment")
+		inRange := comment{
+			Id: "41", Author: user{AccountId: bobAccountId}, Created: "2023-06-08T10:00:00.000-0700", Body: "plain"}
+		tooLate := comment{Author: user{AccountId: bobAccountId}, Created: "2023-07-08T10:00:00.000-0700"}
+		notBob := comment{Author: user{AccountId: "someoneElse"}, Created: "2023-06-08T10:00:00.000-0700"}
+		truncated := makeRecord(3)
+		// Claims more comments than it holds, forcing a fetch.
+		truncated.Fields.Comment = &commentPage{Total: 2, Comments: []comment{notBob}}
+		withRendered := makeRecord(4, inRange)
+		withRendered.RenderedFields = &renderedDetails{Comment: &commentPage{Comments: []comment{
+			{Id: "41", Body: "\n<p>Looks <b>good</b> &amp; ships</p><p>Second line</p>"},
+		}}}
+		return &jqlSearchResponse{IsLast: true, Issues: []issueRecord{
+			withRendered,
+			makeRecord(5, tooLate, notBob),
+			truncated,
+		}}
+	case strings.HasPrefix(req.Jql, "status CHANGED BY"):
+		assert.Contains(fc.t, req.Expand, "changelog")
+		rec := makeRecord(8)
+		rec.Changelog = &changelog{Total: 3, Histories: []history{
+			{Author: user{AccountId: bobAccountId}, Created: "2023-06-08T10:00:00.000-0700", Items: []changeItem{
+				{Field: "assignee", ToString: "Bob"},
+				{Field: "status", FromString: "In Progress", ToString: "Done"},
+			}},
+			{Author: user{AccountId: "someoneElse"}, Created: "2023-06-07T10:00:00.000-0700", Items: []changeItem{
+				{Field: "status", FromString: "Open", ToString: "In Progress"},
+			}},
+			{Author: user{AccountId: bobAccountId}, Created: "2023-05-07T10:00:00.000-0700", Items: []changeItem{
+				{Field: "status", FromString: "In Progress", ToString: "Closed"},
+			}},
+		}}
+		truncated := makeRecord(9)
+		truncated.Changelog = &changelog{Total: 1}
+		return &jqlSearchResponse{IsLast: true, Issues: []issueRecord{rec, truncated}}
+	case strings.HasPrefix(req.Jql, "worklogAuthor"):
+		assert.Contains(fc.t, req.Fields, "worklog")
+		rec := makeRecord(6)
+		rec.Fields.Worklog = &worklogPage{Total: 3, Worklogs: []worklog{
+			{Author: user{AccountId: bobAccountId}, Started: "2023-06-08T10:00:00.000-0700", TimeSpentSeconds: 7200},
+			{Author: user{AccountId: bobAccountId}, Started: "2023-07-08T10:00:00.000-0700", TimeSpentSeconds: 7200},
+			{Author: user{AccountId: "someoneElse"}, Started: "2023-06-08T10:00:00.000-0700", TimeSpentSeconds: 7200},
+		}}
+		truncated := makeRecord(7)
+		truncated.Fields.Worklog = &worklogPage{Total: 2}
+		return &jqlSearchResponse{IsLast: true, Issues: []issueRecord{rec, truncated}}
+	}
+	return &jqlSearchResponse{IsLast: true}
+}
+
+func Test_DoSearchCloud(t *testing.T) {
+	fc := &fakeCloud{t: t}
+	srv := httptest.NewTLSServer(fc)
+	defer srv.Close()
+	dr, err := types.MakeDayRange("2023-06-01", "2023-06-30", 0)
+	assert.NoError(t, err)
+	args := &pgmargs.JiraArgs{
+		ServiceArgs: pgmargs.ServiceArgs{
+			Domain: strings.TrimPrefix(srv.URL, "https://"),
+			Token:  "sekret",
+		},
+		Email:     bobEmail,
+		Cloud:     true,
+		PageSize:  pgmargs.DefaultJiraPageSize,
+		MaxIssues: pgmargs.DefaultJiraMaxIssues,
+	}
+	u := &types.MyUser{Login: "bob", Name: "bob", Email: bobEmail}
+	ghost := &types.MyUser{Login: "ghost"}
+	jb := MakeJiraBoss(context.Background(), srv.Client(), args, dr)
+	assert.NoError(t, jb.DoSearch([]*types.MyUser{u, ghost}))
+
+	assert.Equal(t, "Bob Bobface", u.Name)
+	assert.Equal(t, "https://avatar/bob.png", u.AvatarUrl)
+	assert.Equal(t, types.JiraAccountActive, u.JiraAccount)
+	assert.Equal(t, types.JiraAccountUnknown, ghost.JiraAccount)
+	assert.Nil(t, ghost.IssuesCreated)
+	assert.Equal(t, []string{`unknown jira user "ghost"; skipping jira searches for ghost`}, jb.Warnings())
+
+	// Two pages of created issues, then status changes, updatedBy and worklogAuthor.
+	assert.Equal(t, 5, len(fc.searches))
+	for _, jql := range fc.searches {
+		assert.Contains(t, jql, `"`+bobAccountId+`"`)
+		assert.NotContains(t, jql, "issuefunction")
+	}
+	msft := types.RepoId{Org: "microsoft developers", Name: "MSFT"}
+	assert.Equal(t, 2, u.IssuesCreated.Count())
+	assert.Equal(t, "Done", u.IssuesCreated.Groups[msft][0].State)
+	assert.Equal(t, "https://"+args.Domain+"/browse/MSFT-1", u.IssuesCreated.Groups[msft][0].HtmlUrl)
+	commented := make(map[int][]types.MyComment)
+	for _, issue := range u.IssuesCommented.Groups[msft] {
+		commented[issue.Number] = issue.Comments
+	}
+	assert.Len(t, commented, 2)
+	if assert.Len(t, commented[3], 1) {
+		assert.Equal(t, "Fetched <later>", commented[3][0].FirstLine)
+		assert.Equal(t, "https://"+args.Domain+"/browse/MSFT-3?focusedCommentId=31", commented[3][0].Url)
+	}
+	if assert.Len(t, commented[4], 1) {
+		assert.Equal(t, "Looks good & ships", commented[4][0].FirstLine)
+		assert.Equal(t, 8, commented[4][0].When.Day())
+	}
+	if assert.Len(t, u.Transitions.Groups[msft], 2) {
+		// Most recent first.
+		assert.Equal(t, 9, u.Transitions.Groups[msft][0].Issue.Number)
+		assert.Equal(t, "In Progress", u.Transitions.Groups[msft][0].To)
+		assert.Equal(t, "Done", u.Transitions.Groups[msft][1].To)
+	}
+	if assert.Len(t, u.IssuesClosed.Groups[msft], 1) {
+		assert.Equal(t, 8, u.IssuesClosed.Groups[msft][0].Number)
+	}
+	assert.Equal(t, 3*time.Hour+30*time.Minute, u.TimeLogged.Total())
+	if assert.Len(t, u.TimeLogged.Groups[msft], 2) {
+		assert.Equal(t, 6, u.TimeLogged.Groups[msft][0].Issue.Number)
+		assert.Equal(t, 2*time.Hour, u.TimeLogged.Groups[msft][0].Spent)
+	}
+}
+
+func Test_DoSearchCloudBadCredentials(t *testing.T) {
+	srv := httptest.NewTLSServer(&fakeCloud{t: t})
+	defer srv.Close()
+	dr, _ := types.MakeDayRange("2023-06-01", "2023-06-30", 0)
+	args := &pgmargs.JiraArgs{
+		ServiceArgs: pgmargs.ServiceArgs{Domain: strings.TrimPrefix(srv.URL, "https://"), Token: "wrong"},
+		Email:       bobEmail,
+		Cloud:       true,
+		PageSize:    pgmargs.DefaultJiraPageSize,
+		MaxIssues:   pgmargs.DefaultJiraMaxIssues,
+	}
+	err := MakeJiraBoss(context.Background(), srv.Client(), args, dr).DoSearch([]*types.MyUser{{Login: "bob"}})
+	assert.ErrorContains(t, err, "status code 401")
+}
+
+func Test_CheckTokenCloud(t *testing.T) {
+	srv := httptest.NewTLSServer(&fakeCloud{t: t})
+	defer srv.Close()
+	tests := map[string]struct {
+		token string
+		err   error
+	}{
+		"good": {token: "sekret"},
+		"bad":  {token: "wrong", err: ErrBadToken},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			args := &pgmargs.JiraArgs{
+				ServiceArgs: pgmargs.ServiceArgs{Domain: strings.TrimPrefix(srv.URL, "https://"), Token: tt.token},
+				Email:       bobEmail,
+				Cloud:       true,
+			}
+			err := MakeJiraBoss(context.Background(), srv.Client(), args, nil).CheckToken()
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_richText(t *testing.T) {
+	tests := map[string]struct {
+		raw  string
+		want string
+	}{
+		"v2 string": {
+			raw:  `"plain old text"`,
+			want: "plain old text",
+		},
+		"v3 adf": {
+			raw: `{"type":"doc","version":1,"content":[
+  {"type":"paragraph","content":[
+    {"type":"text","text":"Hello "},
+    {"type":"mention","attrs":{"id":"x","text":"@Bob"}},
+    {"type":"hardBreak"},
+    {"type":"text","text":"second line","marks":[{"type":"strong"}]}]},
+  {"type":"bulletList","content":[
+    {"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"item"}]}]}]}]}`,
+			want: "Hello @Bob\nsecond line\nitem",
+		},
+		"null": {
+			raw:  `null`,
+			want: "",
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			var rt richText
+			assert.NoError(t, json.Unmarshal([]byte(tt.raw), &rt))
+			assert.Equal(t, tt.want, string(rt))
+		})
+	}
+}
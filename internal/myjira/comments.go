@@ -0,0 +1,135 @@
+package myjira
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monopole/snips/internal/myhttp"
+	"github.com/monopole/snips/internal/types"
+)
+
+const (
+	// https://docs.atlassian.com/software/jira/docs/api/REST/9.4.0/#api/2/issue/{issueIdOrKey}/comment-getComments
+	// https://developer.atlassian.com/cloud/jira/platform/rest/v3/api-group-issue-comments/#api-rest-api-3-issue-issueidorkey-comment-get
+	commentEndpointFmtDataCenter = "rest/api/2/issue/%s/comment"
+	commentEndpointFmtCloud      = "rest/api/3/issue/%s/comment"
+
+	maxCommentResult = 100
+
+	// maxFirstLineLen caps the length of a comment's first line in the report.
+	maxFirstLineLen = 120
+)
+
+// findIssuesCommented finds the issues the user commented on in the day
+// range, along with those comments, among the issues matching the jql.
+func (jb *jiraBoss) findIssuesCommented(jql string, who string) (*types.IssueSet, error) {
+	candidates, err := jb.searchIssues(jql, jb.issueFields("comment"), searchExpand)
+	if err != nil {
+		return nil, err
+	}
+	var commented []issueRecord
+	for i := range candidates {
+		rec := &candidates[i]
+		var comments []comment
+		if comments, err = jb.allComments(rec); err != nil {
+			return nil, err
+		}
+		if rec.userComments = jb.userComments(rec, comments, who); len(rec.userComments) > 0 {
+			commented = append(commented, *rec)
+		}
+	}
+	return jb.makeIssueSet(commented)
+}
+
+// allComments returns the comments that came with the issue, fetching the rest
+// if the search response held only the first page of them.
+func (jb *jiraBoss) allComments(rec *issueRecord) ([]comment, error) {
+	page := rec.Fields.Comment
+	if page != nil && len(page.Comments) >= page.Total {
+		return page.Comments, nil
+	}
+	endpointFmt := commentEndpointFmtDataCenter
+	if jb.args.Cloud {
+		endpointFmt = commentEndpointFmtCloud
+	}
+	var result []comment
+	for startAt := 0; ; {
+		loc, err := jb.makeUrl(fmt.Sprintf(endpointFmt, rec.Key), url.Values{
+			"startAt":    {strconv.Itoa(startAt)},
+			"maxResults": {strconv.Itoa(maxCommentResult)},
+			"expand":     {"renderedBody"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		var resp commentPage
+		if err = jb.doJiraRequest(http.MethodGet, loc, nil, &resp); err != nil {
+			return nil, err
+		}
+		result = append(result, resp.Comments...)
+		startAt += len(resp.Comments)
+		if len(resp.Comments) == 0 || startAt >= resp.Total {
+			return result, nil
+		}
+	}
+}
+
+// userComments returns the user's comments in the day range, oldest first.
+func (jb *jiraBoss) userComments(rec *issueRecord, comments []comment, who string) []types.MyComment {
+	rendered := make(map[string]string)
+	if rec.RenderedFields != nil && rec.RenderedFields.Comment != nil {
+		for _, c := range rec.RenderedFields.Comment.Comments {
+			rendered[c.Id] = string(c.Body)
+		}
+	}
+	var result []types.MyComment
+	for _, c := range comments {
+		if !jb.isUser(c.Author, who) {
+			continue
+		}
+		when, err := time.Parse(types.DateFormatJiraIssue, c.Created)
+		if err != nil || !jb.dayRange.Contains(when) {
+			continue
+		}
+		line := firstLine(string(c.Body))
+		if h, ok := rendered[c.Id]; ok {
+			line = firstLineOfHtml(h)
+		} else if c.RenderedBody != "" {
+			line = firstLineOfHtml(c.RenderedBody)
+		}
+		result = append(result, types.MyComment{
+			Url: myhttp.Scheme + jb.args.Domain + "/browse/" + rec.Key +
+				"?focusedCommentId=" + url.QueryEscape(c.Id),
+			When:      when,
+			FirstLine: line,
+		})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].When.Before(result[j].When)
+	})
+	return result
+}
+
+var (
+	// htmlBreak matches the tags that end a line of rendered text.
+	htmlBreak = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|h[1-6]|li|pre|blockquote|tr)>`)
+	htmlTag   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// firstLineOfHtml returns the first line of the text of a rendered field.
+func firstLineOfHtml(s string) string {
+	s = htmlBreak.ReplaceAllString(s, "\n")
+	return firstLine(html.UnescapeString(htmlTag.ReplaceAllString(s, "")))
+}
+
+// firstLine returns the first non-blank line of s, shortened if long.
+func firstLine(s string) string {
+	return types.Clip(strings.Join(strings.Fields(types.FirstLine(s)), " "), maxFirstLineLen)
+}
@@ -0,0 +1,38 @@
+package myjira
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_firstLineOfHtml(t *testing.T) {
+	tests := map[string]struct {
+		html string
+		want string
+	}{
+		"empty": {},
+		"paragraphs": {
+			html: "<p>First <em>one</em></p>\n<p>Second</p>",
+			want: "First one",
+		},
+		"break": {
+			html: "<p>  <br/>after a &quot;break&quot;<br>more</p>",
+			want: `after a "break"`,
+		},
+		"mention": {
+			html: `<p><a href="/secure/ViewProfile.jspa?name=bob" class="user-hover">Bob</a> please look</p>`,
+			want: "Bob please look",
+		},
+		"long": {
+			html: "<p>" + strings.Repeat("x", 200) + "</p>",
+			want: strings.Repeat("x", maxFirstLineLen-1) + "…",
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			assert.Equal(t, tt.want, firstLineOfHtml(tt.html))
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package myjira
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+const (
+	// fieldParent is the parent of an issue; for stories on Cloud, it's the epic.
+	// https://community.developer.atlassian.com/t/deprecation-of-the-epic-link-parent-link-and-other-related-fields-in-rest-apis-and-webhooks/54048
+	fieldParent = "parent"
+
+	issueTypeEpic = "Epic"
+	// epicHierarchyLevel is the hierarchyLevel of epics; stories are 0, sub-tasks -1.
+	epicHierarchyLevel = 1
+)
+
+type issueType struct {
+	Name           string `json:"name,omitempty"`
+	HierarchyLevel int    `json:"hierarchyLevel,omitempty"`
+}
+
+// parentIssue is the parent field of an issue.
+type parentIssue struct {
+	Key    string `json:"key,omitempty"`
+	Fields struct {
+		Summary   string    `json:"summary,omitempty"`
+		IssueType issueType `json:"issuetype"`
+	} `json:"fields"`
+}
+
+func (p *parentIssue) isEpic() bool {
+	return p.Fields.IssueType.HierarchyLevel == epicHierarchyLevel ||
+		strings.EqualFold(p.Fields.IssueType.Name, issueTypeEpic)
+}
+
+// sprint is an element of the sprint custom field, as served
+// by Cloud and recent versions of Data Center.
+type sprint struct {
+	Id    int    `json:"id"`
+	Name  string `json:"name"`
+	State string `json:"state,omitempty"`
+}
+
+// UnmarshalJSON keeps the raw fields of the issue, since the
+// ids of custom fields (e.g. sprint) vary between instances.
+func (rec *issueRecord) UnmarshalJSON(data []byte) error {
+	type plain issueRecord
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	var raw struct {
+		Fields map[string]json.RawMessage `json:"fields"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*rec = issueRecord(p)
+	rec.rawFields = raw.Fields
+	return nil
+}
+
+// epic returns the key and, if known, the summary of the issue's epic.
+func (rec *issueRecord) epic(epicLinkField string) (key string, title string) {
+	if raw, ok := rec.rawFields[epicLinkField]; ok && epicLinkField != "" {
+		if json.Unmarshal(raw, &key) == nil && key != "" {
+			return key, ""
+		}
+	}
+	var p parentIssue
+	if raw, ok := rec.rawFields[fieldParent]; ok && json.Unmarshal(raw, &p) == nil && p.isEpic() {
+		return p.Key, p.Fields.Summary
+	}
+	return "", ""
+}
+
+// legacySprintName finds the name in the string form of a sprint served
+// by old versions of Data Center, e.g.
+// com.atlassian.greenhopper.service.sprint.Sprint@1f39[id=12,rapidViewId=3,state=CLOSED,name=Sprint 7,startDate=...]
+var legacySprintName = regexp.MustCompile(`[\[,]name=([^,\]]*)`)
+
+// sprints returns the names of the sprints in the given sprint field.
+func (rec *issueRecord) sprints(sprintField string) []string {
+	raw, ok := rec.rawFields[sprintField]
+	if !ok || sprintField == "" {
+		return nil
+	}
+	var result []string
+	var objs []sprint
+	if json.Unmarshal(raw, &objs) == nil {
+		for _, s := range objs {
+			result = append(result, s.Name)
+		}
+		return result
+	}
+	var legacy []string
+	if json.Unmarshal(raw, &legacy) == nil {
+		for _, s := range legacy {
+			if m := legacySprintName.FindStringSubmatch(s); m != nil {
+				result = append(result, m[1])
+			}
+		}
+	}
+	return result
+}
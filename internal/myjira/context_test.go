@@ -0,0 +1,86 @@
+package myjira
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/monopole/snips/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_issueRecordContext(t *testing.T) {
+	tests := map[string]struct {
+		fields    string
+		epicKey   string
+		epicTitle string
+		sprints   []string
+	}{
+		"nothing": {
+			fields: `{"summary": "hey"}`,
+		},
+		"cloud parent epic": {
+			fields: `{"parent": {"key": "MSFT-100", "fields": {
+  "summary": "Big thing", "issuetype": {"name": "Epic", "hierarchyLevel": 1}}},
+  "customfield_10020": [{"id": 3, "name": "Sprint 3", "state": "closed"}, {"id": 4, "name": "Sprint 4"}]}`,
+			epicKey:   "MSFT-100",
+			epicTitle: "Big thing",
+			sprints:   []string{"Sprint 3", "Sprint 4"},
+		},
+		"renamed epic type": {
+			fields: `{"parent": {"key": "MSFT-100", "fields": {
+  "summary": "Big thing", "issuetype": {"name": "Initiative", "hierarchyLevel": 1}}}}`,
+			epicKey:   "MSFT-100",
+			epicTitle: "Big thing",
+		},
+		"sub-task parent is not an epic": {
+			fields: `{"parent": {"key": "MSFT-7", "fields": {
+  "summary": "A story", "issuetype": {"name": "Story", "hierarchyLevel": 0}}}}`,
+		},
+		"data center epic link": {
+			fields: `{"customfield_10014": "MSFT-200",
+  "customfield_10020": ["com.atlassian.greenhopper.service.sprint.Sprint@1f39[id=12,rapidViewId=3,state=CLOSED,name=Sprint 7,startDate=2023-06-01T00:00:00.000Z]"]}`,
+			epicKey: "MSFT-200",
+			sprints: []string{"Sprint 7"},
+		},
+		"epic link beats parent": {
+			fields: `{"customfield_10014": "MSFT-200", "parent": {"key": "MSFT-100", "fields": {
+  "summary": "Big thing", "issuetype": {"name": "Epic"}}}}`,
+			epicKey: "MSFT-200",
+		},
+		"null fields": {
+			fields: `{"customfield_10014": null, "customfield_10020": null, "parent": null}`,
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			var rec issueRecord
+			assert.NoError(t, json.Unmarshal([]byte(`{"key": "MSFT-1", "fields": `+tt.fields+`}`), &rec))
+			assert.Equal(t, "MSFT-1", rec.Key)
+			key, title := rec.epic("customfield_10014")
+			assert.Equal(t, tt.epicKey, key)
+			assert.Equal(t, tt.epicTitle, title)
+			assert.Equal(t, tt.sprints, rec.sprints("customfield_10020"))
+		})
+	}
+}
+
+func Test_regroup(t *testing.T) {
+	msft := types.RepoId{Org: "microsoft developers", Name: "MSFT"}
+	plm := types.RepoId{Org: "plumbing", Name: "PLM"}
+	a := types.MyIssue{RepoId: msft, Number: 1, Epic: "MSFT-100", EpicTitle: "Big", Sprints: []string{"S1", "S2"}}
+	b := types.MyIssue{RepoId: plm, Number: 2, Epic: "MSFT-100", EpicTitle: "Big", Sprints: []string{"S2"}}
+	c := types.MyIssue{RepoId: plm, Number: 3}
+	groups := map[types.RepoId][]types.MyIssue{msft: {a}, plm: {b, c}}
+	issueOf := func(issue *types.MyIssue) *types.MyIssue { return issue }
+	byNumber := func(x, y *types.MyIssue) bool { return x.Number < y.Number }
+
+	assert.Equal(t, map[types.RepoId][]types.MyIssue{
+		{Org: "Big", Name: "MSFT-100"}: {a, b},
+		{}:                             {c},
+	}, regroup(groups, types.GroupByEpic, issueOf, byNumber))
+
+	assert.Equal(t, map[types.RepoId][]types.MyIssue{
+		{Name: "S2"}: {a, b},
+		{}:           {c},
+	}, regroup(groups, types.GroupBySprint, issueOf, byNumber))
+}
@@ -0,0 +1,37 @@
+package myjira
+
+import (
+	"fmt"
+
+	"github.com/monopole/snips/internal/config"
+	"github.com/monopole/snips/internal/types"
+)
+
+// makeCustomJql fills in the placeholders of a section's JQL.
+// The user is as in makeIssuesCreatedJql, and the end date is
+// the day after the range, as in makeIssuesCreatedJql, so a query like
+//
+//	assignee = "{user}" and updated >= '{start}' and updated < '{end}'
+//
+// covers the whole range.
+func makeCustomJql(s *config.Section, user string, dayRange *types.DayRange) string {
+	return s.Expand(
+		user,
+		dayRange.StartAsTime().Format(types.DayFormatJira),
+		adjustEndDate(dayRange.EndAsTime()).Format(types.DayFormatJira),
+	)
+}
+
+// findCustomIssues runs the searches of the jira sections in the config file.
+func (jb *jiraBoss) findCustomIssues(who string) ([]types.CustomIssueSet, error) {
+	var result []types.CustomIssueSet
+	for i := range jb.args.Config.Sections {
+		s := &jb.args.Config.Sections[i]
+		iSet, err := jb.doJiraSearch(makeCustomJql(s, who, jb.dayRange))
+		if err != nil {
+			return nil, fmt.Errorf("trouble with section %q; %w", s.Name, err)
+		}
+		result = append(result, types.CustomIssueSet{Label: s.Name, Issues: iSet})
+	}
+	return result, nil
+}
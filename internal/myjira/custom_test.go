@@ -0,0 +1,21 @@
+package myjira
+
+import (
+	"testing"
+
+	"github.com/monopole/snips/internal/config"
+	"github.com/monopole/snips/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_makeCustomJql(t *testing.T) {
+	dr, err := types.MakeDayRange("2023/01/03", "2023/01/17", 0)
+	assert.NoError(t, err)
+	s := &config.Section{
+		Name:  "On-call Tickets",
+		Query: `labels = oncall and assignee = "{user}" and updated >= '{start}' and updated < '{end}'`,
+	}
+	assert.Equal(t,
+		`labels = oncall and assignee = "bob" and updated >= '2023/01/03' and updated < '2023/01/18'`,
+		makeCustomJql(s, "bob", dr))
+}
@@ -0,0 +1,174 @@
+package myjira
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/monopole/snips/internal/types"
+)
+
+// groupKey returns the group of the issue under the given grouping.
+// Issues in no epic or sprint share the zero RepoId.
+func groupKey(groupBy string, issue *types.MyIssue) types.RepoId {
+	switch groupBy {
+	case types.GroupByEpic:
+		return types.RepoId{Org: issue.EpicTitle, Name: issue.Epic}
+	case types.GroupBySprint:
+		// Issues carried over from sprint to sprint land in the latest.
+		if n := len(issue.Sprints); n > 0 {
+			return types.RepoId{Name: issue.Sprints[n-1]}
+		}
+		return types.RepoId{}
+	}
+	return issue.RepoId
+}
+
+// regroup moves the items of groups into the groups of their issues under
+// the given grouping, then sorts each group by less.
+func regroup[T any](
+	groups map[types.RepoId][]T, groupBy string,
+	issueOf func(*T) *types.MyIssue, less func(a, b *T) bool) map[types.RepoId][]T {
+	result := make(map[types.RepoId][]T)
+	for _, lst := range groups {
+		for i := range lst {
+			k := groupKey(groupBy, issueOf(&lst[i]))
+			result[k] = append(result[k], lst[i])
+		}
+	}
+	for _, lst := range result {
+		sort.SliceStable(lst, func(i, j int) bool {
+			return less(&lst[i], &lst[j])
+		})
+	}
+	return result
+}
+
+// issueSets returns the user's jira issue sets, along with the given custom ones.
+func issueSets(u *types.MyUser, custom []types.CustomIssueSet) []*types.IssueSet {
+	result := []*types.IssueSet{u.IssuesCreated, u.IssuesCommented, u.IssuesClosed}
+	for _, c := range custom {
+		result = append(result, c.Issues)
+	}
+	return result
+}
+
+// groupUser regroups the user's jira items, and the given custom
+// issue sets, by epic or sprint, if so configured.
+func (jb *jiraBoss) groupUser(u *types.MyUser, custom []types.CustomIssueSet) error {
+	groupBy := jb.args.GroupBy
+	if groupBy == "" || groupBy == types.GroupByProject {
+		return nil
+	}
+	if groupBy == types.GroupByEpic {
+		if err := jb.addEpicTitles(u, custom); err != nil {
+			return err
+		}
+	}
+	for _, iSet := range issueSets(u, custom) {
+		if iSet == nil {
+			continue
+		}
+		iSet.Groups = regroup(iSet.Groups, groupBy,
+			func(issue *types.MyIssue) *types.MyIssue { return issue },
+			func(a, b *types.MyIssue) bool { return a.Updated.After(b.Updated) })
+		iSet.GroupedBy = groupBy
+	}
+	if u.Transitions != nil {
+		u.Transitions.Groups = regroup(u.Transitions.Groups, groupBy,
+			func(tr *types.MyTransition) *types.MyIssue { return &tr.Issue },
+			func(a, b *types.MyTransition) bool { return a.When.After(b.When) })
+		u.Transitions.GroupedBy = groupBy
+	}
+	if u.TimeLogged != nil {
+		u.TimeLogged.Groups = regroup(u.TimeLogged.Groups, groupBy,
+			func(wl *types.MyWorklog) *types.MyIssue { return &wl.Issue },
+			func(a, b *types.MyWorklog) bool { return a.Spent > b.Spent })
+		u.TimeLogged.GroupedBy = groupBy
+	}
+	return nil
+}
+
+// forEachIssue calls f on every jira issue held by the user or the custom issue sets.
+func forEachIssue(u *types.MyUser, custom []types.CustomIssueSet, f func(issue *types.MyIssue)) {
+	for _, iSet := range issueSets(u, custom) {
+		if iSet == nil {
+			continue
+		}
+		for _, lst := range iSet.Groups {
+			for i := range lst {
+				f(&lst[i])
+			}
+		}
+	}
+	if u.Transitions != nil {
+		for _, lst := range u.Transitions.Groups {
+			for i := range lst {
+				f(&lst[i].Issue)
+			}
+		}
+	}
+	if u.TimeLogged != nil {
+		for _, lst := range u.TimeLogged.Groups {
+			for i := range lst {
+				f(&lst[i].Issue)
+			}
+		}
+	}
+}
+
+// addEpicTitles looks up the summaries of epics known only by key,
+// as is the case when the epic comes from an epic link field.
+// Epics that can't be looked up, e.g. deleted ones, are left untitled.
+func (jb *jiraBoss) addEpicTitles(u *types.MyUser, custom []types.CustomIssueSet) error {
+	titles := make(map[string]string)
+	forEachIssue(u, custom, func(issue *types.MyIssue) {
+		if issue.Epic != "" && issue.EpicTitle == "" {
+			titles[issue.Epic] = ""
+		}
+	})
+	if len(titles) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(titles))
+	for k := range titles {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	epics, err := jb.searchIssues(
+		fmt.Sprintf("key in (%s)", strings.Join(keys, ",")), []string{"summary"}, nil)
+	if err != nil {
+		// Jira rejects the whole query if any key is deleted or hidden,
+		// so look them up one at a time, skipping those that fail.
+		if epics, err = jb.searchEpics(keys); err != nil {
+			return fmt.Errorf("trouble looking up epics; %w", err)
+		}
+	}
+	for _, e := range epics {
+		titles[e.Key] = e.Fields.Summary
+	}
+	forEachIssue(u, custom, func(issue *types.MyIssue) {
+		if issue.EpicTitle == "" {
+			issue.EpicTitle = titles[issue.Epic]
+		}
+	})
+	return nil
+}
+
+// searchEpics returns the epics with the given keys, warning of those
+// that can't be found.  It fails only if the boss's context ends.
+func (jb *jiraBoss) searchEpics(keys []string) ([]issueRecord, error) {
+	var result []issueRecord
+	for _, k := range keys {
+		epics, err := jb.searchIssues(fmt.Sprintf("key = %s", k), []string{"summary"}, nil)
+		if err != nil {
+			if jb.ctx.Err() != nil {
+				return nil, err
+			}
+			jb.Warn("unable to look up jira epic %s, so its title is missing; %s", k, err.Error())
+			continue
+		}
+		result = append(result, epics...)
+	}
+	return result, nil
+}
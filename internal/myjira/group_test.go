@@ -0,0 +1,82 @@
+package myjira
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/monopole/snips/internal/pgmargs"
+	"github.com/monopole/snips/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeEpics serves the given epic summaries by key, rejecting any
+// search naming a key it lacks, as Jira does.
+type fakeEpics map[string]string
+
+func (fe fakeEpics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req issueSearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var resp issueSearchResponse
+	keys := strings.TrimPrefix(req.Jql, "key = ")
+	keys = strings.TrimSuffix(strings.TrimPrefix(keys, "key in ("), ")")
+	for _, k := range strings.Split(keys, ",") {
+		summary, ok := fe[k]
+		if !ok {
+			http.Error(w, "An issue with key '"+k+"' does not exist", http.StatusBadRequest)
+			return
+		}
+		resp.Issues = append(resp.Issues, issueRecord{Key: k, Fields: issueDetails{Summary: summary}})
+	}
+	resp.Total = len(resp.Issues)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func Test_addEpicTitles(t *testing.T) {
+	tests := map[string]struct {
+		epics    []string
+		titles   []string
+		warnings int
+	}{
+		"all found": {
+			epics:  []string{"MSFT-1", "MSFT-2"},
+			titles: []string{"first epic", "second epic"},
+		},
+		"one deleted": {
+			epics:    []string{"MSFT-1", "MSFT-9", "MSFT-2"},
+			titles:   []string{"first epic", "", "second epic"},
+			warnings: 1,
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			srv := httptest.NewTLSServer(fakeEpics{"MSFT-1": "first epic", "MSFT-2": "second epic"})
+			defer srv.Close()
+			dr, _ := types.MakeDayRange("2023-06-01", "2023-06-30", 0)
+			jb := MakeJiraBoss(context.Background(), srv.Client(), &pgmargs.JiraArgs{
+				ServiceArgs: pgmargs.ServiceArgs{Domain: strings.TrimPrefix(srv.URL, "https://")},
+				PageSize:    10,
+				MaxIssues:   100,
+			}, dr)
+			u := &types.MyUser{Login: "bob", IssuesCreated: &types.IssueSet{}}
+			for _, e := range tt.epics {
+				u.IssuesCreated.Add(types.RepoId{Name: "MSFT"}, types.MyIssue{Epic: e})
+			}
+			assert.NoError(t, jb.addEpicTitles(u, nil))
+			var titles []string
+			for _, issue := range u.IssuesCreated.Groups[types.RepoId{Name: "MSFT"}] {
+				titles = append(titles, issue.EpicTitle)
+			}
+			assert.Equal(t, tt.titles, titles)
+			if assert.Len(t, jb.Warnings(), tt.warnings) && tt.warnings > 0 {
+				assert.Contains(t, jb.Warnings()[0], "unable to look up jira epic MSFT-9")
+			}
+		})
+	}
+}
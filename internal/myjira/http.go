@@ -7,64 +7,89 @@ import (
 	"io"
 	"net/http"
 	"net/url"
-	"os"
 
 	"github.com/monopole/snips/internal/myhttp"
+	"github.com/monopole/snips/internal/progress"
 )
 
-func (jb *jiraBoss) doJiraRequest(loc *url.URL, req issueSearchRequest) (resp *issueSearchResponse, err error) {
+// makeUrl returns the location of the given endpoint on the jira domain.
+func (jb *jiraBoss) makeUrl(endpoint string, query url.Values) (*url.URL, error) {
+	loc, err := url.Parse(myhttp.Scheme + jb.args.Domain + "/" + endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if query != nil {
+		loc.RawQuery = query.Encode()
+	}
+	return loc, nil
+}
+
+// doJiraRequest sends reqBody (if not nil) as JSON, and unmarshals the JSON response into resp.
+func (jb *jiraBoss) doJiraRequest(method string, loc *url.URL, reqBody any, resp any) (err error) {
 	var (
 		ans  io.ReadCloser
-		body []byte
+		body io.Reader
+		data []byte
 	)
-	body, err = json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("trouble marshaling data from request; %w", err)
+	if reqBody != nil {
+		data, err = json.Marshal(reqBody)
+		if err != nil {
+			return fmt.Errorf("trouble marshaling data from request; %w", err)
+		}
+		body = bytes.NewBuffer(data)
 	}
-	ans, err = jb.sendPost(loc, bytes.NewBuffer(body), jb.args.Token)
+	ans, err = jb.sendRequest(method, loc, body)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	body, err = io.ReadAll(ans)
+	defer ans.Close()
+	data, err = io.ReadAll(ans)
 	if err != nil {
-		return nil, fmt.Errorf("ReadAll failure: %w", err)
+		return fmt.Errorf("ReadAll failure: %w", err)
 	}
-	if debug := false; debug {
-		var pretty bytes.Buffer
-		_ = json.Indent(&pretty, body, "  ", "  ")
-		fmt.Fprintln(os.Stderr, pretty.String())
+	if err = json.Unmarshal(data, resp); err != nil {
+		return fmt.Errorf("trouble unmarshaling data from response; %w", err)
 	}
-	resp = &issueSearchResponse{}
-	err = json.Unmarshal(body, resp)
-	if err != nil {
-		return nil, fmt.Errorf("trouble unmarshaling data from response; %w", err)
-	}
-	return
+	return nil
 }
 
-func (jb *jiraBoss) sendPost(loc *url.URL, body io.Reader, token string) (ans io.ReadCloser, err error) {
-	const debug = false
+func (jb *jiraBoss) sendRequest(method string, loc *url.URL, body io.Reader) (ans io.ReadCloser, err error) {
 	var (
 		req  *http.Request
 		resp *http.Response
 	)
-	req, err = http.NewRequest(http.MethodPost, loc.String(), body)
+	req, err = http.NewRequestWithContext(jb.ctx, method, loc.String(), body)
 	if err != nil {
 		return
 	}
 	req.Header.Set(myhttp.HeaderAccept, myhttp.ContentTypeJson)
-	req.Header.Set(myhttp.HeaderContentType, myhttp.ContentTypeJson)
-	req.Header.Set(myhttp.HeaderAAuthorization, fmt.Sprintf("Bearer: %s", token))
+	if body != nil {
+		req.Header.Set(myhttp.HeaderContentType, myhttp.ContentTypeJson)
+	}
+	jb.authorize(req)
 	resp, err = jb.htCl.Do(req)
 	if err != nil {
 		return
 	}
-	if debug {
-		myhttp.PrintResponse(resp, myhttp.PrArgs{Headers: true, Body: true})
-	}
+	progress.Page()
 	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf("status code %d", resp.StatusCode)
+		defer resp.Body.Close()
+		err = fmt.Errorf("status code %d from %s %s: %s",
+			resp.StatusCode, method, loc.Path, myhttp.ErrBody(resp.Body))
 		return
 	}
 	return resp.Body, nil
 }
+
+// authorize adds credentials to the request.
+// Data Center takes a personal access token as a bearer token.
+// Cloud takes basic auth made from an email address and an API token.
+// https://confluence.atlassian.com/enterprise/using-personal-access-tokens-1026032365.html
+// https://developer.atlassian.com/cloud/jira/platform/basic-auth-for-rest-apis/
+func (jb *jiraBoss) authorize(req *http.Request) {
+	if jb.args.Cloud {
+		req.SetBasicAuth(jb.args.Email, jb.args.Token)
+		return
+	}
+	req.Header.Set(myhttp.HeaderAAuthorization, "Bearer "+jb.args.Token)
+}
@@ -1,57 +1,116 @@
 package myjira
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/monopole/snips/internal/pgmargs"
+	"github.com/monopole/snips/internal/progress"
 	"github.com/monopole/snips/internal/types"
 )
 
 type jiraBoss struct {
+	// ctx bounds every request, so searches stop when the run is cancelled.
+	ctx      context.Context
 	htCl     *http.Client
-	args     *pgmargs.ServiceArgs
+	args     *pgmargs.JiraArgs
 	dayRange *types.DayRange
+	types.WarningList
 }
 
-func MakeJiraBoss(htCl *http.Client, args *pgmargs.ServiceArgs, dayRange *types.DayRange) *jiraBoss {
+func MakeJiraBoss(
+	ctx context.Context, htCl *http.Client, args *pgmargs.JiraArgs, dayRange *types.DayRange) *jiraBoss {
 	return &jiraBoss{
+		ctx:      ctx,
 		htCl:     htCl,
 		args:     args,
 		dayRange: dayRange,
 	}
 }
 
-func (jb *jiraBoss) DoSearch(users []*types.MyUser) (err error) {
-	for _, u := range users {
-		u.IssuesCreated, err = jb.doJiraSearch(makeIssuesCreatedJql(u.Login, jb.dayRange))
-		if err != nil {
-			return err
-		}
-		u.IssuesClosed, err = jb.doJiraSearch(makeIssuesClosedJql(u.Login, jb.dayRange))
-		if err != nil {
-			return err
-		}
-		u.IssuesCommented, err = jb.doJiraSearch(makeIssuesCommentedJql(u.Login, jb.dayRange))
-		if err != nil {
-			return err
+// DoSearch adds each user's jira activity to their report sections.
+// If the boss's context ends first, it returns a types.StoppedError.
+func (jb *jiraBoss) DoSearch(users []*types.MyUser) error {
+	for i, u := range users {
+		if err := jb.collectUser(u); err != nil {
+			return types.Stopped(jb.ctx, err, users[i:])
 		}
 	}
 	return nil
 }
 
+func (jb *jiraBoss) collectUser(u *types.MyUser) error {
+	progress.Task("jira", u.Login)
+	slog.Info("searching jira", "user", u.Login)
+	profile, err := jb.lookupUser(u)
+	if errors.Is(err, types.ErrUnknownUser) {
+		u.JiraAccount = types.JiraAccountUnknown
+		jb.Warn("%s; skipping jira searches for %s", err.Error(), u.Login)
+		return nil
+	}
+	if errors.Is(err, errAmbiguousUser) {
+		u.JiraAccount = types.JiraAccountAmbiguous
+		jb.Warn("%s; skipping jira searches for %s, as it's unclear which account is theirs",
+			err.Error(), u.Login)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	addJiraProfile(u, profile)
+	// In JQL, Data Center identifies users by name, Cloud by accountId.
+	who := profile.Name
+	if jb.args.Cloud {
+		who = profile.AccountId
+	}
+	u.IssuesCreated, err = jb.doJiraSearch(makeIssuesCreatedJql(who, jb.dayRange))
+	if err != nil {
+		return err
+	}
+	u.Transitions, u.IssuesClosed, err = jb.findTransitions(who)
+	if err != nil {
+		return err
+	}
+	if jb.args.Cloud {
+		u.IssuesCommented, err = jb.findIssuesCommented(makeIssuesUpdatedByJql(who, jb.dayRange), who)
+	} else {
+		u.IssuesCommented, err = jb.findIssuesCommented(makeIssuesCommentedJql(who, jb.dayRange), who)
+	}
+	if err != nil {
+		return err
+	}
+	u.TimeLogged, err = jb.findTimeLogged(who)
+	if err != nil {
+		return err
+	}
+	var custom []types.CustomIssueSet
+	if custom, err = jb.findCustomIssues(who); err != nil {
+		return err
+	}
+	if err = jb.groupUser(u, custom); err != nil {
+		return err
+	}
+	u.Custom = append(u.Custom, custom...)
+	return nil
+}
+
 func makeIssuesCreatedJql(user string, dayRange *types.DayRange) string {
 	// the creator cannot change, but the reporter can change.  so maybe use reporter
 	// see :  https://support.atlassian.com/jira-software-cloud/docs/jql-fields/
 	return fmt.Sprintf(
-		"creator = %s and created >= '%s' and created < '%s'",
+		"creator = %q and created >= '%s' and created < '%s'",
 		user,
 		dayRange.StartAsTime().Format(types.DayFormatJira),
 		adjustEndDate(dayRange.EndAsTime()).Format(types.DayFormatJira),
 	)
 }
 
+// makeIssuesCommentedJql needs the ScriptRunner plugin's issuefunction,
+// which Data Center instances commonly have, and Cloud instances don't.
 func makeIssuesCommentedJql(user string, dayRange *types.DayRange) string {
 	return fmt.Sprintf(
 		"creator != %s and issuefunction in commented (' by %s after %s') and issuefunction in commented ('by %s before %s')",
@@ -63,15 +122,6 @@ func makeIssuesCommentedJql(user string, dayRange *types.DayRange) string {
 	)
 }
 
-func makeIssuesClosedJql(user string, dayRange *types.DayRange) string {
-	return fmt.Sprintf(
-		"status WAS 'Resolved' BY %s DURING ('%s','%s')",
-		user,
-		dayRange.StartAsTime().Format(types.DayFormatJira),
-		adjustEndDate(dayRange.EndAsTime()).Format(types.DayFormatJira),
-	)
-}
-
 // adjustEndDate adds one day to the end-day so that the query range counts up through midnight on the end-day.
 //
 // E.g. we know that https://issues.acmecorp.com/browse/MSFT-001 was created at 2023-06-08T13:47
@@ -2,8 +2,7 @@ package myjira
 
 import (
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"sort"
 	"strconv"
 	"strings"
@@ -13,24 +12,18 @@ import (
 	"github.com/monopole/snips/internal/types"
 )
 
-func makeMapOfRepoToIssueList(domain string, issues []issueRecord) (map[types.RepoId][]types.MyIssue, error) {
+func (jb *jiraBoss) makeMapOfRepoToIssueList(issues []issueRecord) (map[types.RepoId][]types.MyIssue, error) {
 	rawMap := make(map[types.RepoId][]*issueRecord)
 	seen := make(map[string]*issueRecord)
 	for i := range issues {
 		issue := issues[i]
 		if _, ok := seen[issue.Id]; ok {
-			fmt.Fprintf(os.Stderr, "seem to be doubling up on %q\n", issue.Id)
-			// ignore it - but maybe we should complain that something non-unique came back from search
+			// Something non-unique came back from search; ignore it.
+			slog.Debug("jira search returned an issue twice", "id", issue.Id, "key", issue.Key)
 			continue
 		}
 		seen[issue.Id] = &issue
-		id := types.RepoId{
-			// Name is something like 'microsoft developers'
-			Org: issue.Fields.Project.Name,
-			// Key is something like MSFT
-			// The URL we want is https://issues.acmecorp.com/projects/MSFT/issues
-			Name: issue.Fields.Project.Key,
-		}
+		id := repoIdOf(&issue)
 		rawMap[id] = append(rawMap[id], &issue)
 	}
 	var err error
@@ -38,9 +31,9 @@ func makeMapOfRepoToIssueList(domain string, issues []issueRecord) (map[types.Re
 	for id, jiraIssues := range rawMap {
 		lst := make([]types.MyIssue, len(jiraIssues))
 		for i := range jiraIssues {
-			lst[i], err = convertJiraIssueToGhIssue(domain, id, jiraIssues[i])
+			lst[i], err = jb.convertJiraIssueToGhIssue(id, jiraIssues[i])
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Trouble with %+v\n", jiraIssues[i])
+				slog.Error("trouble converting jira issue", "key", jiraIssues[i].Key, "err", err)
 				return nil, err
 			}
 		}
@@ -52,32 +45,78 @@ func makeMapOfRepoToIssueList(domain string, issues []issueRecord) (map[types.Re
 	return result, nil
 }
 
-func convertJiraIssueToGhIssue(domain string, id types.RepoId, rec *issueRecord) (types.MyIssue, error) {
+// repoIdOf returns the project of the issue as if it were a GitHub repo.
+func repoIdOf(rec *issueRecord) types.RepoId {
+	return types.RepoId{
+		// Name is something like 'microsoft developers'
+		Org: rec.Fields.Project.Name,
+		// Key is something like MSFT
+		// The URL we want is https://issues.acmecorp.com/projects/MSFT/issues
+		Name: rec.Fields.Project.Key,
+	}
+}
+
+func (jb *jiraBoss) convertJiraIssueToGhIssue(id types.RepoId, rec *issueRecord) (types.MyIssue, error) {
 	updated, err := time.Parse(types.DateFormatJiraIssue, rec.Fields.Updated)
 	if err != nil {
 		return types.MyIssue{}, fmt.Errorf("trouble parsing 'updated' time field; %w", err)
 	}
-	num, err := getIssueNumber(id, rec.Key)
-	if err != nil {
-		return types.MyIssue{}, err
-	}
+	epic, epicTitle := rec.epic(jb.args.Config.EpicLinkField)
 	return types.MyIssue{
-		RepoId:  id,
-		Number:  num,
-		Title:   rec.Fields.Summary,
-		HtmlUrl: myhttp.Scheme + domain + "/browse/" + rec.Key,
-		Updated: updated,
+		RepoId:    id,
+		Number:    jb.issueNumber(id, rec.Key),
+		Key:       rec.Key,
+		Title:     rec.Fields.Summary,
+		HtmlUrl:   myhttp.Scheme + jb.args.Domain + "/browse/" + rec.Key,
+		Updated:   updated,
+		State:     rec.Fields.Status.Name,
+		Epic:      epic,
+		EpicTitle: epicTitle,
+		Sprints:   rec.sprints(jb.args.Config.SprintField),
+		Comments:  rec.userComments,
 	}, nil
 }
 
-func getIssueNumber(id types.RepoId, raw string) (int, error) {
-	parts := strings.Split(raw, "-")
-	if len(parts) != 2 {
-		return 0, fmt.Errorf("expected something like PLM-1234, but have %q", raw)
+// issueNumber returns the number in the issue's key, or zero if the key
+// can't be parsed.  Surprises are warnings rather than errors, since the
+// issue is still worth reporting, and its link is made from the whole key.
+func (jb *jiraBoss) issueNumber(id types.RepoId, key string) int {
+	projectKey, num, err := parseIssueKey(key)
+	if err != nil {
+		jb.Warn("%s", err.Error())
+		return 0
+	}
+	if !strings.EqualFold(projectKey, id.Name) {
+		// Issues moved between projects can keep their old key.
+		jb.Warn("jira issue %s belongs to project %s; it was probably moved there", key, id.Name)
+	}
+	return num
+}
+
+// parseIssueKey splits an issue key like "PLM-1234" into a project key and a number.
+// Project keys usually hold only letters, digits and underscores, but some older
+// or imported projects have hyphens in their keys, so the number is whatever
+// follows the last hyphen.
+func parseIssueKey(raw string) (projectKey string, num int, err error) {
+	key := strings.TrimSpace(raw)
+	i := strings.LastIndex(key, "-")
+	if i < 1 || !isDigits(key[i+1:]) {
+		return "", 0, fmt.Errorf("expected a jira issue key like PLM-1234, but have %q", raw)
+	}
+	if num, err = strconv.Atoi(key[i+1:]); err != nil {
+		return "", 0, fmt.Errorf("trouble with the number in jira issue key %q; %w", raw, err)
+	}
+	return key[:i], num, nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
 	}
-	if parts[0] != id.Name {
-		log.Fatal(fmt.Errorf("expected %q, but got %q from issue key %s", id.Name, parts[0], raw).Error())
-		// return 0, fmt.Errorf("expected %q, but got %q from issue key %s", id.Name, parts[0], raw)
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
 	}
-	return strconv.Atoi(parts[1])
+	return true
 }
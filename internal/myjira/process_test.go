@@ -0,0 +1,78 @@
+package myjira
+
+import (
+	"context"
+	"testing"
+
+	"github.com/monopole/snips/internal/pgmargs"
+	"github.com/monopole/snips/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseIssueKey(t *testing.T) {
+	tests := map[string]struct {
+		key        string
+		projectKey string
+		num        int
+		errMsg     string
+	}{
+		"typical":              {key: "PLM-25038", projectKey: "PLM", num: 25038},
+		"short":                {key: "MSFT-1", projectKey: "MSFT", num: 1},
+		"digits in project":    {key: "ABC2-34", projectKey: "ABC2", num: 34},
+		"underscore":           {key: "MY_PROJ-12", projectKey: "MY_PROJ", num: 12},
+		"hyphenated project":   {key: "OLD-PROJ-7", projectKey: "OLD-PROJ", num: 7},
+		"lower case":           {key: "msft-12", projectKey: "msft", num: 12},
+		"leading zeros":        {key: "MSFT-0012", projectKey: "MSFT", num: 12},
+		"surrounding space":    {key: " MSFT-3 ", projectKey: "MSFT", num: 3},
+		"empty":                {key: "", errMsg: `but have ""`},
+		"no hyphen":            {key: "MSFT", errMsg: `but have "MSFT"`},
+		"no number":            {key: "MSFT-", errMsg: `but have "MSFT-"`},
+		"no project":           {key: "-12", errMsg: `but have "-12"`},
+		"number only":          {key: "12", errMsg: `but have "12"`},
+		"letters after number": {key: "MSFT-12a", errMsg: `but have "MSFT-12a"`},
+		"signed number":        {key: "MSFT-+12", errMsg: `but have "MSFT-+12"`},
+		"huge number":          {key: "MSFT-99999999999999999999", errMsg: "trouble with the number"},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			projectKey, num, err := parseIssueKey(tt.key)
+			if tt.errMsg != "" {
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.projectKey, projectKey)
+			assert.Equal(t, tt.num, num)
+		})
+	}
+}
+
+func Test_issueNumber(t *testing.T) {
+	msft := types.RepoId{Org: "microsoft developers", Name: "MSFT"}
+	tests := map[string]struct {
+		key      string
+		num      int
+		warnings []string
+	}{
+		"matching project":  {key: "MSFT-12", num: 12},
+		"matching any case": {key: "msft-12", num: 12},
+		"moved issue": {
+			key:      "PLM-7",
+			num:      7,
+			warnings: []string{"jira issue PLM-7 belongs to project MSFT; it was probably moved there"},
+		},
+		"unparseable": {
+			key:      "MSFT",
+			warnings: []string{`expected a jira issue key like PLM-1234, but have "MSFT"`},
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			jb := MakeJiraBoss(context.Background(), nil, &pgmargs.JiraArgs{}, nil)
+			assert.Equal(t, tt.num, jb.issueNumber(msft, tt.key))
+			// The same issue found again doesn't repeat the warning.
+			assert.Equal(t, tt.num, jb.issueNumber(msft, tt.key))
+			assert.Equal(t, tt.warnings, jb.Warnings())
+		})
+	}
+}
@@ -1,92 +1,221 @@
 package myjira
 
 import (
-	"net/url"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
 
-	"github.com/monopole/snips/internal/myhttp"
+	"github.com/monopole/snips/internal/progress"
 	"github.com/monopole/snips/internal/types"
 )
 
 const (
-	// v3 is in beta, v2 is in production.  Use the latter.
-	// https://developer.atlassian.com/cloud/jira/platform/rest/v3/intro/#version
-	// https://developer.atlassian.com/cloud/jira/platform/rest/v2/intro/#version
-	//
-	// Issue Search
-	// https://developer.atlassian.com/cloud/jira/platform/rest/v2/api-group-issue-search/#api-rest-api-2-search-post
+	// Data Center serves v2 of the REST API.
+	// https://docs.atlassian.com/software/jira/docs/api/REST/9.4.0/#api/2/search-searchUsingSearchRequest
 	searchEndpoint = "rest/api/2/search"
 
-	maxResult    = 10
-	maxMaxResult = 10000
+	// Cloud has replaced its offset-paged search endpoints with this token-paged one.
+	// https://developer.atlassian.com/cloud/jira/platform/rest/v3/api-group-issue-search/#api-rest-api-3-search-jql-post
+	searchJqlEndpoint = "rest/api/3/search/jql"
+
+	// maxConcurrentPages limits the number of search pages fetched at once.
+	maxConcurrentPages = 4
 )
 
+// searchFields are the issue fields needed for the report.
+// Send Fields:nil to get all fields (but be mindful that you'll lose them when marshalling from JSON).
+var searchFields = []string{
+	// id is a jira internal number with seven or so digits.
+	"id",
+	// key is something like PLM-25038, DESOS-234.
+	"key",
+	// summary is the issue summary, e.g. "users wants this blue thing to be red".
+	"summary",
+	// resolution is a struct describing the conditions of resolution.
+	"resolution",
+	// labels is a string array of labels.
+	"labels",
+
+	// assignee is a struct describing a user - name, email, displayName, etc.
+	"assignee",
+	// reporter is a struct describing a user - name, email, displayName, etc.
+	"reporter",
+	// creator is a struct describing a user - name, email, displayName, etc.
+	"creator",
+
+	// project is a struct with a key like "MSFT", a name like "microsoft developers", and avatar urls.
+	// A project url takes the form: https://issues.acmecorp.com/projects/PLM/issues
+	"project",
+
+	// description is the long textual description of the issue.
+	// It's a string in v2 of the API, and an ADF document in v3.
+	"description",
+
+	// updated is the timestamp associated with the most recent update.
+	"updated",
+
+	// status is a struct with the name of the issue's workflow status, e.g. "In Progress".
+	"status",
+}
+
+// issueFields returns the fields to search for: searchFields, the
+// configured custom fields holding sprint and epic, and any others given.
+func (jb *jiraBoss) issueFields(others ...string) []string {
+	result := slices.Concat(searchFields, others)
+	// parent holds the epic on Cloud.
+	result = append(result, fieldParent)
+	for _, f := range []string{jb.args.Config.SprintField, jb.args.Config.EpicLinkField} {
+		if f != "" {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+var searchExpand = []string{"renderedFields", "names"}
+
 func (jb *jiraBoss) doJiraSearch(jql string) (*types.IssueSet, error) {
-	loc, err := url.Parse(myhttp.Scheme + jb.args.Domain + "/" + searchEndpoint)
+	issues, err := jb.searchIssues(jql, jb.issueFields(), searchExpand)
+	if err != nil {
+		return nil, err
+	}
+	return jb.makeIssueSet(issues)
+}
+
+func (jb *jiraBoss) makeIssueSet(issues []issueRecord) (*types.IssueSet, error) {
+	m, err := jb.makeMapOfRepoToIssueList(issues)
+	if err != nil {
+		return nil, err
+	}
+	return &types.IssueSet{
+		Domain: jb.args.Domain,
+		Groups: m,
+	}, nil
+}
+
+// searchIssues returns all issues matching the jql, using
+// whichever search endpoint the jira instance supports.
+func (jb *jiraBoss) searchIssues(jql string, fields []string, expand []string) ([]issueRecord, error) {
+	progress.Query(jql)
+	slog.Debug("jira search", "jql", jql)
+	if jb.args.Cloud {
+		return jb.searchCloud(jql, fields, expand)
+	}
+	return jb.searchDataCenter(jql, fields, expand)
+}
+
+// searchDataCenter fetches the first page to learn the total
+// number of matches, then fetches the remaining pages concurrently.
+func (jb *jiraBoss) searchDataCenter(jql string, fields []string, expand []string) ([]issueRecord, error) {
+	loc, err := jb.makeUrl(searchEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req := jb.makeJiraSearchRequest(jql, fields, expand)
+	var first issueSearchResponse
+	if err = jb.doJiraRequest(http.MethodPost, loc, req, &first); err != nil {
+		return nil, err
+	}
+	want := first.Total
+	if want > jb.args.MaxIssues {
+		jb.warnTruncated(jql, fmt.Sprintf("%d", first.Total))
+		want = jb.args.MaxIssues
+	}
+	if len(first.Issues) >= want {
+		return first.Issues[:want], nil
+	}
+	// The server may grant a smaller page than the one asked for.
+	pageSize := first.MaxResults
+	if pageSize < 1 {
+		pageSize = len(first.Issues)
+	}
+	if pageSize < 1 {
+		return first.Issues, nil
+	}
+	var starts []int
+	for start := len(first.Issues); start < want; start += pageSize {
+		starts = append(starts, start)
+	}
+	var (
+		wg    sync.WaitGroup
+		sem   = make(chan struct{}, maxConcurrentPages)
+		pages = make([][]issueRecord, len(starts))
+		errs  = make([]error, len(starts))
+	)
+	for i, start := range starts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			pageReq := req
+			pageReq.StartAt = start
+			pageReq.MaxResults = min(pageSize, want-start)
+			var resp issueSearchResponse
+			errs[i] = jb.doJiraRequest(http.MethodPost, loc, pageReq, &resp)
+			pages[i] = resp.Issues
+		}()
+	}
+	wg.Wait()
+	if err = errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	issues := first.Issues
+	for _, page := range pages {
+		issues = append(issues, page...)
+	}
+	return issues, nil
+}
+
+// searchCloud pages sequentially, since each page's token comes from
+// the previous page, and Cloud doesn't report a total.
+func (jb *jiraBoss) searchCloud(jql string, fields []string, expand []string) ([]issueRecord, error) {
+	loc, err := jb.makeUrl(searchJqlEndpoint, nil)
 	if err != nil {
 		return nil, err
 	}
 	var issues []issueRecord
-	req := makeJiraSearchRequest(jql)
+	req := jqlSearchRequest{
+		Jql:    jql,
+		Fields: fields,
+		Expand: strings.Join(expand, ","),
+	}
 	for {
-		var resp *issueSearchResponse
-		resp, err = jb.doJiraRequest(loc, req)
-		if err != nil {
+		req.MaxResults = min(jb.args.PageSize, jb.args.MaxIssues-len(issues))
+		var resp jqlSearchResponse
+		if err = jb.doJiraRequest(http.MethodPost, loc, &req, &resp); err != nil {
 			return nil, err
 		}
-		if len(resp.Issues) == 0 {
+		issues = append(issues, resp.Issues...)
+		if resp.IsLast || resp.NextPageToken == "" {
 			break
 		}
-		issues = append(issues, resp.Issues...)
-		req.StartAt += len(resp.Issues)
-		if req.StartAt > maxMaxResult {
+		if len(issues) >= jb.args.MaxIssues {
+			jb.warnTruncated(jql, fmt.Sprintf("more than %d", jb.args.MaxIssues))
 			break
 		}
+		req.NextPageToken = resp.NextPageToken
 	}
-	m, err := makeMapOfRepoToIssueList(jb.args.Domain, issues)
-	if err != nil {
-		return nil, err
-	}
-	return &types.IssueSet{
-		Domain: jb.args.Domain,
-		Groups: m,
-	}, nil
+	return issues, nil
+}
+
+// warnTruncated records that a search matched more issues than were fetched.
+func (jb *jiraBoss) warnTruncated(jql string, matched string) {
+	jb.Warn(
+		"jira search [%s] matched %s issues, but only the first %d are shown; raise --jira-max-issues to see more",
+		jql, matched, jb.args.MaxIssues)
 }
 
-func makeJiraSearchRequest(jql string) issueSearchRequest {
+func (jb *jiraBoss) makeJiraSearchRequest(jql string, fields []string, expand []string) issueSearchRequest {
 	return issueSearchRequest{
 		Jql:        jql,
-		MaxResults: maxResult,
+		MaxResults: min(jb.args.PageSize, jb.args.MaxIssues),
 		StartAt:    0,
-		// Send Fields:nil to get all fields (but be mindful that you'll lose them when marshalling from JSON).
-		Fields: []string{
-			// id is a jira internal number with seven or so digits.
-			"id",
-			// key is something like PLM-25038, DESOS-234.
-			"key",
-			// summary is the issue summary, e.g. "users wants this blue thing to be red".
-			"summary",
-			// resolution is a struct describing the conditions of resolution.
-			"resolution",
-			// labels is a string array of labels.
-			"labels",
-
-			// assignee is a struct describing a user - name, email, displayName, etc.
-			"assignee",
-			// reporter is a struct describing a user - name, email, displayName, etc.
-			"reporter",
-			// creator is a struct describing a user - name, email, displayName, etc.
-			"creator",
-
-			// project is a struct with a key like "MSFT", a name like "microsoft developers", and avatar urls.
-			// A project url takes the form: https://issues.acmecorp.com/projects/PLM/issues
-			"project",
-
-			// description is the long textual description of the issue.
-			"description",
-
-			// updated is the timestamp associated with the most recent update.
-			"updated",
-		},
-		Expand: []string{"renderedFields", "names"},
+		Fields:     fields,
+		Expand:     expand,
 	}
 }
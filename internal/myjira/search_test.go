@@ -0,0 +1,124 @@
+package myjira
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/monopole/snips/internal/pgmargs"
+	"github.com/monopole/snips/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDataCenter serves total issues, granting at most grant issues per page.
+type fakeDataCenter struct {
+	t     *testing.T
+	total int
+	grant int
+
+	mu       sync.Mutex
+	requests []issueSearchRequest
+}
+
+func (fd *fakeDataCenter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	assert.Equal(fd.t, "Bearer sekret", r.Header.Get("Authorization"))
+	assert.Equal(fd.t, "/"+searchEndpoint, r.URL.Path)
+	var req issueSearchRequest
+	assert.NoError(fd.t, json.NewDecoder(r.Body).Decode(&req))
+	fd.mu.Lock()
+	fd.requests = append(fd.requests, req)
+	fd.mu.Unlock()
+	resp := issueSearchResponse{
+		StartAt:    req.StartAt,
+		MaxResults: min(req.MaxResults, fd.grant),
+		Total:      fd.total,
+	}
+	for i := req.StartAt; i < min(req.StartAt+resp.MaxResults, fd.total); i++ {
+		resp.Issues = append(resp.Issues, makeRecord(i+1))
+	}
+	assert.NoError(fd.t, json.NewEncoder(w).Encode(resp))
+}
+
+func Test_searchDataCenter(t *testing.T) {
+	tests := map[string]struct {
+		total     int
+		grant     int
+		pageSize  int
+		maxIssues int
+		// starts are the startAt values expected in requests.
+		starts  []int
+		count   int
+		warning string
+	}{
+		"no matches": {
+			total: 0, grant: 50, pageSize: 10, maxIssues: 100,
+			starts: []int{0},
+		},
+		"one page": {
+			total: 7, grant: 50, pageSize: 10, maxIssues: 100,
+			starts: []int{0},
+			count:  7,
+		},
+		"several pages": {
+			total: 25, grant: 50, pageSize: 10, maxIssues: 100,
+			starts: []int{0, 10, 20},
+			count:  25,
+		},
+		"server grants smaller pages": {
+			total: 25, grant: 8, pageSize: 10, maxIssues: 100,
+			starts: []int{0, 8, 16, 24},
+			count:  25,
+		},
+		"truncated": {
+			total: 25, grant: 50, pageSize: 10, maxIssues: 22,
+			starts:  []int{0, 10, 20},
+			count:   22,
+			warning: "matched 25 issues, but only the first 22 are shown",
+		},
+		"truncated in first page": {
+			total: 25, grant: 50, pageSize: 10, maxIssues: 5,
+			starts:  []int{0},
+			count:   5,
+			warning: "matched 25 issues, but only the first 5 are shown",
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			fd := &fakeDataCenter{t: t, total: tt.total, grant: tt.grant}
+			srv := httptest.NewTLSServer(fd)
+			defer srv.Close()
+			dr, _ := types.MakeDayRange("2023-06-01", "2023-06-30", 0)
+			jb := MakeJiraBoss(context.Background(), srv.Client(), &pgmargs.JiraArgs{
+				ServiceArgs: pgmargs.ServiceArgs{
+					Domain: strings.TrimPrefix(srv.URL, "https://"),
+					Token:  "sekret",
+				},
+				PageSize:  tt.pageSize,
+				MaxIssues: tt.maxIssues,
+			}, dr)
+			issues, err := jb.searchIssues("project = MSFT", searchFields, searchExpand)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.count, len(issues))
+			for i := range issues {
+				assert.Equal(t, makeRecord(i+1).Key, issues[i].Key)
+			}
+			var starts []int
+			for _, req := range fd.requests {
+				starts = append(starts, req.StartAt)
+				assert.LessOrEqual(t, req.StartAt+req.MaxResults, tt.maxIssues)
+			}
+			assert.ElementsMatch(t, tt.starts, starts)
+			if tt.warning == "" {
+				assert.Empty(t, jb.Warnings())
+				return
+			}
+			if assert.Len(t, jb.Warnings(), 1) {
+				assert.Contains(t, jb.Warnings()[0], tt.warning)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package myjira
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/monopole/snips/internal/types"
+)
+
+const (
+	// https://developer.atlassian.com/cloud/jira/platform/rest/v3/api-group-issues/#api-rest-api-3-issue-issueidorkey-changelog-get
+	changelogEndpointFmtCloud = "rest/api/3/issue/%s/changelog"
+
+	// Data Center has no changelog endpoint, but returns the whole
+	// changelog when asked to expand an issue.
+	// https://docs.atlassian.com/software/jira/docs/api/REST/9.4.0/#api/2/issue-getIssue
+	issueEndpointFmtDataCenter = "rest/api/2/issue/%s"
+
+	maxChangelogResult = 100
+
+	// fieldStatus names the workflow status field in a changelog.
+	fieldStatus = "status"
+)
+
+// makeStatusChangedJql finds issues whose status the user changed in the day range.
+// https://support.atlassian.com/jira-software-cloud/docs/jql-operators/#CHANGED
+func makeStatusChangedJql(user string, dayRange *types.DayRange) string {
+	return fmt.Sprintf(
+		"status CHANGED BY %q DURING ('%s','%s')",
+		user,
+		dayRange.StartAsTime().Format(types.DayFormatJira),
+		adjustEndDate(dayRange.EndAsTime()).Format(types.DayFormatJira),
+	)
+}
+
+// findTransitions returns the status transitions the user made in the day range,
+// and the issues the user closed, i.e. moved to one of the closed statuses
+// configured for the issue's project.
+func (jb *jiraBoss) findTransitions(who string) (*types.TransitionSet, *types.IssueSet, error) {
+	candidates, err := jb.searchIssues(
+		makeStatusChangedJql(who, jb.dayRange), jb.issueFields(), slices.Concat(searchExpand, []string{"changelog"}))
+	if err != nil {
+		return nil, nil, err
+	}
+	result := &types.TransitionSet{
+		Domain: jb.args.Domain,
+		Groups: make(map[types.RepoId][]types.MyTransition),
+	}
+	var closed []issueRecord
+	for i := range candidates {
+		rec := &candidates[i]
+		var histories []history
+		if histories, err = jb.allHistories(rec); err != nil {
+			return nil, nil, err
+		}
+		id := repoIdOf(rec)
+		var issue types.MyIssue
+		if issue, err = jb.convertJiraIssueToGhIssue(id, rec); err != nil {
+			return nil, nil, err
+		}
+		isClosed := false
+		for _, h := range histories {
+			if !jb.isUser(h.Author, who) {
+				continue
+			}
+			when, err := time.Parse(types.DateFormatJiraIssue, h.Created)
+			if err != nil || !jb.dayRange.Contains(when) {
+				continue
+			}
+			for _, item := range h.Items {
+				if item.Field != fieldStatus {
+					continue
+				}
+				result.Groups[id] = append(result.Groups[id], types.MyTransition{
+					Issue: issue,
+					From:  item.FromString,
+					To:    item.ToString,
+					When:  when,
+				})
+				if jb.args.Config.IsClosedStatus(id.Name, item.ToString) {
+					isClosed = true
+				}
+			}
+		}
+		if isClosed {
+			closed = append(closed, *rec)
+		}
+	}
+	for _, lst := range result.Groups {
+		sort.SliceStable(lst, func(i, j int) bool {
+			return lst[i].When.After(lst[j].When)
+		})
+	}
+	closedSet, err := jb.makeIssueSet(closed)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, closedSet, nil
+}
+
+// allHistories returns the changelog that came with the issue, fetching
+// the rest if the search response held only part of it.
+func (jb *jiraBoss) allHistories(rec *issueRecord) ([]history, error) {
+	cl := rec.Changelog
+	if cl != nil && len(cl.Histories) >= cl.Total {
+		return cl.Histories, nil
+	}
+	if !jb.args.Cloud {
+		loc, err := jb.makeUrl(fmt.Sprintf(issueEndpointFmtDataCenter, rec.Key), url.Values{
+			"fields": {fieldStatus},
+			"expand": {"changelog"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		var full issueRecord
+		if err = jb.doJiraRequest(http.MethodGet, loc, nil, &full); err != nil {
+			return nil, err
+		}
+		if full.Changelog == nil {
+			return nil, nil
+		}
+		return full.Changelog.Histories, nil
+	}
+	var result []history
+	for startAt := 0; ; {
+		loc, err := jb.makeUrl(fmt.Sprintf(changelogEndpointFmtCloud, rec.Key), url.Values{
+			"startAt":    {strconv.Itoa(startAt)},
+			"maxResults": {strconv.Itoa(maxChangelogResult)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		var resp changelogPage
+		if err = jb.doJiraRequest(http.MethodGet, loc, nil, &resp); err != nil {
+			return nil, err
+		}
+		result = append(result, resp.Values...)
+		startAt += len(resp.Values)
+		if resp.IsLast || len(resp.Values) == 0 || startAt >= resp.Total {
+			return result, nil
+		}
+	}
+}
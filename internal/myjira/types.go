@@ -1,11 +1,18 @@
 package myjira
 
+import (
+	"encoding/json"
+
+	"github.com/monopole/snips/internal/types"
+)
+
+// issueSearchRequest is the body of a Data Center search (rest/api/2/search).
 type issueSearchRequest struct {
-	Jql        string   `json:"jql,omitempty" :"jql" :"jql"`
-	StartAt    int      `json:"startAt",omitempty`
-	MaxResults int      `json:"maxResults,omitempty" :"maxResults" :"maxResults"`
-	Fields     []string `json:"fields,omitempty" :"fields"`
-	Expand     []string `json:"expand,omitempty" :"expand"`
+	Jql        string   `json:"jql,omitempty"`
+	StartAt    int      `json:"startAt,omitempty"`
+	MaxResults int      `json:"maxResults,omitempty"`
+	Fields     []string `json:"fields,omitempty"`
+	Expand     []string `json:"expand,omitempty"`
 }
 
 type issueSearchResponse struct {
@@ -16,6 +23,23 @@ type issueSearchResponse struct {
 	Issues     []issueRecord `json:"issues,omitempty"`
 }
 
+// jqlSearchRequest is the body of a Cloud search (rest/api/3/search/jql),
+// which pages with an opaque token rather than an offset.
+type jqlSearchRequest struct {
+	Jql           string   `json:"jql"`
+	NextPageToken string   `json:"nextPageToken,omitempty"`
+	MaxResults    int      `json:"maxResults,omitempty"`
+	Fields        []string `json:"fields,omitempty"`
+	// Expand is a comma separated list, unlike in issueSearchRequest.
+	Expand string `json:"expand,omitempty"`
+}
+
+type jqlSearchResponse struct {
+	Issues        []issueRecord `json:"issues,omitempty"`
+	NextPageToken string        `json:"nextPageToken,omitempty"`
+	IsLast        bool          `json:"isLast,omitempty"`
+}
+
 type project struct {
 	Self string `json:"self,omitempty"`
 	Id   string `json:"id,omitempty"`
@@ -23,29 +47,127 @@ type project struct {
 	Name string `json:"name,omitempty"`
 }
 
+type status struct {
+	Self string `json:"self,omitempty"`
+	Id   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
 type user struct {
 	Self         string `json:"self,omitempty"`
 	Name         string `json:"name,omitempty"`
 	Key          string `json:"key,omitempty"`
 	EmailAddress string `json:"emailAddress,omitempty"`
 	DisplayName  string `json:"displayName,omitempty"`
+	// AccountId identifies users on Jira Cloud, where Name and Key are gone.
+	AccountId   string `json:"accountId,omitempty"`
+	AccountType string `json:"accountType,omitempty"`
+	Active      bool   `json:"active,omitempty"`
+	// AvatarUrls maps a size, e.g. "48x48", to the location of the user's avatar.
+	AvatarUrls map[string]string `json:"avatarUrls,omitempty"`
+}
+
+type comment struct {
+	Id      string   `json:"id,omitempty"`
+	Author  user     `json:"author"`
+	Body    richText `json:"body,omitempty"`
+	Created string   `json:"created,omitempty"`
+	Updated string   `json:"updated,omitempty"`
+	// RenderedBody is the body as HTML, if the renderedBody expansion was asked for.
+	RenderedBody string `json:"renderedBody,omitempty"`
+}
+
+// commentPage is both the "comment" field of an issue and
+// the response of the issue comment endpoint.
+type commentPage struct {
+	StartAt    int       `json:"startAt"`
+	MaxResults int       `json:"maxResults"`
+	Total      int       `json:"total"`
+	Comments   []comment `json:"comments"`
+}
+
+type worklog struct {
+	Id      string `json:"id,omitempty"`
+	Author  user   `json:"author"`
+	Started string `json:"started,omitempty"`
+	// TimeSpentSeconds is the time logged; TimeSpent is the same thing, formatted like "3h 20m".
+	TimeSpentSeconds int    `json:"timeSpentSeconds,omitempty"`
+	TimeSpent        string `json:"timeSpent,omitempty"`
+}
+
+// worklogPage is both the "worklog" field of an issue and
+// the response of the issue worklog endpoint.
+type worklogPage struct {
+	StartAt    int       `json:"startAt"`
+	MaxResults int       `json:"maxResults"`
+	Total      int       `json:"total"`
+	Worklogs   []worklog `json:"worklogs"`
 }
 
 type issueDetails struct {
-	Summary     string   `json:"summary,omitempty"`
-	Creator     user     `json:"creator"`
-	Description string   `json:"description,omitempty"`
-	Project     project  `json:"project"`
-	Reporter    user     `json:"reporter"`
-	Assignee    user     `json:"assignee"`
-	Updated     string   `json:"updated,omitempty"`
-	Labels      []string `json:"labels,omitempty"`
+	Summary     string       `json:"summary,omitempty"`
+	Creator     user         `json:"creator"`
+	Description richText     `json:"description,omitempty"`
+	Project     project      `json:"project"`
+	Reporter    user         `json:"reporter"`
+	Assignee    user         `json:"assignee"`
+	Updated     string       `json:"updated,omitempty"`
+	Status      status       `json:"status"`
+	Labels      []string     `json:"labels,omitempty"`
+	Comment     *commentPage `json:"comment,omitempty"`
+	Worklog     *worklogPage `json:"worklog,omitempty"`
+}
+
+// changeItem is one field changed in a history entry.
+type changeItem struct {
+	Field      string `json:"field"`
+	FieldType  string `json:"fieldtype,omitempty"`
+	FromString string `json:"fromString,omitempty"`
+	ToString   string `json:"toString,omitempty"`
+}
+
+// history is an entry in an issue's changelog; the changes one user made at once.
+type history struct {
+	Id      string       `json:"id,omitempty"`
+	Author  user         `json:"author"`
+	Created string       `json:"created,omitempty"`
+	Items   []changeItem `json:"items"`
+}
+
+// changelog is what the changelog expansion adds to an issue.
+type changelog struct {
+	StartAt    int       `json:"startAt"`
+	MaxResults int       `json:"maxResults"`
+	Total      int       `json:"total"`
+	Histories  []history `json:"histories"`
+}
+
+// changelogPage is the response of Cloud's issue changelog endpoint.
+type changelogPage struct {
+	StartAt    int       `json:"startAt"`
+	MaxResults int       `json:"maxResults"`
+	Total      int       `json:"total"`
+	IsLast     bool      `json:"isLast"`
+	Values     []history `json:"values"`
+}
+
+// renderedDetails are the fields of an issue rendered as HTML,
+// per the renderedFields expansion.  In the comments, only the
+// Id and Body are of use; Created is something like "2 days ago".
+type renderedDetails struct {
+	Comment *commentPage `json:"comment,omitempty"`
 }
 
 type issueRecord struct {
-	Expand string       `json:"expand,omitempty"`
-	Id     string       `json:"id,omitempty"`
-	Self   string       `json:"self,omitempty"`
-	Key    string       `json:"key,omitempty"`
-	Fields issueDetails `json:"fields"`
+	Expand         string           `json:"expand,omitempty"`
+	Id             string           `json:"id,omitempty"`
+	Self           string           `json:"self,omitempty"`
+	Key            string           `json:"key,omitempty"`
+	Fields         issueDetails     `json:"fields"`
+	RenderedFields *renderedDetails `json:"renderedFields,omitempty"`
+	Changelog      *changelog       `json:"changelog,omitempty"`
+	// rawFields holds all the fields, including those not in Fields.
+	rawFields map[string]json.RawMessage
+	// userComments are the searched-for user's comments, if looked for.
+	userComments []types.MyComment
 }
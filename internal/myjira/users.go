@@ -0,0 +1,125 @@
+package myjira
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/monopole/snips/internal/types"
+)
+
+const (
+	// https://docs.atlassian.com/software/jira/docs/api/REST/9.4.0/#api/2/user-findUsers
+	// https://developer.atlassian.com/cloud/jira/platform/rest/v3/api-group-user-search/#api-rest-api-3-user-search-get
+	userSearchEndpointDataCenter = "rest/api/2/user/search"
+	userSearchEndpointCloud      = "rest/api/3/user/search"
+
+	accountTypeAtlassian = "atlassian"
+
+	// avatarSize is the key of the preferred size in a user's avatarUrls.
+	avatarSize = "48x48"
+)
+
+// errAmbiguousUser means several jira accounts match a user exactly,
+// e.g. two people with the same display name.
+var errAmbiguousUser = errors.New("ambiguous jira user")
+
+// lookupUser returns the jira profile of the given user, searching
+// by email if known, then by login, then by name.
+func (jb *jiraBoss) lookupUser(u *types.MyUser) (*user, error) {
+	var queries []string
+	for _, q := range []string{u.Email, u.Login, u.Name} {
+		if q != "" && !slices.ContainsFunc(queries, func(x string) bool { return strings.EqualFold(x, q) }) {
+			queries = append(queries, q)
+		}
+	}
+	err := types.UnknownUser("jira", u.Login)
+	for _, q := range queries {
+		var found *user
+		if found, err = jb.searchUser(q); err == nil {
+			return found, nil
+		}
+		if !errors.Is(err, types.ErrUnknownUser) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// searchUser returns the one human jira user whose email, display name
+// or (on Data Center) username is the query.  The search itself matches
+// prefixes, e.g. "dave" finds "Dave Jones", so near matches are only
+// mentioned in the error, lest activity be pinned on the wrong person.
+func (jb *jiraBoss) searchUser(query string) (*user, error) {
+	var (
+		loc *url.URL
+		err error
+	)
+	if jb.args.Cloud {
+		loc, err = jb.makeUrl(userSearchEndpointCloud, url.Values{"query": {query}})
+	} else {
+		loc, err = jb.makeUrl(userSearchEndpointDataCenter, url.Values{
+			"username":        {query},
+			"includeInactive": {"true"},
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	var found []user
+	if err = jb.doJiraRequest(http.MethodGet, loc, nil, &found); err != nil {
+		return nil, fmt.Errorf("trouble looking up jira user %q; %w", query, err)
+	}
+	var exact, near []user
+	for i := range found {
+		f := &found[i]
+		if strings.EqualFold(f.EmailAddress, query) || (!jb.args.Cloud && strings.EqualFold(f.Name, query)) {
+			return f, nil
+		}
+		if f.AccountType != "" && f.AccountType != accountTypeAtlassian {
+			continue
+		}
+		if strings.EqualFold(f.DisplayName, query) {
+			exact = append(exact, *f)
+		} else {
+			near = append(near, *f)
+		}
+	}
+	switch len(exact) {
+	case 0:
+		if len(near) == 0 {
+			return nil, types.UnknownUser("jira", query)
+		}
+		return nil, fmt.Errorf("%w; near matches are %s; use an exact email address or name",
+			types.UnknownUser("jira", query), describeUsers(near))
+	case 1:
+		return &exact[0], nil
+	}
+	return nil, fmt.Errorf("%w %q; %d jira users match (%s); use an email address",
+		errAmbiguousUser, query, len(exact), describeUsers(exact))
+}
+
+// describeUsers lists the users' display names, with their emails if known.
+func describeUsers(users []user) string {
+	names := make([]string, len(users))
+	for i := range users {
+		names[i] = users[i].DisplayName
+		if users[i].EmailAddress != "" {
+			names[i] += " <" + users[i].EmailAddress + ">"
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// addJiraProfile fills in what the user lacks from their jira profile,
+// e.g. the name of a user not looked up on GitHub.
+func addJiraProfile(u *types.MyUser, p *user) {
+	u.AddProfile(p.DisplayName, p.EmailAddress, p.AvatarUrls[avatarSize])
+	u.JiraAccount = types.JiraAccountActive
+	if !p.Active {
+		u.JiraAccount = types.JiraAccountInactive
+	}
+}
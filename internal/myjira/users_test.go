@@ -0,0 +1,183 @@
+package myjira
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/monopole/snips/internal/pgmargs"
+	"github.com/monopole/snips/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// dataCenterUsers stands in for the user search of a Jira Data Center
+// instance, matching users whose name, display name or email starts
+// with the query.
+var dataCenterUsers = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/"+userSearchEndpointDataCenter || r.URL.Query().Get("includeInactive") != "true" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	q := strings.ToLower(r.URL.Query().Get("username"))
+	found := []user{}
+	for _, u := range []user{
+		{Name: "bob", DisplayName: "Bob Bobface", EmailAddress: "bob@acme.com", Active: true},
+		{Name: "bobby", DisplayName: "Bobby Tables", EmailAddress: "bobby@acme.com", Active: true},
+		{Name: "carol", DisplayName: "Carol Gone", EmailAddress: "carol@acme.com"},
+		{Name: "dave1", DisplayName: "Dave One", EmailAddress: "dave1@acme.com", Active: true},
+		{Name: "dave2", DisplayName: "Dave Two", EmailAddress: "dave2@acme.com", Active: true},
+		{Name: "edward", DisplayName: "Ed Wood", EmailAddress: "ed@acme.com", Active: true},
+		{Name: "dan1", DisplayName: "Dan Same", EmailAddress: "dan1@acme.com", Active: true},
+		{Name: "dan2", DisplayName: "Dan Same", EmailAddress: "dan2@acme.com", Active: true},
+	} {
+		if strings.HasPrefix(u.Name, q) || strings.HasPrefix(strings.ToLower(u.DisplayName), q) ||
+			strings.HasPrefix(u.EmailAddress, q) {
+			found = append(found, u)
+		}
+	}
+	_ = json.NewEncoder(w).Encode(found)
+})
+
+func Test_lookupUserDataCenter(t *testing.T) {
+	srv := httptest.NewTLSServer(dataCenterUsers)
+	defer srv.Close()
+	jb := MakeJiraBoss(context.Background(), srv.Client(), &pgmargs.JiraArgs{
+		ServiceArgs: pgmargs.ServiceArgs{Domain: strings.TrimPrefix(srv.URL, "https://"), Token: "sekret"},
+	}, nil)
+	tests := map[string]struct {
+		u       types.MyUser
+		name    string
+		account string
+		errIs   error
+		errMsg  string
+	}{
+		"exact login among prefixes": {
+			u:    types.MyUser{Login: "bob"},
+			name: "Bob Bobface", account: types.JiraAccountActive,
+		},
+		"by email": {
+			u:    types.MyUser{Login: "robert", Email: "bobby@acme.com"},
+			name: "Bobby Tables", account: types.JiraAccountActive,
+		},
+		"github email unknown to jira": {
+			u:    types.MyUser{Login: "bob", Email: "bob@home.org"},
+			name: "Bob Bobface", account: types.JiraAccountActive,
+		},
+		"inactive": {
+			u:    types.MyUser{Login: "carol"},
+			name: "Carol Gone", account: types.JiraAccountInactive,
+		},
+		"github name kept": {
+			u:    types.MyUser{Login: "carol", Name: "Carol C."},
+			name: "Carol C.", account: types.JiraAccountInactive,
+		},
+		"by display name": {
+			u:    types.MyUser{Login: "robert", Name: "Bobby Tables"},
+			name: "Bobby Tables", account: types.JiraAccountActive,
+		},
+		"unknown": {
+			u:      types.MyUser{Login: "eve"},
+			errIs:  types.ErrUnknownUser,
+			errMsg: `unknown jira user "eve"`,
+		},
+		"one prefix match isn't enough": {
+			u:      types.MyUser{Login: "ed"},
+			errIs:  types.ErrUnknownUser,
+			errMsg: "near matches are Ed Wood <ed@acme.com>",
+		},
+		"prefix matches": {
+			u:      types.MyUser{Login: "dave"},
+			errIs:  types.ErrUnknownUser,
+			errMsg: "near matches are Dave One <dave1@acme.com>, Dave Two <dave2@acme.com>",
+		},
+		"ambiguous": {
+			u:     types.MyUser{Login: "dsame", Name: "Dan Same"},
+			errIs: errAmbiguousUser,
+			errMsg: `ambiguous jira user "Dan Same"; 2 jira users match ` +
+				"(Dan Same <dan1@acme.com>, Dan Same <dan2@acme.com>); use an email address",
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			profile, err := jb.lookupUser(&tt.u)
+			if tt.errIs != nil {
+				assert.ErrorIs(t, err, tt.errIs)
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+			assert.NoError(t, err)
+			addJiraProfile(&tt.u, profile)
+			assert.Equal(t, tt.name, tt.u.Name)
+			assert.Equal(t, tt.account, tt.u.JiraAccount)
+		})
+	}
+}
+
+// cloudUsers stands in for the user search of Jira Cloud, which matches
+// users whose display name or email starts with the query, and hides
+// the emails of some.
+var cloudUsers = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/"+userSearchEndpointCloud {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	q := strings.ToLower(r.URL.Query().Get("query"))
+	found := []user{}
+	for _, u := range []user{
+		{AccountId: "1", DisplayName: "Bob Bobface", EmailAddress: "bob@acme.com", AccountType: accountTypeAtlassian, Active: true},
+		{AccountId: "2", DisplayName: "Carol Private", AccountType: accountTypeAtlassian, Active: true},
+		{AccountId: "3", DisplayName: "Carol Bot", AccountType: "app", Active: true},
+	} {
+		if strings.HasPrefix(strings.ToLower(u.DisplayName), q) || strings.HasPrefix(u.EmailAddress, q) {
+			found = append(found, u)
+		}
+	}
+	_ = json.NewEncoder(w).Encode(found)
+})
+
+func Test_lookupUserCloud(t *testing.T) {
+	srv := httptest.NewTLSServer(cloudUsers)
+	defer srv.Close()
+	jb := MakeJiraBoss(context.Background(), srv.Client(), &pgmargs.JiraArgs{
+		ServiceArgs: pgmargs.ServiceArgs{Domain: strings.TrimPrefix(srv.URL, "https://"), Token: "sekret"},
+		Cloud:       true,
+	}, nil)
+	tests := map[string]struct {
+		u         types.MyUser
+		accountId string
+		errMsg    string
+	}{
+		"by email": {
+			u:         types.MyUser{Login: "bob", Email: "bob@acme.com"},
+			accountId: "1",
+		},
+		"by display name, email hidden": {
+			u:         types.MyUser{Login: "carol", Name: "Carol Private"},
+			accountId: "2",
+		},
+		"one prefix match isn't enough": {
+			u:      types.MyUser{Login: "bob"},
+			errMsg: `unknown jira user "bob"; near matches are Bob Bobface <bob@acme.com>`,
+		},
+		"apps ignored": {
+			u:      types.MyUser{Login: "carol"},
+			errMsg: `unknown jira user "carol"; near matches are Carol Private;`,
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			profile, err := jb.lookupUser(&tt.u)
+			if tt.errMsg != "" {
+				assert.ErrorIs(t, err, types.ErrUnknownUser)
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.accountId, profile.AccountId)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package myjira
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/monopole/snips/internal/types"
+)
+
+const (
+	// https://docs.atlassian.com/software/jira/docs/api/REST/9.4.0/#api/2/issue/{issueIdOrKey}/worklog-getIssueWorklog
+	// https://developer.atlassian.com/cloud/jira/platform/rest/v3/api-group-issue-worklogs/#api-rest-api-3-issue-issueidorkey-worklog-get
+	worklogEndpointFmtDataCenter = "rest/api/2/issue/%s/worklog"
+	worklogEndpointFmtCloud      = "rest/api/3/issue/%s/worklog"
+
+	maxWorklogResult = 100
+)
+
+// makeTimeLoggedJql finds issues on which the user logged work in the day range.
+// The issues may also hold work logged by others, or outside the range.
+func makeTimeLoggedJql(user string, dayRange *types.DayRange) string {
+	return fmt.Sprintf(
+		"worklogAuthor = %q and worklogDate >= '%s' and worklogDate < '%s'",
+		user,
+		dayRange.StartAsTime().Format(types.DayFormatJira),
+		adjustEndDate(dayRange.EndAsTime()).Format(types.DayFormatJira),
+	)
+}
+
+// findTimeLogged sums, per issue, the work the user logged in the day range.
+func (jb *jiraBoss) findTimeLogged(who string) (*types.WorklogSet, error) {
+	candidates, err := jb.searchIssues(
+		makeTimeLoggedJql(who, jb.dayRange), jb.issueFields("worklog"), searchExpand)
+	if err != nil {
+		return nil, err
+	}
+	result := &types.WorklogSet{
+		Domain: jb.args.Domain,
+		Groups: make(map[types.RepoId][]types.MyWorklog),
+	}
+	for i := range candidates {
+		var logs []worklog
+		if logs, err = jb.allWorklogs(&candidates[i]); err != nil {
+			return nil, err
+		}
+		var spent time.Duration
+		for _, wl := range logs {
+			if jb.isUser(wl.Author, who) && jb.inDayRange(wl.Started) {
+				spent += time.Duration(wl.TimeSpentSeconds) * time.Second
+			}
+		}
+		if spent == 0 {
+			continue
+		}
+		id := repoIdOf(&candidates[i])
+		var issue types.MyIssue
+		if issue, err = jb.convertJiraIssueToGhIssue(id, &candidates[i]); err != nil {
+			return nil, err
+		}
+		result.Groups[id] = append(result.Groups[id], types.MyWorklog{Issue: issue, Spent: spent})
+	}
+	for _, lst := range result.Groups {
+		sort.SliceStable(lst, func(i, j int) bool {
+			return lst[i].Spent > lst[j].Spent
+		})
+	}
+	return result, nil
+}
+
+// allWorklogs returns the worklogs that came with the issue, fetching the rest
+// if the search response held only the first page of them.
+func (jb *jiraBoss) allWorklogs(rec *issueRecord) ([]worklog, error) {
+	page := rec.Fields.Worklog
+	if page != nil && len(page.Worklogs) >= page.Total {
+		return page.Worklogs, nil
+	}
+	endpointFmt := worklogEndpointFmtDataCenter
+	if jb.args.Cloud {
+		endpointFmt = worklogEndpointFmtCloud
+	}
+	var result []worklog
+	for startAt := 0; ; {
+		loc, err := jb.makeUrl(fmt.Sprintf(endpointFmt, rec.Key), url.Values{
+			"startAt":    {strconv.Itoa(startAt)},
+			"maxResults": {strconv.Itoa(maxWorklogResult)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		var resp worklogPage
+		if err = jb.doJiraRequest(http.MethodGet, loc, nil, &resp); err != nil {
+			return nil, err
+		}
+		result = append(result, resp.Worklogs...)
+		startAt += len(resp.Worklogs)
+		if len(resp.Worklogs) == 0 || startAt >= resp.Total {
+			return result, nil
+		}
+	}
+}
+
+// isUser is true if u is the user identified in JQL by who,
+// i.e. by accountId on Cloud, or by name on Data Center.
+func (jb *jiraBoss) isUser(u user, who string) bool {
+	if jb.args.Cloud {
+		return u.AccountId == who
+	}
+	return u.Name == who || u.Key == who
+}
+
+// inDayRange is true if the given jira timestamp falls in the day range.
+func (jb *jiraBoss) inDayRange(raw string) bool {
+	t, err := time.Parse(types.DateFormatJiraIssue, raw)
+	if err != nil {
+		return false
+	}
+	return jb.dayRange.Contains(t)
+}
@@ -3,8 +3,15 @@ package pgmargs
 import (
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/monopole/snips/internal/config"
+	"github.com/monopole/snips/internal/creds"
 	"github.com/monopole/snips/internal/types"
 )
 
@@ -14,6 +21,36 @@ const (
 	flagDayCount    = "day-count"
 	flagNoTokenEcho = "suppress-token-echo"
 	flagMarkdown    = "md"
+	flagFormat      = "format"
+	flagWebhookUrl  = "webhook-url"
+	flagTemplateDir = "template-dir"
+	flagDumpTmpl    = "dump-templates"
+	flagConfig      = "config"
+	flagCredentials = "credentials"
+	flagCaPath      = "ca-path"
+	flagClientCert  = "client-cert"
+	flagClientKey   = "client-key"
+	flagInsecure    = "insecure-skip-tls-verify"
+	flagTrace       = "trace"
+	flagTraceBodies = "trace-bodies"
+	flagTraceFile   = "trace-file"
+	flagLogLevel    = "log-level"
+	flagProgress    = "progress"
+	flagTimeout     = "timeout"
+
+	// EnvPassphrase holds the passphrase encrypting stored tokens, if any.
+	EnvPassphrase = "SNIPS_PASSPHRASE"
+
+	// CmdAuth starts the command line of the auth command,
+	// e.g. "snips auth list", rather than a list of users.
+	CmdAuth = "auth"
+
+	FormatHtml  = "html"
+	FormatMd    = "md"
+	FormatSlack = "slack"
+	FormatTeams = "teams"
+	FormatCsv   = "csv"
+	FormatTsv   = "tsv"
 
 	GithubPublic                = "github.com"
 	githubDomainAcmeCorp        = "github.tesla.com"
@@ -24,8 +61,41 @@ const (
 	envGhToken  = "GH_TOKEN"
 	flagGhToken = "gh-token"
 
+	envGhAppId              = "GH_APP_ID"
+	flagGhAppId             = "gh-app-id"
+	envGhAppKey             = "GH_APP_KEY"
+	flagGhAppKey            = "gh-app-key"
+	envGhAppInstallationId  = "GH_APP_INSTALLATION_ID"
+	flagGhAppInstallationId = "gh-app-installation-id"
+	flagJustGetGhToken      = "just-get-gh-token"
+
 	envJiraToken  = "JIRA_API_TOKEN"
 	flagJiraToken = "jira-token"
+	envJiraEmail  = "JIRA_EMAIL"
+	flagJiraEmail = "jira-email"
+	flagJiraCloud = "jira-cloud"
+	flagJiraPage  = "jira-page-size"
+	flagJiraMax   = "jira-max-issues"
+	flagJiraGroup = "jira-group-by"
+
+	flagGerritDomain   = "gerrit-domain"
+	envGerritUser      = "GERRIT_USER"
+	flagGerritUser     = "gerrit-user"
+	envGerritPassword  = "GERRIT_PASSWORD"
+	flagGerritPassword = "gerrit-password"
+
+	flagBitbucketDomain = "bitbucket-domain"
+	envBitbucketToken   = "BITBUCKET_TOKEN"
+	flagBitbucketToken  = "bitbucket-token"
+
+	// DefaultJiraPageSize is the number of issues asked for per search request.
+	// Servers may return fewer, e.g. Data Center caps it with jira.search.views.default.max.
+	DefaultJiraPageSize = 100
+	// DefaultJiraMaxIssues caps the number of issues fetched per search.
+	DefaultJiraMaxIssues = 10000
+
+	// jiraCloudDomainSuffix identifies Atlassian Cloud instances.
+	jiraCloudDomainSuffix = ".atlassian.net"
 )
 
 // ServiceArgs holds information needed to contact GitHub or Jira (public or enterprise instance).
@@ -35,6 +105,83 @@ type ServiceArgs struct {
 	Token    string
 }
 
+// GhArgs holds information needed to contact GitHub.
+type GhArgs struct {
+	ServiceArgs
+	// ApiUrl is the root of the REST API, if it isn't the usual one for the Domain.
+	ApiUrl string
+}
+
+// TlsArgs say how to trust servers, and how to prove who we are to them.
+type TlsArgs struct {
+	// CaPath, if not empty, is a PEM file of certs to trust besides the system's.
+	CaPath string
+	// CertPath, if not empty, is a PEM file holding a client cert for
+	// mutual TLS, and its key too if KeyPath is empty.
+	CertPath string
+	// KeyPath is a PEM file holding the client cert's key.
+	KeyPath string
+	// Insecure means servers aren't verified at all.
+	Insecure bool
+}
+
+// TraceArgs say whether and where to trace HTTP requests.
+type TraceArgs struct {
+	// On means each request is traced.
+	On bool
+	// Bodies means headers and bodies are traced too.
+	Bodies bool
+	// Path is the file traces are written to; if empty, stderr.
+	Path string
+}
+
+// GhAppArgs identify a GitHub App, to authenticate as one of its
+// installations rather than as a person.
+type GhAppArgs struct {
+	// Id is the app's id (not its client id), or zero if no app is used.
+	Id int64
+	// KeyPath locates the app's private key, a PEM file made in the app's settings.
+	KeyPath string
+	// InstallationId is the installation to act as.  If zero,
+	// the app must have just one installation.
+	InstallationId int64
+}
+
+// JiraArgs holds information needed to contact Jira.
+type JiraArgs struct {
+	ServiceArgs
+	// Email, paired with an API token in Token, is used for basic auth on Jira Cloud.
+	Email string
+	// Cloud means the instance is Atlassian Cloud rather than Jira Data Center.
+	Cloud bool
+	// PageSize is the number of issues to request per search page.
+	PageSize int
+	// MaxIssues caps the number of issues fetched per search.
+	MaxIssues int
+	// GroupBy is how to group jira issues in the report, one of types.AllGroupings().
+	GroupBy string
+	// Config holds jira settings from the config file.
+	Config config.Jira
+}
+
+// GerritArgs holds information needed to contact Gerrit.
+// The Token is an HTTP password from the Gerrit settings page;
+// if empty, Gerrit is queried anonymously.
+type GerritArgs struct {
+	ServiceArgs
+	// User is the Gerrit username owning the HTTP password.
+	User string
+}
+
+// BitbucketArgs holds information needed to contact Bitbucket Server
+// or Data Center.  The Token is an HTTP access token, sent as a bearer
+// token; if empty, Bitbucket is queried anonymously.
+type BitbucketArgs struct {
+	ServiceArgs
+	// Config holds bitbucket settings from the config file.
+	Config config.Bitbucket
+}
+
 // Args holds clean arguments from the command line.
 type Args struct {
 	// UserNames is a slice of usernames to include in the given report.
@@ -42,21 +189,74 @@ type Args struct {
 	// Title is the title for the given report.
 	Title     string
 	DateRange *types.DayRange
-	CaPath    string
-	Gh        ServiceArgs
-	Jira      ServiceArgs
-	// NoTokenEcho if true suppresses echo of the value of a newly discovered GH token.
+	// Tls applies to all connections.
+	Tls TlsArgs
+	// Trace applies to all requests.
+	Trace TraceArgs
+	// LogLevel is the least level of messages logged.
+	LogLevel slog.Level
+	// Progress means show a live status line while collecting,
+	// if stderr is a terminal.
+	Progress bool
+	// Timeout, if positive, limits the whole run; when it passes,
+	// whatever was collected is reported, marked partial.
+	Timeout time.Duration
+	Gh      GhArgs
+	// GhApp, if it has an Id, is used to get GitHub tokens instead of Gh.Token.
+	GhApp GhAppArgs
+	Jira  JiraArgs
+	// Gerrit, if it has a Domain, is searched for code reviews.
+	Gerrit GerritArgs
+	// Bitbucket, if it has a Domain, is searched for pull requests and commits.
+	Bitbucket BitbucketArgs
+	// CredsPath is the credentials file holding saved tokens; if empty, the default.
+	CredsPath string
+	// CredsPassphrase, if not empty, encrypts the saved tokens.
+	CredsPassphrase string
+	// AuthCommand, if not empty, is an auth command and its arguments,
+	// e.g. ["forget", "github.com"], to run instead of making a report.
+	AuthCommand []string
+	// ConfigPath is the config file; if empty, the default.
+	ConfigPath string
+	// NoTokenEcho if true suppresses the reminder about where a newly discovered GH token was saved.
 	NoTokenEcho bool
 	// JustGetGhToken allows execution to get a token if no usernames are specified.
 	// Further, the output is ONLY the token.
 	JustGetGhToken bool
-	// Markdown means emit markdown rather than HTML in the report.
-	Markdown bool
+	// Format is the report format, one of AllFormats().
+	Format string
+	// WebhookUrl, if not empty, is where the report is POSTed instead of
+	// being written to stdout.  Only JSON formats may be posted.
+	WebhookUrl string
+	// TemplateDir, if not empty, holds template files overriding
+	// the built-in templates of the html or md formats.
+	TemplateDir string
+	// DumpTemplatesDir, if not empty, is where to write the built-in
+	// templates of the chosen format, instead of making a report.
+	DumpTemplatesDir string
 	// TestRenderOnly means generate fake data for rendering rather than
 	// making calls to github or jira.
 	TestRenderOnly bool
 	// SkipGh means don't look at GH, just do jira. awful.
 	SkipGh bool
+	// Config is the content of the config file, if any.
+	Config *config.Config
+}
+
+func defaultConfigPath() string {
+	p, err := config.DefaultPath()
+	if err != nil {
+		return "none"
+	}
+	return p
+}
+
+func defaultCredsPath() string {
+	p, err := creds.DefaultPath()
+	if err != nil {
+		return "none"
+	}
+	return p
 }
 
 // ParseArgs parses and validates arguments from the command line.
@@ -67,41 +267,146 @@ func ParseArgs() (*Args, error) {
 		dayStart string
 		dayEnd   string
 		dayCount int
+		markdown bool
+		logLevel string
 	)
 
 	flag.IntVar(&dayCount, flagDayCount, 0, "how many days, inclusive of start date")
 	flag.StringVar(&dayStart, flagDayStart, "", "the day to start, formatted as "+types.DateOptions())
 	flag.StringVar(&dayEnd, flagDayEnd, "", "the day to end, formatted as "+types.DateOptions()+", (default today)")
 	flag.StringVar(&result.Title, "title", "", "the title of the report")
-	flag.BoolVar(&result.Markdown, flagMarkdown, false, "emit markdown instead of HTML")
-	flag.StringVar(&result.CaPath, "ca-path", "", "local path to cert file for TLS in oauth dance")
+	flag.BoolVar(&markdown, flagMarkdown, false, fmt.Sprintf("emit markdown instead of HTML (same as --%s %s)", flagFormat, FormatMd))
+	flag.StringVar(&result.Format, flagFormat, FormatHtml, "the report format, one of "+strings.Join(AllFormats(), ", "))
+	flag.StringVar(&result.WebhookUrl, flagWebhookUrl, "",
+		fmt.Sprintf("POST the report to this webhook url rather than writing it to stdout (requires --%s %s or %s)",
+			flagFormat, FormatSlack, FormatTeams))
+	flag.StringVar(&result.Tls.CaPath, flagCaPath, "",
+		"path to a PEM file of CA certs to trust, besides the system's, e.g. a corporate root")
+	flag.StringVar(&result.Tls.CertPath, flagClientCert, "",
+		"path to a PEM file of a client cert, for servers or gateways requiring mutual TLS")
+	flag.StringVar(&result.Tls.KeyPath, flagClientKey, "",
+		fmt.Sprintf("path to a PEM file of the --%s key (default the cert file)", flagClientCert))
+	flag.BoolVar(&result.Tls.Insecure, flagInsecure, false,
+		"DANGEROUS: don't verify servers' TLS certs; tokens may be sent to impostors")
+	flag.BoolVar(&result.Trace.On, flagTrace, false,
+		"trace each HTTP request, with its status, latency and rate limits, to stderr; secrets are redacted")
+	flag.BoolVar(&result.Trace.Bodies, flagTraceBodies, false,
+		fmt.Sprintf("trace headers and bodies too (implies --%s)", flagTrace))
+	flag.StringVar(&result.Trace.Path, flagTraceFile, "",
+		fmt.Sprintf("write traces to this file rather than stderr (implies --%s)", flagTrace))
+	flag.StringVar(&logLevel, flagLogLevel, "info", "the least level of messages logged: debug, info, warn or error")
+	flag.BoolVar(&result.Progress, flagProgress, false,
+		"while collecting, show the user, query, pages, API quota and time remaining on a status line (only on a terminal)")
+	flag.DurationVar(&result.Timeout, flagTimeout, 0,
+		"stop collecting after this long, e.g. 10m, and report what was gathered, marked partial (default no limit)")
+	flag.StringVar(&result.ConfigPath, flagConfig, "", "path to a YAML config file (default "+defaultConfigPath()+")")
+	flag.StringVar(&result.CredsPath, flagCredentials, "",
+		fmt.Sprintf("path to the file of saved tokens (default %s); env var %s, if set, encrypts them",
+			defaultCredsPath(), EnvPassphrase))
+
+	flag.StringVar(&result.TemplateDir, flagTemplateDir, "",
+		fmt.Sprintf("directory of *.tmpl files overriding the built-in %s or %s templates", FormatHtml, FormatMd))
+	flag.StringVar(&result.DumpTemplatesDir, flagDumpTmpl, "",
+		fmt.Sprintf("write the built-in templates of the --%s to this directory, then exit", flagFormat))
 
 	flag.BoolVar(&result.SkipGh, "skip-gh", false, "ignore GH, just hit jira")
-	flag.BoolVar(&result.JustGetGhToken, "just-get-gh-token", false, "force github login, return the gh-token")
+	flag.BoolVar(&result.JustGetGhToken, flagJustGetGhToken, false, "force github login, return the gh-token")
 	flag.BoolVar(&result.TestRenderOnly, "test", false, "generate test data instead of talking to github or jira")
 	flag.StringVar(&result.Gh.Domain, "gh-domain", GithubPublic, "the github domain")
 	flag.StringVar(&result.Gh.ClientId, "gh-client-id", "", "the oauth clientID from github")
 	flag.StringVar(&result.Gh.Token, flagGhToken, "",
 		fmt.Sprintf("access token for the given GitHub domain (overrides env var %s)", envGhToken))
+	flag.Int64Var(&result.GhApp.Id, flagGhAppId, 0,
+		fmt.Sprintf("id of a GitHub App to authenticate as, for unattended runs (overrides env var %s)", envGhAppId))
+	flag.StringVar(&result.GhApp.KeyPath, flagGhAppKey, "",
+		fmt.Sprintf("path to the GitHub App's private key PEM file (overrides env var %s)", envGhAppKey))
+	flag.Int64Var(&result.GhApp.InstallationId, flagGhAppInstallationId, 0,
+		fmt.Sprintf("id of the GitHub App's installation to act as, if it has more than one (overrides env var %s)",
+			envGhAppInstallationId))
 
 	flag.StringVar(&result.Jira.Domain, "jira-domain", jiraDomainAcmeCorp, "the jira domain")
 	flag.StringVar(&result.Jira.Token, flagJiraToken, "",
 		fmt.Sprintf("access token for the given Jira domain (overrides env var %s)", envJiraToken))
 
+	flag.StringVar(&result.Jira.Email, flagJiraEmail, "",
+		fmt.Sprintf("email address to pair with the Jira Cloud API token (overrides env var %s)", envJiraEmail))
+	flag.BoolVar(&result.Jira.Cloud, flagJiraCloud, false,
+		fmt.Sprintf("the jira domain is Atlassian Cloud (implied by a domain ending in %s)", jiraCloudDomainSuffix))
+	flag.IntVar(&result.Jira.PageSize, flagJiraPage, DefaultJiraPageSize, "number of issues per jira search request")
+	flag.IntVar(&result.Jira.MaxIssues, flagJiraMax, DefaultJiraMaxIssues,
+		"maximum number of issues to fetch per jira search; the report warns if results are cut off")
+	flag.StringVar(&result.Jira.GroupBy, flagJiraGroup, types.GroupByProject,
+		"group jira issues by one of "+strings.Join(types.AllGroupings(), ", "))
+
+	flag.StringVar(&result.Gerrit.Domain, flagGerritDomain, "", "a gerrit domain to search for code reviews (default none)")
+	flag.StringVar(&result.Gerrit.User, flagGerritUser, "",
+		fmt.Sprintf("gerrit username owning the HTTP password (overrides env var %s)", envGerritUser))
+	flag.StringVar(&result.Gerrit.Token, flagGerritPassword, "",
+		fmt.Sprintf("gerrit HTTP password (overrides env var %s); if none, gerrit is queried anonymously", envGerritPassword))
+
+	flag.StringVar(&result.Bitbucket.Domain, flagBitbucketDomain, "",
+		"a bitbucket server or data center domain to search for pull requests and commits (default none)")
+	flag.StringVar(&result.Bitbucket.Token, flagBitbucketToken, "",
+		fmt.Sprintf("bitbucket HTTP access token (overrides env var %s); if none, bitbucket is queried anonymously", envBitbucketToken))
+
 	flag.BoolVar(&result.NoTokenEcho, flagNoTokenEcho,
-		false, fmt.Sprintf("don't echo the value of tokens (over-the-shoulder security)"))
+		false, "don't remind where a newly obtained GitHub token was saved")
 
 	flag.Parse()
 
+	if result.Trace.Bodies || result.Trace.Path != "" {
+		result.Trace.On = true
+	}
+	if err = result.LogLevel.UnmarshalText([]byte(logLevel)); err != nil {
+		return nil, fmt.Errorf("bad --%s; %w", flagLogLevel, err)
+	}
+	if result.Timeout < 0 {
+		return nil, fmt.Errorf("--%s must not be negative", flagTimeout)
+	}
+	result.CredsPassphrase = os.Getenv(EnvPassphrase)
+	if flag.NArg() > 0 && flag.Arg(0) == CmdAuth {
+		result.AuthCommand = flag.Args()[1:]
+		if len(result.AuthCommand) == 0 || !slices.Contains(AllAuthCommands(), result.AuthCommand[0]) {
+			return nil, fmt.Errorf("%s needs one of %s", CmdAuth, strings.Join(AllAuthCommands(), ", "))
+		}
+		return &result, nil
+	}
+
 	// All the arguments should be usernames.
 	result.UserNames = flag.Args()
+	if markdown {
+		result.Format = FormatMd
+	}
+	if result.DumpTemplatesDir != "" {
+		if result.Format != FormatHtml && result.Format != FormatMd {
+			return nil, fmt.Errorf("--%s requires --%s %s or %s", flagDumpTmpl, flagFormat, FormatHtml, FormatMd)
+		}
+		return &result, nil
+	}
 	if !result.TestRenderOnly && len(result.UserNames) == 0 && !result.JustGetGhToken {
 		return nil, fmt.Errorf("no users specified")
 	}
 
+	if result.Config, err = config.Load(result.ConfigPath); err != nil {
+		return nil, err
+	}
+	result.Jira.Config = result.Config.Jira
+	result.Bitbucket.Config = result.Config.Bitbucket
+
+	if strings.HasSuffix(result.Jira.Domain, jiraCloudDomainSuffix) {
+		result.Jira.Cloud = true
+	}
 	if result.Jira.Token == "" {
 		result.Jira.Token = os.Getenv(envJiraToken)
-		if !result.TestRenderOnly && result.Jira.Token == "" {
+		if !result.TestRenderOnly && result.Jira.Token == "" && result.Jira.Cloud {
+			fmt.Fprintf(
+				os.Stderr,
+				"To include issue data from Jira, set env vars %s and %s to your email and an API token obtained from %s\n",
+				envJiraEmail,
+				envJiraToken,
+				"https://id.atlassian.com/manage-profile/security/api-tokens",
+			)
+		} else if !result.TestRenderOnly && result.Jira.Token == "" {
 			fmt.Fprintf(
 				os.Stderr,
 				"To include issue data from Jira, set env var %s to a personal access token value obtained from https://%s/secure/ViewProfile.jspa?%s\n",
@@ -112,16 +417,80 @@ func ParseArgs() (*Args, error) {
 		}
 	}
 
+	if result.Jira.PageSize < 1 {
+		return nil, fmt.Errorf("--%s must be positive", flagJiraPage)
+	}
+	if result.Jira.MaxIssues < 1 {
+		return nil, fmt.Errorf("--%s must be positive", flagJiraMax)
+	}
+	if !slices.Contains(types.AllGroupings(), result.Jira.GroupBy) {
+		return nil, fmt.Errorf(
+			"--%s must be one of %s", flagJiraGroup, strings.Join(types.AllGroupings(), ", "))
+	}
+
+	if result.Jira.Cloud {
+		if result.Jira.Email == "" {
+			result.Jira.Email = os.Getenv(envJiraEmail)
+		}
+		if result.Jira.Token != "" && result.Jira.Email == "" {
+			return nil, fmt.Errorf(
+				"jira cloud needs the email address that owns the API token; use --%s or env var %s",
+				flagJiraEmail, envJiraEmail)
+		}
+	}
+
+	if result.Gerrit.User == "" {
+		result.Gerrit.User = os.Getenv(envGerritUser)
+	}
+	if result.Gerrit.Token == "" {
+		result.Gerrit.Token = os.Getenv(envGerritPassword)
+	}
+	if result.Gerrit.Token != "" && result.Gerrit.User == "" {
+		return nil, fmt.Errorf(
+			"a gerrit HTTP password needs a username; use --%s or env var %s", flagGerritUser, envGerritUser)
+	}
+
+	if result.Bitbucket.Token == "" {
+		result.Bitbucket.Token = os.Getenv(envBitbucketToken)
+	}
+
+	if result.Tls.KeyPath != "" && result.Tls.CertPath == "" {
+		return nil, fmt.Errorf("--%s needs --%s", flagClientKey, flagClientCert)
+	}
+	if result.Tls.Insecure && result.Tls.CaPath != "" {
+		return nil, fmt.Errorf("--%s makes no sense with --%s", flagCaPath, flagInsecure)
+	}
+
 	if result.Gh.Token == "" {
 		result.Gh.Token = os.Getenv(envGhToken)
 		// If Gh.Token still empty, user will be prompted.
 	}
 
-	if !result.TestRenderOnly && result.Gh.ClientId == "" {
-		result.Gh.ClientId, err = determineClientIdFromDomain(result.Gh.Domain)
-		if err != nil {
-			return nil, err
-		}
+	if err = loadGhAppArgs(&result.GhApp); err != nil {
+		return nil, err
+	}
+	if result.GhApp.Id != 0 && result.JustGetGhToken {
+		return nil, fmt.Errorf("--%s makes no sense with a GitHub App", flagJustGetGhToken)
+	}
+
+	if d := result.Config.GitHub.FindDomain(result.Gh.Domain); d != nil {
+		result.Gh.ApiUrl = d.ApiUrl
+	}
+	if !result.TestRenderOnly && result.Gh.ClientId == "" && result.GhApp.Id == 0 {
+		// If still empty, there's no logging in; the token must be given or saved.
+		result.Gh.ClientId = determineClientIdFromDomain(result.Gh.Domain, &result.Config.GitHub)
+	}
+
+	if !slices.Contains(AllFormats(), result.Format) {
+		return nil, fmt.Errorf("unknown --%s %q, use one of %s",
+			flagFormat, result.Format, strings.Join(AllFormats(), ", "))
+	}
+	if result.TemplateDir != "" && result.Format != FormatHtml && result.Format != FormatMd {
+		return nil, fmt.Errorf("--%s requires --%s %s or %s", flagTemplateDir, flagFormat, FormatHtml, FormatMd)
+	}
+	if result.WebhookUrl != "" && result.Format != FormatSlack && result.Format != FormatTeams {
+		return nil, fmt.Errorf("--%s requires --%s %s or %s",
+			flagWebhookUrl, flagFormat, FormatSlack, FormatTeams)
 	}
 
 	if dayStart != "" && dayEnd != "" && dayCount > 0 {
@@ -135,29 +504,68 @@ func ParseArgs() (*Args, error) {
 	return &result, nil
 }
 
-// determineClientIdFromDomain returns a hardcoded clientId as a function of the domain.
-// The only time a user would specify the clientId on the command line would be when
-// registering / re-registering this program with some GitHub server.
-func determineClientIdFromDomain(domain string) (string, error) {
-	if domain == githubDomainAcmeCorp {
-		return githubAcmeCorpOAuthClientId, nil
+// loadGhAppArgs fills in app args missing from the command line
+// from the environment, and checks that they go together.
+func loadGhAppArgs(app *GhAppArgs) error {
+	var err error
+	if app.Id == 0 {
+		if app.Id, err = int64FromEnv(envGhAppId); err != nil {
+			return err
+		}
+	}
+	if app.KeyPath == "" {
+		app.KeyPath = os.Getenv(envGhAppKey)
+	}
+	if app.InstallationId == 0 {
+		if app.InstallationId, err = int64FromEnv(envGhAppInstallationId); err != nil {
+			return err
+		}
+	}
+	if (app.Id == 0) != (app.KeyPath == "") {
+		return fmt.Errorf("a GitHub App needs both --%s and --%s", flagGhAppId, flagGhAppKey)
 	}
-	if domain != GithubPublic {
-		return "", fmt.Errorf("i have no client id registered with %s", domain)
+	if app.Id == 0 && app.InstallationId != 0 {
+		return fmt.Errorf("--%s needs --%s", flagGhAppInstallationId, flagGhAppId)
 	}
-	return githubPublicOAuthClientId, nil
+	return nil
 }
 
-func EchoToken(prefix, token string) {
-	fmt.Fprintf(
-		os.Stderr, `
-%s Login successful.
-%s     In subsequent calls, add this flag:  --%s %s
-%s                     or export this var:  export %s=%s
-%s To suppress this reminder, use --%s
-`[1:],
-		prefix,
-		prefix, flagGhToken, token,
-		prefix, envGhToken, token,
-		prefix, flagNoTokenEcho)
+// int64FromEnv returns the number in the env var, or zero if it's not set.
+func int64FromEnv(name string) (int64, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("env var %s must be a number; %w", name, err)
+	}
+	return n, nil
+}
+
+// AllAuthCommands returns the commands that may follow CmdAuth.
+func AllAuthCommands() []string {
+	return append(creds.AllCommands(), config.CmdAddDomain)
+}
+
+// AllFormats returns the allowed values of --format.
+func AllFormats() []string {
+	return []string{FormatHtml, FormatMd, FormatSlack, FormatTeams, FormatCsv, FormatTsv}
+}
+
+// determineClientIdFromDomain returns the OAuth clientId registered for the domain
+// in the config, else a hardcoded one, else empty.  The only time a user would
+// specify the clientId on the command line would be when registering /
+// re-registering this program with some GitHub server.
+func determineClientIdFromDomain(domain string, gh *config.GitHub) string {
+	if d := gh.FindDomain(domain); d != nil {
+		return d.ClientId
+	}
+	switch domain {
+	case GithubPublic:
+		return githubPublicOAuthClientId
+	case githubDomainAcmeCorp:
+		return githubAcmeCorpOAuthClientId
+	}
+	return ""
 }
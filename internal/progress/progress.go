@@ -0,0 +1,229 @@
+// Package progress shows what a long collection is up to, on one
+// status line redrawn on a terminal: the source and user being searched,
+// the query, pages fetched, API quota left and the time remaining.
+//
+// Like log/slog, the package functions act on a default, here the
+// Tracker started last; without one they do nothing.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/monopole/snips/internal/types"
+)
+
+const (
+	// redrawInterval is how often the status line is redrawn,
+	// to keep its clock and ETA fresh.
+	redrawInterval = 500 * time.Millisecond
+	// lineWidth is how wide the status line may be; wider
+	// would wrap, and redrawing a wrapped line makes a mess.
+	lineWidth = 79
+	// minQueryWidth is the least of a query worth showing.
+	minQueryWidth = 12
+	// clearLine returns the cursor to the start of the line, and clears it.
+	clearLine = "\r\033[K"
+)
+
+var current atomic.Pointer[Tracker]
+
+// Tracker tracks progress through a number of tasks, e.g. searching
+// one source for one user, drawing a status line on a terminal.
+type Tracker struct {
+	mu sync.Mutex
+	w  io.Writer
+	// total is the expected number of tasks; done have finished.
+	total int
+	done  int
+	// started is when the tracker started, to estimate the time remaining.
+	started time.Time
+	now     func() time.Time
+	// source and user describe the current task.
+	source string
+	user   string
+	// query is what the current task is asking.
+	query string
+	// pages counts the responses received for the current task.
+	pages int
+	// quota is the remaining API quota, if known, e.g. "27/30".
+	quota string
+	// shown is true if the status line is on screen.
+	shown bool
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// IsTerminal is true if f is a terminal, so a status line may be drawn on it.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Start returns a running tracker of the given number of tasks,
+// drawing its status line on w, and makes it the default.
+func Start(w io.Writer, tasks int) *Tracker {
+	t := makeTracker(w, tasks, time.Now)
+	current.Store(t)
+	t.wg.Add(1)
+	go t.redrawLoop()
+	return t
+}
+
+func makeTracker(w io.Writer, tasks int, now func() time.Time) *Tracker {
+	return &Tracker{w: w, total: tasks, started: now(), now: now, stop: make(chan struct{})}
+}
+
+func (t *Tracker) redrawLoop() {
+	defer t.wg.Done()
+	ticker := time.NewTicker(redrawInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			t.mu.Lock()
+			t.draw()
+			t.mu.Unlock()
+		}
+	}
+}
+
+// Stop erases the status line, and stops the tracker being the default.
+func (t *Tracker) Stop() {
+	current.CompareAndSwap(t, nil)
+	close(t.stop)
+	t.wg.Wait()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.erase()
+}
+
+// Write writes p above the status line, so that log output
+// and the status line don't garble each other.
+func (t *Tracker) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.erase()
+	n, err := t.w.Write(p)
+	t.draw()
+	return n, err
+}
+
+// Writer returns a writer to w that, while a tracker drawing
+// on w is running, writes through that tracker.
+func Writer(w io.Writer) io.Writer {
+	return &writer{w: w}
+}
+
+type writer struct {
+	w io.Writer
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if t := current.Load(); t != nil && t.w == w.w {
+		return t.Write(p)
+	}
+	return w.w.Write(p)
+}
+
+// Task finishes the current task, if any, and starts one
+// searching the given source for the given user.
+func Task(source string, user string) {
+	update(func(t *Tracker) {
+		if t.source != "" {
+			t.done++
+		}
+		t.source, t.user, t.query, t.pages = source, user, "", 0
+	})
+}
+
+// Query notes what the current task is asking, e.g. a search query.
+func Query(q string) {
+	update(func(t *Tracker) { t.query = q })
+}
+
+// Page notes that a response arrived for the current task.
+func Page() {
+	update(func(t *Tracker) { t.pages++ })
+}
+
+// Quota notes the API quota remaining, of the given limit.
+func Quota(remaining int, limit int) {
+	update(func(t *Tracker) { t.quota = fmt.Sprintf("%d/%d", remaining, limit) })
+}
+
+func update(f func(t *Tracker)) {
+	t := current.Load()
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f(t)
+	t.draw()
+}
+
+func (t *Tracker) erase() {
+	if t.shown {
+		_, _ = io.WriteString(t.w, clearLine)
+		t.shown = false
+	}
+}
+
+func (t *Tracker) draw() {
+	if t.source == "" {
+		return
+	}
+	_, _ = io.WriteString(t.w, clearLine+t.status())
+	t.shown = true
+}
+
+// status returns the status line, e.g.
+// "github 2/6 alice · author:alice · page 3 · quota 27/30 · ETA 1m20s",
+// shortening the query to fit.
+func (t *Tracker) status() string {
+	const sep = " · "
+	head := fmt.Sprintf("%s %d/%d %s", t.source, t.done+1, t.total, t.user)
+	var tail []string
+	if t.pages > 0 {
+		tail = append(tail, fmt.Sprintf("page %d", t.pages))
+	}
+	if t.quota != "" {
+		tail = append(tail, "quota "+t.quota)
+	}
+	if eta := t.eta(); eta > 0 {
+		tail = append(tail, "ETA "+eta.String())
+	}
+	parts := []string{head}
+	if t.query != "" {
+		rest := strings.Join(append([]string{head}, tail...), sep)
+		room := lineWidth - utf8.RuneCountInString(rest+sep)
+		if room >= minQueryWidth {
+			parts = append(parts, truncate(t.query, room))
+		}
+	}
+	return truncate(strings.Join(append(parts, tail...), sep), lineWidth)
+}
+
+// eta estimates the time remaining from the average time per task
+// so far, or returns zero if nothing's finished.
+func (t *Tracker) eta() time.Duration {
+	if t.done == 0 || t.done >= t.total {
+		return 0
+	}
+	perTask := t.now().Sub(t.started) / time.Duration(t.done)
+	return (perTask * time.Duration(t.total-t.done)).Round(time.Second)
+}
+
+// truncate collapses the whitespace in s, then clips it to at most n runes.
+func truncate(s string, n int) string {
+	return types.Clip(strings.Join(strings.Fields(s), " "), n)
+}
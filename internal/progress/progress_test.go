@@ -0,0 +1,87 @@
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// install makes a tracker with a fake clock the default, without its redraw loop.
+func install(t *testing.T, w *bytes.Buffer, tasks int) (*Tracker, *time.Time) {
+	now := time.Date(2023, 1, 3, 9, 0, 0, 0, time.UTC)
+	tr := makeTracker(w, tasks, func() time.Time { return now })
+	current.Store(tr)
+	t.Cleanup(func() { current.Store(nil) })
+	return tr, &now
+}
+
+func TestStatus(t *testing.T) {
+	var b bytes.Buffer
+	tr, now := install(t, &b, 4)
+	assert.Equal(t, "", b.String(), "nothing drawn before the first task")
+
+	Task("github", "alice")
+	Query("author:alice")
+	Page()
+	Page()
+	Quota(27, 30)
+	assert.Equal(t,
+		"github 1/4 alice · author:alice · page 2 · quota 27/30",
+		tr.status())
+
+	*now = now.Add(30 * time.Second)
+	Task("github", "bob")
+	assert.Equal(t, "github 2/4 bob · quota 27/30 · ETA 1m30s", tr.status())
+	assert.True(t, strings.HasSuffix(b.String(), clearLine+tr.status()))
+
+	*now = now.Add(90 * time.Second)
+	Task("jira", "alice")
+	Query("assignee = alice and\n  updated >= '2023/01/03' and updated < '2023/01/18' order by updated desc")
+	Page()
+	assert.Equal(t,
+		"jira 3/4 alice · assignee = alice and update… · page 1 · quota 27/30 · ETA 2m0s",
+		tr.status())
+	assert.Equal(t, lineWidth, len([]rune(tr.status())))
+}
+
+func TestWriteAboveStatus(t *testing.T) {
+	var b bytes.Buffer
+	tr, _ := install(t, &b, 1)
+	w := Writer(&b)
+
+	_, err := w.Write([]byte("before\n"))
+	assert.NoError(t, err)
+	Task("gerrit", "alice")
+	b.Reset()
+	_, err = w.Write([]byte("level=INFO msg=hello\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, clearLine+"level=INFO msg=hello\n"+clearLine+"gerrit 1/1 alice", b.String())
+
+	current.Store(nil)
+	b.Reset()
+	_, err = w.Write([]byte("after\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "after\n", b.String())
+	tr.erase()
+}
+
+func TestNoTracker(t *testing.T) {
+	// Nothing happens, and nothing panics.
+	Task("github", "alice")
+	Query("author:alice")
+	Page()
+	Quota(1, 30)
+}
+
+func TestStartStop(t *testing.T) {
+	// The tracker writes only while holding its lock, and Stop waits for its redraw loop.
+	var b bytes.Buffer
+	tr := Start(&b, 2)
+	Task("github", "alice")
+	tr.Stop()
+	assert.Nil(t, current.Load())
+	assert.True(t, strings.HasSuffix(b.String(), clearLine))
+}
@@ -1,6 +1,11 @@
 package common
 
 import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,12 +16,16 @@ import (
 func MakeFuncMap() map[string]interface{} {
 	return map[string]interface{}{
 		"toUpper": strings.ToUpper,
+		"join":    strings.Join,
 		"shaSmall": func(s string) string {
 			return s[0:7]
 		},
 		"snipDate": func(t time.Time) string {
 			return t.Format(types.DayFormatHuman)
 		},
+		"snipTime": func(t time.Time) string {
+			return t.Format(types.TimeFormatHuman)
+		},
 		"prettyDateRange": func(dr *types.DayRange) string {
 			return dr.PrettyRange()
 		},
@@ -63,18 +72,89 @@ func MakeFuncMap() map[string]interface{} {
 				GhOrgs []types.MyGhOrg
 			}{Dgh: dGh, GhOrgs: o}
 		},
-		"lowerHyphen": func(what string) string {
-			return strings.ReplaceAll(strings.ToLower(what), " ", "-")
-		},
+		"lowerHyphen":          lowerHyphen,
+		"anchor":               Anchor,
+		"userIssueSet":         UserIssueSet,
+		"userCommitMap":        UserCommitMap,
+		"tableOfContent":       TableOfContent,
+		"isBot":                IsAutomated,
+		"jiraFlag":             JiraFlag,
+		"userWorklogSet":       UserWorklogSet,
+		"labeledWorklogSet":    LabeledWorklogSet,
+		"hours":                Hours,
+		"issueGroup":           IssueGroup,
+		"userTransitionSet":    UserTransitionSet,
+		"labeledTransitionSet": LabeledTransitionSet,
 	}
 }
 
+func lowerHyphen(what string) string {
+	return strings.ReplaceAll(strings.ToLower(what), " ", "-")
+}
+
+// Anchor returns an HTML element id made from the given parts,
+// e.g. ("bob", "Issues Created") yields "bob-issues-created".
+func Anchor(parts ...string) string {
+	return lowerHyphen(strings.Join(parts, " "))
+}
+
 type DomainAndRepo struct {
 	Dgh string
 	Rid types.RepoId
+	// GroupedBy is how jira issues are grouped, one of types.GroupBy*.
+	// If empty, Rid is a GitHub repo or a jira project.
+	GroupedBy string
+}
+
+// IssueGroup is DomainAndRepo for the group of an issue set.
+func IssueGroup(domain string, groupedBy string, rid types.RepoId) *DomainAndRepo {
+	return &DomainAndRepo{Dgh: domain, Rid: rid, GroupedBy: groupedBy}
+}
+
+// Label returns the name of the repo, project, epic or sprint.
+func (dr DomainAndRepo) Label() string {
+	if dr.Rid.Host != "" {
+		return dr.Rid.String()
+	}
+	switch dr.GroupedBy {
+	case types.GroupByEpic:
+		if dr.Rid.Name == "" {
+			return "no epic"
+		}
+		if dr.Rid.Org == "" {
+			return dr.Rid.Name
+		}
+		return dr.Rid.Name + " " + dr.Rid.Org
+	case types.GroupBySprint:
+		if dr.Rid.Name == "" {
+			return "no sprint"
+		}
+		return dr.Rid.Name
+	}
+	return dr.Rid.String()
 }
 
 func (dr DomainAndRepo) HRef() string {
+	// These repos aren't on the report's domains.
+	switch dr.Rid.Host {
+	case types.HostGerrit:
+		return dr.Rid.Org + "/q/project:" + dr.Rid.Name
+	case types.HostBitbucket:
+		project, slug, _ := strings.Cut(dr.Rid.Name, "/")
+		return dr.Rid.Org + "/projects/" + project + "/repos/" + slug
+	}
+	switch dr.GroupedBy {
+	case types.GroupByEpic:
+		if dr.Rid.Name == "" {
+			return dr.Dgh
+		}
+		return dr.Dgh + "/browse/" + dr.Rid.Name
+	case types.GroupBySprint:
+		if dr.Rid.Name == "" {
+			return dr.Dgh
+		}
+		return dr.Dgh + "/issues/?jql=" + url.QueryEscape(fmt.Sprintf("sprint = %q", dr.Rid.Name))
+	}
 	if strings.Contains(dr.Dgh, "github") {
 		// Try to make a GitHub link.
 		return dr.Dgh + "/" + dr.Rid.String()
@@ -83,17 +163,171 @@ func (dr DomainAndRepo) HRef() string {
 	return dr.Dgh + "/projects/" + dr.Rid.Name + "/issues"
 }
 
+// LabeledCommits is the data for a labeled commit section.
+type LabeledCommits struct {
+	// Anchor is the element id of the section.
+	Anchor string
+	Label  string
+	Dgh    string
+	M      map[types.RepoId][]*types.MyCommit
+}
+
+// LabeledIssues is the data for a labeled issue section.
+type LabeledIssues struct {
+	// Anchor is the element id of the section.
+	Anchor string
+	Label  string
+	ISet   *types.IssueSet
+}
+
 func LabeledCommitMap(l string, dGh string, m map[types.RepoId][]*types.MyCommit) interface{} {
-	return &struct {
-		Label string
-		Dgh   string
-		M     map[types.RepoId][]*types.MyCommit
-	}{Label: l, Dgh: dGh, M: m}
+	return &LabeledCommits{Anchor: Anchor(l), Label: l, Dgh: dGh, M: m}
 }
 
 func LabeledIssueSet(l string, iSet *types.IssueSet) interface{} {
-	return &struct {
-		Label string
-		ISet  *types.IssueSet
-	}{Label: l, ISet: iSet}
+	return &LabeledIssues{Anchor: Anchor(l), Label: l, ISet: iSet}
+}
+
+// UserCommitMap is LabeledCommitMap with an anchor unique to the user.
+func UserCommitMap(login string, l string, dGh string, m map[types.RepoId][]*types.MyCommit) interface{} {
+	return &LabeledCommits{Anchor: Anchor(login, l), Label: l, Dgh: dGh, M: m}
+}
+
+// UserIssueSet is LabeledIssueSet with an anchor unique to the user.
+func UserIssueSet(login string, l string, iSet *types.IssueSet) interface{} {
+	return &LabeledIssues{Anchor: Anchor(login, l), Label: l, ISet: iSet}
+}
+
+// LabeledWorklogs is the data for a labeled time logged section.
+type LabeledWorklogs struct {
+	// Anchor is the element id of the section.
+	Anchor string
+	Label  string
+	WSet   *types.WorklogSet
+}
+
+func LabeledWorklogSet(l string, wSet *types.WorklogSet) interface{} {
+	return &LabeledWorklogs{Anchor: Anchor(l), Label: l, WSet: wSet}
+}
+
+// UserWorklogSet is LabeledWorklogSet with an anchor unique to the user.
+func UserWorklogSet(login string, l string, wSet *types.WorklogSet) interface{} {
+	return &LabeledWorklogs{Anchor: Anchor(login, l), Label: l, WSet: wSet}
+}
+
+// LabeledTransitions is the data for a labeled status transition section.
+type LabeledTransitions struct {
+	// Anchor is the element id of the section.
+	Anchor string
+	Label  string
+	TSet   *types.TransitionSet
+}
+
+func LabeledTransitionSet(l string, tSet *types.TransitionSet) interface{} {
+	return &LabeledTransitions{Anchor: Anchor(l), Label: l, TSet: tSet}
+}
+
+// UserTransitionSet is LabeledTransitionSet with an anchor unique to the user.
+func UserTransitionSet(login string, l string, tSet *types.TransitionSet) interface{} {
+	return &LabeledTransitions{Anchor: Anchor(login, l), Label: l, TSet: tSet}
+}
+
+// Hours formats a duration as hours, to at most two decimal places, e.g. "2.25h".
+func Hours(d time.Duration) string {
+	return strconv.FormatFloat(math.Round(d.Hours()*100)/100, 'f', -1, 64) + "h"
+}
+
+const (
+	// LabelTransitions labels the section holding a user's status transitions.
+	LabelTransitions = "Status Transitions"
+	// LabelTimeLogged labels the section holding a user's worklogs.
+	LabelTimeLogged = "Time Logged"
+)
+
+// TocEntry is a line in a report's table of contents.
+type TocEntry struct {
+	Label  string
+	Anchor string
+	Count  int
+}
+
+// TableOfContent returns an entry for each of the user's non-empty sections.
+func TableOfContent(u *types.MyUser) []TocEntry {
+	var result []TocEntry
+	for _, c := range UserCategories("", u) {
+		result = append(result, TocEntry{
+			Label:  c.Label,
+			Anchor: Anchor(u.Login, c.Label),
+			Count:  c.Count(),
+		})
+	}
+	if u.Transitions != nil && !u.Transitions.IsEmpty() {
+		result = append(result, TocEntry{
+			Label:  LabelTransitions,
+			Anchor: Anchor(u.Login, LabelTransitions),
+			Count:  u.Transitions.Count(),
+		})
+	}
+	if u.TimeLogged != nil && !u.TimeLogged.IsEmpty() {
+		result = append(result, TocEntry{
+			Label:  LabelTimeLogged,
+			Anchor: Anchor(u.Login, LabelTimeLogged),
+			Count:  u.TimeLogged.Count(),
+		})
+	}
+	return result
+}
+
+// JiraFlag returns a note about the user's Jira account if it's
+// not in good standing, else the empty string.
+func JiraFlag(u *types.MyUser) string {
+	switch u.JiraAccount {
+	case types.JiraAccountInactive:
+		return "inactive in Jira"
+	case types.JiraAccountUnknown:
+		return "unknown to Jira"
+	case types.JiraAccountAmbiguous:
+		return "ambiguous in Jira"
+	}
+	return ""
+}
+
+// botSuffixes identify the logins of automated accounts.
+var botSuffixes = []string{"[bot]", "-bot", "-robot", "-ci"}
+
+// automatedPrefixes identify the messages of commits typically made by tools.
+var automatedPrefixes = []string{"Merge branch ", "Merge pull request ", "Merge remote-tracking branch "}
+
+// IsAutomated guesses whether a bot, rather than a person, made the commit.
+func IsAutomated(c *types.MyCommit) bool {
+	author := strings.ToLower(c.Author)
+	for _, s := range botSuffixes {
+		if strings.HasSuffix(author, s) {
+			return true
+		}
+	}
+	for _, p := range automatedPrefixes {
+		if strings.HasPrefix(c.MessageFirstLine, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// SortedRepoIds returns the keys of the given map, sorted by their string form,
+// so that reports built outside of Go templates have a stable order.
+func SortedRepoIds[T any](m map[types.RepoId]T) []types.RepoId {
+	result := make([]types.RepoId, 0, len(m))
+	for id := range m {
+		result = append(result, id)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].String() < result[j].String()
+	})
+	return result
+}
+
+// UserHRef returns a link (sans scheme) to the given user's profile at the given domain.
+func UserHRef(dGh string, login string) string {
+	return dGh + "/" + login
 }
@@ -0,0 +1,135 @@
+package common
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/monopole/snips/internal/types"
+)
+
+// Item is one issue or commit, flattened for writers that don't use Go templates.
+type Item struct {
+	// Id is an issue number, a Jira issue key, or a short commit sha.
+	Id    string
+	Title string
+	Url   string
+	When  time.Time
+	// State is the issue state, if known.
+	State string
+}
+
+// ItemGroup holds the items of one category that belong to one repo.
+type ItemGroup struct {
+	RepoId types.RepoId
+	// Domain is the GitHub or Jira domain the items came from.
+	Domain string
+	// RepoHRef is a link (sans scheme) to the repo or jira project.
+	RepoHRef string
+	// RepoLabel names the group, e.g. "org/repo", or a jira epic or sprint.
+	RepoLabel string
+	Items     []Item
+}
+
+// ItemCategory is a labeled report section, e.g. "Issues Created".
+type ItemCategory struct {
+	Label  string
+	Groups []ItemGroup
+}
+
+// Count returns the number of items in the category.
+func (c *ItemCategory) Count() int {
+	n := 0
+	for _, g := range c.Groups {
+		n += len(g.Items)
+	}
+	return n
+}
+
+// UserCategories returns the user's non-empty report sections in report order,
+// custom sections last, with repos sorted by name.
+func UserCategories(dGh string, u *types.MyUser) []ItemCategory {
+	var result []ItemCategory
+	for _, c := range []ItemCategory{
+		issueSetCategory("Issues Created", u.IssuesCreated),
+		issueSetCategory("Issues Commented", u.IssuesCommented),
+		issueSetCategory("Issues Closed", u.IssuesClosed),
+		issueSetCategory("PRs Reviewed", u.PrsReviewed),
+		commitMapCategory("Commits", dGh, u.Commits),
+	} {
+		if len(c.Groups) > 0 {
+			result = append(result, c)
+		}
+	}
+	for _, custom := range u.Custom {
+		if c := issueSetCategory(custom.Label, custom.Issues); len(c.Groups) > 0 {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func issueSetCategory(label string, iSet *types.IssueSet) ItemCategory {
+	c := ItemCategory{Label: label}
+	if iSet == nil {
+		return c
+	}
+	for _, id := range SortedRepoIds(iSet.Groups) {
+		dr := DomainAndRepo{Dgh: iSet.Domain, Rid: id, GroupedBy: iSet.GroupedBy}
+		g := ItemGroup{
+			RepoId:    id,
+			Domain:    iSet.Domain,
+			RepoHRef:  dr.HRef(),
+			RepoLabel: dr.Label(),
+		}
+		for _, issue := range iSet.Groups[id] {
+			itemId := issue.Key
+			if itemId == "" {
+				itemId = strconv.Itoa(issue.Number)
+			}
+			g.Items = append(g.Items, Item{
+				Id:    itemId,
+				Title: issue.Title,
+				Url:   issue.HtmlUrl,
+				When:  issue.Updated,
+				State: issue.State,
+			})
+		}
+		c.Groups = append(c.Groups, g)
+	}
+	return c
+}
+
+func commitMapCategory(label string, dGh string, m map[types.RepoId][]*types.MyCommit) ItemCategory {
+	c := ItemCategory{Label: label}
+	for _, id := range SortedRepoIds(m) {
+		dr := DomainAndRepo{Dgh: dGh, Rid: id}
+		g := ItemGroup{
+			RepoId:    id,
+			Domain:    dGh,
+			RepoHRef:  dr.HRef(),
+			RepoLabel: dr.Label(),
+		}
+		for _, commit := range m[id] {
+			item := Item{
+				Id:    shortSha(commit.Sha),
+				Title: commit.MessageFirstLine,
+				Url:   commit.Url,
+				When:  commit.Committed,
+			}
+			if commit.Pr != nil {
+				// Found via a merged PR.
+				item.State = "merged"
+			}
+			g.Items = append(g.Items, item)
+		}
+		c.Groups = append(c.Groups, g)
+	}
+	return c
+}
+
+func shortSha(s string) string {
+	if len(s) > 7 {
+		return s[0:7]
+	}
+	return s
+}
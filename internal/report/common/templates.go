@@ -0,0 +1,103 @@
+package common
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TemplateFileExt is the extension of template override files.
+const TemplateFileExt = ".tmpl"
+
+// NamedTemplate is the text of a template, typically a single
+// {{define "name"}} ... {{end}} block, paired with a name.
+type NamedTemplate struct {
+	Name string
+	Body string
+}
+
+// ReadTemplateDir returns the contents of the template files in dir,
+// sorted by file name.  Each is named for its file, less the extension.
+func ReadTemplateDir(dir string) ([]NamedTemplate, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+TemplateFileExt))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no %s files found in %q", TemplateFileExt, dir)
+	}
+	sort.Strings(paths)
+	result := make([]NamedTemplate, len(paths))
+	for i, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read template %q; %w", p, err)
+		}
+		result[i] = NamedTemplate{
+			Name: strings.TrimSuffix(filepath.Base(p), TemplateFileExt),
+			Body: string(data),
+		}
+	}
+	return result, nil
+}
+
+// WriteTemplateDir writes each template to its own file in dir,
+// for use as a starting point for overrides.
+func WriteTemplateDir(dir string, tmpls []NamedTemplate) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, t := range tmpls {
+		p := filepath.Join(dir, t.Name+TemplateFileExt)
+		body := strings.TrimPrefix(t.Body, "\n")
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			return fmt.Errorf("unable to write template %q; %w", p, err)
+		}
+	}
+	return nil
+}
+
+// Template is satisfied by both *html/template.Template and *text/template.Template.
+type Template[T any] interface {
+	Name() string
+	New(name string) T
+	Parse(text string) (T, error)
+	Templates() []T
+}
+
+// ApplyOverrides parses the template files found in dir into t, so that their
+// {{define}} blocks replace the built-in templates of the same name.
+// A define matching no built-in name is logged as a warning, as it's probably a typo.
+func ApplyOverrides[T Template[T]](t T, builtIns []NamedTemplate, dir string) error {
+	overrides, err := ReadTemplateDir(dir)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool)
+	for _, nt := range builtIns {
+		known[nt.Name] = true
+	}
+	for _, o := range overrides {
+		before := nameSet(t.Templates())
+		if _, err = t.New(o.Name).Parse(o.Body); err != nil {
+			return fmt.Errorf("trouble with template file %q; %w", o.Name, err)
+		}
+		for n := range nameSet(t.Templates()) {
+			if !before[n] && !known[n] && n != o.Name {
+				slog.Warn("template overrides no built-in template", "template", n, "file", o.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func nameSet[T Template[T]](ts []T) map[string]bool {
+	result := make(map[string]bool)
+	for _, x := range ts {
+		result[x.Name()] = true
+	}
+	return result
+}
@@ -0,0 +1,121 @@
+package csv
+
+import (
+	stdcsv "encoding/csv"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/monopole/snips/internal/report/common"
+	"github.com/monopole/snips/internal/types"
+)
+
+const (
+	SourceGitHub    = "github"
+	SourceJira      = "jira"
+	SourceGerrit    = "gerrit"
+	SourceBitbucket = "bitbucket"
+	// SourceSnips marks rows about the report itself, e.g. its warnings.
+	SourceSnips = "snips"
+
+	// CategoryWarning is the category of rows holding the report's
+	// warnings, e.g. that collection was interrupted, leaving it partial.
+	CategoryWarning = "Warning"
+)
+
+// Header names the columns of the export, one row per activity item.
+var Header = []string{
+	"person", "source", "category", "repo", "id", "title", "url", "timestamp", "state",
+}
+
+// WriteCsvReport writes every item in the report as comma separated values.
+func WriteCsvReport(w io.Writer, r *types.Report) error {
+	return writeReport(w, ',', r)
+}
+
+// WriteTsvReport writes every item in the report as tab separated values.
+func WriteTsvReport(w io.Writer, r *types.Report) error {
+	return writeReport(w, '\t', r)
+}
+
+func writeReport(w io.Writer, delim rune, r *types.Report) error {
+	cw := stdcsv.NewWriter(w)
+	cw.Comma = delim
+	if err := cw.Write(Header); err != nil {
+		return err
+	}
+	for _, row := range makeRows(r) {
+		for i := range row {
+			row[i] = defuse(row[i])
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func makeRows(r *types.Report) (rows [][]string) {
+	for _, u := range r.Users {
+		for _, c := range common.UserCategories(r.DomainGh, u) {
+			for _, g := range c.Groups {
+				source := sourceOf(r, &g)
+				for _, item := range g.Items {
+					rows = append(rows, []string{
+						u.Login,
+						source,
+						c.Label,
+						g.RepoLabel,
+						item.Id,
+						item.Title,
+						item.Url,
+						item.When.Format(time.RFC3339),
+						item.State,
+					})
+				}
+			}
+		}
+	}
+	// Warnings go last, so that a report cut short says so even when
+	// it's redirected to a file.
+	for _, w := range r.Warnings {
+		rows = append(rows, []string{"", SourceSnips, CategoryWarning, "", "", w, "", "", ""})
+	}
+	return
+}
+
+// sourceOf names the service the group's items came from.
+func sourceOf(r *types.Report, g *common.ItemGroup) string {
+	switch {
+	case g.RepoId.Host == types.HostGerrit:
+		return SourceGerrit
+	case g.RepoId.Host == types.HostBitbucket:
+		return SourceBitbucket
+	case r.DomainJira != "" && g.Domain == r.DomainJira:
+		return SourceJira
+	default:
+		return SourceGitHub
+	}
+}
+
+// plainNumber matches a signed number, which is safe to leave as is.
+var plainNumber = regexp.MustCompile(`^[+-][0-9]+(\.[0-9]+)?$`)
+
+// defuse quotes a cell that a spreadsheet would otherwise take as a
+// formula, e.g. an issue titled "=HYPERLINK(...)".
+// https://owasp.org/www-community/attacks/CSV_Injection
+func defuse(cell string) string {
+	if cell == "" {
+		return cell
+	}
+	switch cell[0] {
+	case '=', '@', '\t', '\r':
+		return "'" + cell
+	case '+', '-':
+		if !plainNumber.MatchString(cell) {
+			return "'" + cell
+		}
+	}
+	return cell
+}
@@ -0,0 +1,114 @@
+package csv_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/monopole/snips/internal/report/csv"
+	"github.com/monopole/snips/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	when = time.Date(2023, 6, 8, 13, 47, 0, 0, time.UTC)
+
+	report = &types.Report{
+		DomainGh:   "github.com",
+		DomainJira: "issues.acmecorp.com",
+		Warnings:   []string{"collection was interrupted; only what was gathered by then is reported"},
+		Users: []*types.MyUser{{
+			Login: "bob",
+			IssuesCreated: &types.IssueSet{
+				Domain: "github.com",
+				Groups: map[types.RepoId][]types.MyIssue{
+					{Org: "kubernetes", Name: "kubectl"}: {{
+						Number:  12,
+						Title:   "Fix it, \"now\"",
+						HtmlUrl: "https://github.com/kubernetes/kubectl/issues/12",
+						Updated: when,
+						State:   "open",
+					}, {
+						Number:  13,
+						Title:   "=HYPERLINK(\"https://evil.example.com\")",
+						HtmlUrl: "https://github.com/kubernetes/kubectl/issues/13",
+						Updated: when,
+						State:   "open",
+					}},
+				},
+			},
+			IssuesClosed: &types.IssueSet{
+				Domain: "issues.acmecorp.com",
+				Groups: map[types.RepoId][]types.MyIssue{
+					{Org: "microsoft developers", Name: "MSFT"}: {{
+						Number:  1,
+						Key:     "MSFT-1",
+						Title:   "Clean\tup",
+						HtmlUrl: "https://issues.acmecorp.com/browse/MSFT-1",
+						Updated: when,
+						State:   "Done",
+					}},
+				},
+			},
+			Commits: map[types.RepoId][]*types.MyCommit{
+				{Org: "kubernetes", Name: "kubectl"}: {{
+					Sha:              "fc25519428f4f91813d5a8c324c73ada2d94b578",
+					Url:              "https://github.com/kubernetes/kubectl/commit/fc25519",
+					MessageFirstLine: "Fry bananas",
+					Committed:        when,
+					Pr:               &types.MyIssue{Number: 3},
+				}},
+				{Org: "review.acme.com", Name: "platform/build", Host: types.HostGerrit}: {{
+					Sha:              "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678",
+					Url:              "https://review.acme.com/c/platform/build/+/1234",
+					MessageFirstLine: "Cache the toolchain",
+					Committed:        when,
+				}},
+				{Org: "bitbucket.acme.com", Name: "PLAT/deploy", Host: types.HostBitbucket}: {{
+					Sha:              "0f1e2d3c4b5a69788796a5b4c3d2e1f00f1e2d3c",
+					Url:              "https://bitbucket.acme.com/projects/PLAT/repos/deploy/commits/0f1e2d3c",
+					MessageFirstLine: "Roll back",
+					Committed:        when,
+				}},
+			},
+		}},
+	}
+)
+
+func Test_WriteReport(t *testing.T) {
+	tests := map[string]struct {
+		writeF func(*bytes.Buffer) error
+		want   string
+	}{
+		"csv": {
+			writeF: func(b *bytes.Buffer) error { return WriteCsvReport(b, report) },
+			want: `person,source,category,repo,id,title,url,timestamp,state
+bob,github,Issues Created,kubernetes/kubectl,12,"Fix it, ""now""",https://github.com/kubernetes/kubectl/issues/12,2023-06-08T13:47:00Z,open
+bob,github,Issues Created,kubernetes/kubectl,13,"'=HYPERLINK(""https://evil.example.com"")",https://github.com/kubernetes/kubectl/issues/13,2023-06-08T13:47:00Z,open
+bob,jira,Issues Closed,microsoft developers/MSFT,MSFT-1,Clean	up,https://issues.acmecorp.com/browse/MSFT-1,2023-06-08T13:47:00Z,Done
+bob,bitbucket,Commits,bitbucket.acme.com/PLAT/deploy,0f1e2d3,Roll back,https://bitbucket.acme.com/projects/PLAT/repos/deploy/commits/0f1e2d3c,2023-06-08T13:47:00Z,
+bob,github,Commits,kubernetes/kubectl,fc25519,Fry bananas,https://github.com/kubernetes/kubectl/commit/fc25519,2023-06-08T13:47:00Z,merged
+bob,gerrit,Commits,review.acme.com/platform/build,a1b2c3d,Cache the toolchain,https://review.acme.com/c/platform/build/+/1234,2023-06-08T13:47:00Z,
+,snips,Warning,,,collection was interrupted; only what was gathered by then is reported,,,
+`,
+		},
+		"tsv": {
+			writeF: func(b *bytes.Buffer) error { return WriteTsvReport(b, report) },
+			want: "person\tsource\tcategory\trepo\tid\ttitle\turl\ttimestamp\tstate\n" +
+				"bob\tgithub\tIssues Created\tkubernetes/kubectl\t12\t\"Fix it, \"\"now\"\"\"\thttps://github.com/kubernetes/kubectl/issues/12\t2023-06-08T13:47:00Z\topen\n" +
+				"bob\tgithub\tIssues Created\tkubernetes/kubectl\t13\t\"'=HYPERLINK(\"\"https://evil.example.com\"\")\"\thttps://github.com/kubernetes/kubectl/issues/13\t2023-06-08T13:47:00Z\topen\n" +
+				"bob\tjira\tIssues Closed\tmicrosoft developers/MSFT\tMSFT-1\t\"Clean\tup\"\thttps://issues.acmecorp.com/browse/MSFT-1\t2023-06-08T13:47:00Z\tDone\n" +
+				"bob\tbitbucket\tCommits\tbitbucket.acme.com/PLAT/deploy\t0f1e2d3\tRoll back\thttps://bitbucket.acme.com/projects/PLAT/repos/deploy/commits/0f1e2d3c\t2023-06-08T13:47:00Z\t\n" +
+				"bob\tgithub\tCommits\tkubernetes/kubectl\tfc25519\tFry bananas\thttps://github.com/kubernetes/kubectl/commit/fc25519\t2023-06-08T13:47:00Z\tmerged\n" +
+				"bob\tgerrit\tCommits\treview.acme.com/platform/build\ta1b2c3d\tCache the toolchain\thttps://review.acme.com/c/platform/build/+/1234\t2023-06-08T13:47:00Z\t\n" +
+				"\tsnips\tWarning\t\t\tcollection was interrupted; only what was gathered by then is reported\t\t\t\n",
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			var b bytes.Buffer
+			assert.NoError(t, tt.writeF(&b))
+			assert.Equal(t, tt.want, b.String())
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package csv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_defuse(t *testing.T) {
+	tests := map[string]struct {
+		cell string
+		want string
+	}{
+		"empty":           {},
+		"plain":           {cell: "Fix it", want: "Fix it"},
+		"formula":         {cell: "=1+2", want: "'=1+2"},
+		"at":              {cell: "@SUM(A1:A9)", want: "'@SUM(A1:A9)"},
+		"tab":             {cell: "\t=cmd", want: "'\t=cmd"},
+		"carriage return": {cell: "\r=cmd", want: "'\r=cmd"},
+		"negative number": {cell: "-1", want: "-1"},
+		"signed decimal":  {cell: "+2.5", want: "+2.5"},
+		"minus formula":   {cell: "-1+cmd|' /C calc'!A0", want: "'-1+cmd|' /C calc'!A0"},
+		"plus formula":    {cell: "+A1", want: "'+A1"},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			assert.Equal(t, tt.want, defuse(tt.cell))
+		})
+	}
+}
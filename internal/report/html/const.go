@@ -4,7 +4,7 @@ const (
 	tmplNameRepoLink = "tmplRepoLink"
 	tmplBodyRepoLink = `
 {{define "` + tmplNameRepoLink + `" -}}
-<a href="https://{{.HRef}}"> {{.Rid}} </a>
+<a href="https://{{.HRef}}"> {{.Label}} </a>
 {{- end}}
 `
 	tmplNameItemCount = "tmplItemCount"
@@ -19,6 +19,17 @@ const (
 	tmplBodyIssue = `
 {{define "` + tmplNameIssue + `" -}}
 <code>{{snipDate .Updated}}</code> &nbsp; <a href="{{.HtmlUrl}}"> {{.Title}} </a>
+{{- if or .Epic .Sprints}} <span class="context">
+{{- if .Epic}}{{.Epic}}{{end}}{{if and .Epic .Sprints}} &middot; {{end}}{{join .Sprints ", "}}</span>
+{{- end}}
+{{- if .Votes}} <span class="context">{{join .Votes ", "}}</span>{{end}}
+{{- if .Comments}} <span class="itemCount">({{len .Comments}} comments)</span>
+<ul class="comments">
+{{- range .Comments}}
+<li><code>{{snipTime .When}}</code> &nbsp; <a href="{{.Url}}">{{.FirstLine}}</a></li>
+{{- end}}
+</ul>
+{{- end}}
 {{- end}}
 `
 	tmplNameCommit = "tmplCommit"
@@ -36,12 +47,14 @@ const (
 {{define "` + tmplNameIssueSet + `" -}}
 <div class="issueMap">
 {{range $repo, $list := .Groups -}}
-<h4> {{template "` + tmplNameRepoLink + `" domainAndRepo $.Domain $repo}} 
+<details class="repo" open>
+<summary><h4> {{template "` + tmplNameRepoLink + `" issueGroup $.Domain $.GroupedBy $repo}} 
 <span class="itemCount">({{len $list}} issues)</span>
-</h4>
+</h4></summary>
 {{range $i, $issue := $list }}
 <div class="oneIssue"> {{template "` + tmplNameIssue + `" $issue}} </div>
 {{- end}}
+</details>
 {{- end}}
 </div>
 {{- end}}
@@ -51,12 +64,14 @@ const (
 {{define "` + tmplNameRepoToCommitMap + `" -}}
 <div class="issueMap">
 {{range $repo, $list := .M -}}
-<h4> {{template "` + tmplNameRepoLink + `" domainAndRepo $.Dgh $repo}} 
+<details class="repo" open>
+<summary><h4> {{template "` + tmplNameRepoLink + `" domainAndRepo $.Dgh $repo}} 
 <span class="itemCount">({{len $list}} commits)</span>
-</h4>
+</h4></summary>
 {{range $i, $issue := $list }}
-<div class="oneIssue"> {{template "` + tmplNameCommit + `" $issue}} </div>
+<div class="oneIssue{{if isBot $issue}} bot{{end}}"> {{template "` + tmplNameCommit + `" $issue}} </div>
 {{- end}}
+</details>
 {{- end}}
 </div>
 {{- end}}
@@ -68,7 +83,7 @@ const (
 {{if (or (eq .ISet nil) .ISet.IsEmpty) -}}
 <h3> No {{.Label}} </h3>
 {{- else -}}
-<h3 id="{{lowerHyphen .Label}}"> {{.Label}}
+<h3 id="{{.Anchor}}"> {{.Label}}
 <span class="itemCount">({{- .ISet.Count}} issues in {{.ISet.RepoCount}} repos)</span>
 </h3>
 {{template "` + tmplNameIssueSet + `" .ISet}}
@@ -79,7 +94,7 @@ const (
 	tmplBodyLabeledCommitMap = `
 {{define "` + tmplNameLabeledCommitMap + `" -}}
 {{if .M -}}
-<h3 id="{{lowerHyphen .Label}}"> {{.Label}} 
+<h3 id="{{.Anchor}}"> {{.Label}} 
 <span class="itemCount">({{mapTotalCommits .M}} commits to {{len .M}} repos)</span>
 </h3>
 {{template "` + tmplNameRepoToCommitMap + `" (domainAndCommitMap .Dgh .M)}}
@@ -87,6 +102,55 @@ const (
 <h3> No {{.Label}} </h3>
 {{- end}}
 {{- end}}
+`
+	tmplNameLabeledWorklogSet = "tmplLabeledWorklogSet"
+	tmplBodyLabeledWorklogSet = `
+{{define "` + tmplNameLabeledWorklogSet + `" -}}
+{{if .WSet.IsEmpty -}}
+<h3> No {{.Label}} </h3>
+{{- else -}}
+<h3 id="{{.Anchor}}"> {{.Label}}
+<span class="itemCount">({{hours .WSet.Total}} on {{.WSet.Count}} issues in {{.WSet.RepoCount}} projects)</span>
+</h3>
+<div class="issueMap">
+{{range $repo, $list := .WSet.Groups -}}
+<details class="repo" open>
+<summary><h4> {{template "` + tmplNameRepoLink + `" issueGroup $.WSet.Domain $.WSet.GroupedBy $repo}} 
+<span class="itemCount">({{hours ($.WSet.RepoTotal $repo)}})</span>
+</h4></summary>
+{{range $i, $wl := $list }}
+<div class="oneIssue"> <code>{{hours $wl.Spent}}</code> &nbsp; {{template "` + tmplNameIssue + `" $wl.Issue}} </div>
+{{- end}}
+</details>
+{{- end}}
+</div>
+{{- end}}
+{{- end}}
+`
+	tmplNameLabeledTransitionSet = "tmplLabeledTransitionSet"
+	tmplBodyLabeledTransitionSet = `
+{{define "` + tmplNameLabeledTransitionSet + `" -}}
+{{if .TSet.IsEmpty -}}
+<h3> No {{.Label}} </h3>
+{{- else -}}
+<h3 id="{{.Anchor}}"> {{.Label}}
+<span class="itemCount">({{.TSet.Count}} transitions in {{.TSet.RepoCount}} projects)</span>
+</h3>
+<div class="issueMap">
+{{range $repo, $list := .TSet.Groups -}}
+<details class="repo" open>
+<summary><h4> {{template "` + tmplNameRepoLink + `" issueGroup $.TSet.Domain $.TSet.GroupedBy $repo}} 
+<span class="itemCount">({{len $list}} transitions)</span>
+</h4></summary>
+{{range $i, $tr := $list }}
+<div class="oneIssue"> <code>{{snipDate $tr.When}}</code> &nbsp; <a href="{{$tr.Issue.HtmlUrl}}"> {{$tr.Issue.Title}} </a>
+&nbsp; <em>{{$tr.From}} &rarr; {{$tr.To}}</em> </div>
+{{- end}}
+</details>
+{{- end}}
+</div>
+{{- end}}
+{{- end}}
 `
 	tmplNameOrganizations = "tmplOrganizations"
 	tmplBodyOrganizations = `
@@ -109,11 +173,11 @@ const (
   <th> items </th>
   <th> repos </th>
 </tr>
-{{template "` + tmplNameSummaryIssueSet + `" (labeledIssueSet "issues created" .U.IssuesCreated)}}
-{{template "` + tmplNameSummaryIssueSet + `" (labeledIssueSet "issues commented" .U.IssuesCommented)}}
-{{template "` + tmplNameSummaryIssueSet + `" (labeledIssueSet "issues closed" .U.IssuesClosed)}}
-{{template "` + tmplNameSummaryIssueSet + `" (labeledIssueSet "PRs reviewed" .U.PrsReviewed)}}
-{{template "` + tmplNameSummaryCommits + `" (labeledCommitMap "commits" .Dgh .U.Commits)}}
+{{template "` + tmplNameSummaryIssueSet + `" (userIssueSet .U.Login "issues created" .U.IssuesCreated)}}
+{{template "` + tmplNameSummaryIssueSet + `" (userIssueSet .U.Login "issues commented" .U.IssuesCommented)}}
+{{template "` + tmplNameSummaryIssueSet + `" (userIssueSet .U.Login "issues closed" .U.IssuesClosed)}}
+{{template "` + tmplNameSummaryIssueSet + `" (userIssueSet .U.Login "PRs reviewed" .U.PrsReviewed)}}
+{{template "` + tmplNameSummaryCommits + `" (userCommitMap .U.Login "commits" .Dgh .U.Commits)}}
 </table>
 
 {{- end}}
@@ -125,7 +189,7 @@ const (
 <tr> No {{.Label}} </tr>
 {{- else -}}
 <tr>
-  <td> <a href="#{{lowerHyphen .Label}}">{{.Label}}</a></td>
+  <td> <a href="#{{.Anchor}}">{{.Label}}</a></td>
   <td> {{.ISet.Count}} </td>
   <td> {{.ISet.RepoCount}} </td>
 </tr>
@@ -139,7 +203,7 @@ const (
 <tr> No {{.Label}} </tr>
 {{- else -}}
 <tr>
-  <td> <a href="#{{lowerHyphen .Label}}">{{.Label}}</a></td>
+  <td> <a href="#{{.Anchor}}">{{.Label}}</a></td>
   <td> {{mapTotalCommits .M}} </td>
   <td> {{len .M}} </td>
 </tr>
@@ -149,7 +213,9 @@ const (
 	tmplNameUser = "tmplUser"
 	tmplBodyUser = `
 {{define "` + tmplNameUser + `" -}}
-<h2> {{.U.Name}} (<em>{{if .U.Email}}{{.U.Email}}{{else}}{{.U.Login}}{{end}}</em>)</h2>
+<h2 id="{{anchor "user" .U.Login}}">
+{{- if .U.AvatarUrl}} <img class="avatar" src="{{.U.AvatarUrl}}" alt="">{{end}} {{.U.Name}} (<em>{{if .U.Email}}{{.U.Email}}{{else}}{{.U.Login}}{{end}}</em>)
+{{- with jiraFlag .U}} <span class="flag">{{.}}</span>{{end}}</h2>
 <div class="userData">
 {{template "` + tmplNameUserHighlights + `" domainsAndUser .Dgh "jira" .U}}
 {{if .U.GhOrgs}}
@@ -157,14 +223,65 @@ const (
 {{else}}
   <h3> no organizations </h3>
 {{end}}
-{{template "` + tmplNameLabeledIssueSet + `" (labeledIssueSet "Issues Created" .U.IssuesCreated)}}
-{{template "` + tmplNameLabeledIssueSet + `" (labeledIssueSet "Issues Commented" .U.IssuesCommented)}}
-{{template "` + tmplNameLabeledIssueSet + `" (labeledIssueSet "Issues Closed" .U.IssuesClosed)}}
-{{template "` + tmplNameLabeledIssueSet + `" (labeledIssueSet "PRs Reviewed" .U.PrsReviewed)}}
-{{template "` + tmplNameLabeledCommitMap + `" (labeledCommitMap "Commits" .Dgh .U.Commits)}}
+{{template "` + tmplNameLabeledIssueSet + `" (userIssueSet .U.Login "Issues Created" .U.IssuesCreated)}}
+{{template "` + tmplNameLabeledIssueSet + `" (userIssueSet .U.Login "Issues Commented" .U.IssuesCommented)}}
+{{template "` + tmplNameLabeledIssueSet + `" (userIssueSet .U.Login "Issues Closed" .U.IssuesClosed)}}
+{{template "` + tmplNameLabeledIssueSet + `" (userIssueSet .U.Login "PRs Reviewed" .U.PrsReviewed)}}
+{{template "` + tmplNameLabeledCommitMap + `" (userCommitMap .U.Login "Commits" .Dgh .U.Commits)}}
+{{range .U.Custom -}}
+{{template "` + tmplNameLabeledIssueSet + `" (userIssueSet $.U.Login .Label .Issues)}}
+{{end -}}
+{{if .U.Transitions -}}
+{{template "` + tmplNameLabeledTransitionSet + `" (userTransitionSet .U.Login "Status Transitions" .U.Transitions)}}
+{{- end}}
+{{if .U.TimeLogged -}}
+{{template "` + tmplNameLabeledWorklogSet + `" (userWorklogSet .U.Login "Time Logged" .U.TimeLogged)}}
+{{- end}}
 </div>
 <hr>
 {{end}}
+`
+	tmplNameToc = "tmplToc"
+	tmplBodyToc = `
+{{define "` + tmplNameToc + `" -}}
+<nav class="toc">
+<ul>
+{{range . -}}
+<li><a href="#{{anchor "user" .Login}}">{{if .Name}}{{.Name}}{{else}}{{.Login}}{{end}}</a>
+<ul>
+{{range tableOfContent . -}}
+<li><a href="#{{.Anchor}}">{{.Label}}</a> <span class="itemCount">({{.Count}})</span></li>
+{{end -}}
+</ul>
+</li>
+{{end -}}
+</ul>
+</nav>
+{{- end}}
+`
+	tmplNameToolbar = "tmplToolbar"
+	tmplBodyToolbar = `
+{{define "` + tmplNameToolbar + `" -}}
+<div class="toolbar">
+  <input id="snipsFilter" type="search" placeholder="filter items..." autofocus>
+  <label><input id="snipsHideBots" type="checkbox"> hide bots &amp; automated commits</label>
+  <button id="snipsExpand" type="button">expand all</button>
+  <button id="snipsCollapse" type="button">collapse all</button>
+</div>
+{{- end}}
+`
+	tmplNameWarnings = "tmplWarnings"
+	tmplBodyWarnings = `
+{{define "` + tmplNameWarnings + `" -}}
+<div class="warnings">
+  <strong>This report is incomplete:</strong>
+  <ul>
+  {{- range .}}
+    <li>{{.}}</li>
+  {{- end}}
+  </ul>
+</div>
+{{- end}}
 `
 	tmplNameSnipsMain = "tmplSnipsMain"
 	tmplBodySnipsMain = `
@@ -179,11 +296,19 @@ const (
   <body>
     <h1>{{.Title}}</h1>
     <p><em> {{ prettyDateRange .Dr }} </em></p>
+    {{if .Warnings -}}
+    {{template "` + tmplNameWarnings + `" .Warnings}}
+    {{- end}}
+    {{if .Users -}}
+    {{template "` + tmplNameToolbar + `" .}}
+    {{template "` + tmplNameToc + `" .Users}}
+    {{- end}}
     {{range .Users -}}
       <div>{{ template "` + tmplNameUser + `" (domainsAndUser $.DomainGh $.DomainJira .) -}}</div>
     {{- else -}}
       <p><strong> no users </strong></p>
-    {{- end}}
+    {{- end}}` +
+		jsScript + `
   </body>
 </html>
 {{- end}}
@@ -211,9 +336,78 @@ const (
   color: gray;
   font-style: italic;
 }
+details.repo > summary { cursor: pointer; }
+details.repo > summary > h4 { display: inline; }
+.toolbar {
+  position: sticky;
+  top: 0;
+  padding: 4px 0;
+  background-color: white;
+  border-bottom: 1px solid #ddd;
+}
+.toc ul { margin-top: 0; }
+.context { color: gray; font-size: smaller; }
+.avatar { width: 1.5em; height: 1.5em; border-radius: 50%; vertical-align: middle; }
+.flag {
+  font-size: smaller;
+  padding: 0 4px;
+  border: 1px solid #e0b000;
+  background-color: #fff8e0;
+}
+.comments { margin: 0; font-size: smaller; }
+.warnings {
+  padding: 2px 10px;
+  border: 1px solid #e0b000;
+  background-color: #fff8e0;
+}
+.hideBots .bot, .filteredOut { display: none; }
 table td { width: 9em; border: 1px solid black; }
 table td { text-align: end; padding-right: 1em; }
 table th { text-align: end; padding-right: 1em; }
 </style>
+`
+
+	// jsScript implements the toolbar.  It's inline so that
+	// the report works offline and as a data: URL.
+	jsScript = `
+<script>
+(function () {
+  var filter = document.getElementById("snipsFilter");
+  var hideBots = document.getElementById("snipsHideBots");
+  if (!filter) {
+    return;
+  }
+  function each(sel, root, f) {
+    Array.prototype.forEach.call((root || document).querySelectorAll(sel), f);
+  }
+  function apply() {
+    var q = filter.value.trim().toLowerCase();
+    document.body.classList.toggle("hideBots", hideBots.checked);
+    each("details.repo", null, function (d) {
+      var repoHit = q !== "" && d.querySelector("summary").textContent.toLowerCase().indexOf(q) >= 0;
+      var shown = 0;
+      each(".oneIssue", d, function (e) {
+        var hit = q === "" || repoHit || e.textContent.toLowerCase().indexOf(q) >= 0;
+        e.classList.toggle("filteredOut", !hit);
+        if (hit && !(hideBots.checked && e.classList.contains("bot"))) {
+          shown++;
+        }
+      });
+      d.classList.toggle("filteredOut", shown === 0);
+      if (q !== "" && shown > 0) {
+        d.open = true;
+      }
+    });
+  }
+  filter.addEventListener("input", apply);
+  hideBots.addEventListener("change", apply);
+  document.getElementById("snipsExpand").addEventListener("click", function () {
+    each("details.repo", null, function (d) { d.open = true; });
+  });
+  document.getElementById("snipsCollapse").addEventListener("click", function () {
+    each("details.repo", null, function (d) { d.open = false; });
+  });
+})();
+</script>
 `
 )
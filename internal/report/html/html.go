@@ -1,29 +1,56 @@
 package html
 
 import (
-	"github.com/monopole/snips/internal/report/common"
-	"github.com/monopole/snips/internal/types"
 	"html/template"
 	"io"
+
+	"github.com/monopole/snips/internal/report/common"
+	"github.com/monopole/snips/internal/types"
 )
 
+// HtmlTemplates returns the built-in templates, in parse order.
+func HtmlTemplates() []common.NamedTemplate {
+	return []common.NamedTemplate{
+		{Name: tmplNameRepoLink, Body: tmplBodyRepoLink},
+		{Name: tmplNameItemCount, Body: tmplBodyItemCount},
+		{Name: tmplNameIssue, Body: tmplBodyIssue},
+		{Name: tmplNameCommit, Body: tmplBodyCommit},
+		{Name: tmplNameOrganizations, Body: tmplBodyOrganizations},
+		{Name: tmplNameIssueSet, Body: tmplBodyIssueSet},
+		{Name: tmplNameRepoToCommitMap, Body: tmplBodyRepoToCommitMap},
+		{Name: tmplNameLabeledIssueSet, Body: tmplBodyLabeledIssueSet},
+		{Name: tmplNameLabeledCommitMap, Body: tmplBodyLabeledCommitMap},
+		{Name: tmplNameLabeledTransitionSet, Body: tmplBodyLabeledTransitionSet},
+		{Name: tmplNameLabeledWorklogSet, Body: tmplBodyLabeledWorklogSet},
+		{Name: tmplNameUser, Body: tmplBodyUser},
+		{Name: tmplNameUserHighlights, Body: tmplBodyUserHighlights},
+		{Name: tmplNameSummaryIssueSet, Body: tmplBodySummaryIssueSet},
+		{Name: tmplNameSummaryCommits, Body: tmplBodySummaryCommits},
+		{Name: tmplNameToc, Body: tmplBodyToc},
+		{Name: tmplNameToolbar, Body: tmplBodyToolbar},
+		{Name: tmplNameWarnings, Body: tmplBodyWarnings},
+		{Name: tmplNameSnipsMain, Body: tmplBodySnipsMain},
+	}
+}
+
 func makeHtmlTemplate() *template.Template {
-	return template.Must(
-		template.New("main").Funcs(common.MakeFuncMap()).Parse(
-			tmplBodyRepoLink +
-				tmplBodyItemCount +
-				tmplBodyIssue +
-				tmplBodyCommit +
-				tmplBodyOrganizations +
-				tmplBodyIssueSet +
-				tmplBodyRepoToCommitMap +
-				tmplBodyLabeledIssueSet +
-				tmplBodyLabeledCommitMap +
-				tmplBodyUser +
-				tmplBodyUserHighlights +
-				tmplBodySummaryIssueSet +
-				tmplBodySummaryCommits +
-				tmplBodySnipsMain))
+	t := template.New("main").Funcs(common.MakeFuncMap())
+	for _, nt := range HtmlTemplates() {
+		template.Must(t.Parse(nt.Body))
+	}
+	return t
+}
+
+// MakeHtmlReportWriter returns a report writer using the built-in templates,
+// overridden by the {{define}} blocks in the template files found in tmplDir.
+func MakeHtmlReportWriter(tmplDir string) (func(io.Writer, *types.Report) error, error) {
+	t := makeHtmlTemplate()
+	if err := common.ApplyOverrides(t, HtmlTemplates(), tmplDir); err != nil {
+		return nil, err
+	}
+	return func(w io.Writer, r *types.Report) error {
+		return t.ExecuteTemplate(w, tmplNameSnipsMain, r)
+	}, nil
 }
 
 func WriteHtmlReport(w io.Writer, r *types.Report) error {
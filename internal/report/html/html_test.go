@@ -2,10 +2,15 @@ package html_test
 
 import (
 	"bytes"
-	. "github.com/monopole/snips/internal/report/html"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/monopole/snips/internal/report/common"
+	. "github.com/monopole/snips/internal/report/html"
+
 	"github.com/monopole/snips/internal/types"
 	"github.com/stretchr/testify/assert"
 )
@@ -65,6 +70,14 @@ var (
 		Author:           "bob",
 		Pr:               &issue1,
 	}
+	commitBot = types.MyCommit{
+		RepoId:           repoId1,
+		Sha:              "0a1b2c3",
+		Url:              urlCommit2,
+		MessageFirstLine: "Bump golang.org/x/net from 0.7.0 to 0.17.0",
+		Committed:        time2,
+		Author:           "dependabot[bot]",
+	}
 	commit2 = types.MyCommit{
 		RepoId:           repoId1,
 		Sha:              "bbd9f61",
@@ -85,6 +98,43 @@ func Test_WriteHtmlIssue(t *testing.T) {
 			issue:  issue1,
 			result: "<code>2019-Jun-13</code> &nbsp; <a href=\"https://github.acmecorp.com/design-technology/3dx/pull/636\"> Fry the older bananas </a>",
 		},
+		"epicAndSprints": {
+			issue: types.MyIssue{
+				Title:   "Jira thing",
+				HtmlUrl: "https://issues.acmecorp.com/browse/MSFT-7",
+				Updated: issue1.Updated,
+				Epic:    "MSFT-100",
+				Sprints: []string{"Sprint 6", "Sprint 7"},
+			},
+			result: "<code>2019-Jun-13</code> &nbsp; <a href=\"https://issues.acmecorp.com/browse/MSFT-7\"> Jira thing </a> <span class=\"context\">MSFT-100 &middot; Sprint 6, Sprint 7</span>",
+		},
+		"sprintOnly": {
+			issue: types.MyIssue{
+				Title:   "Jira thing",
+				HtmlUrl: "https://issues.acmecorp.com/browse/MSFT-7",
+				Updated: issue1.Updated,
+				Sprints: []string{"Sprint 7"},
+			},
+			result: "<code>2019-Jun-13</code> &nbsp; <a href=\"https://issues.acmecorp.com/browse/MSFT-7\"> Jira thing </a> <span class=\"context\">Sprint 7</span>",
+		},
+		"comments": {
+			issue: types.MyIssue{
+				Title:   "Jira thing",
+				HtmlUrl: "https://issues.acmecorp.com/browse/MSFT-7",
+				Updated: issue1.Updated,
+				Comments: []types.MyComment{
+					{
+						Url:       "https://issues.acmecorp.com/browse/MSFT-7?focusedCommentId=3",
+						When:      issue1.Updated,
+						FirstLine: "Use <b>",
+					},
+				},
+			},
+			result: "<code>2019-Jun-13</code> &nbsp; <a href=\"https://issues.acmecorp.com/browse/MSFT-7\"> Jira thing </a>" +
+				" <span class=\"itemCount\">(1 comments)</span>\n<ul class=\"comments\">\n" +
+				"<li><code>2019-Jun-13 10:11</code> &nbsp; " +
+				"<a href=\"https://issues.acmecorp.com/browse/MSFT-7?focusedCommentId=3\">Use &lt;b&gt;</a></li>\n</ul>",
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -136,19 +186,25 @@ func Test_WriteHtmlLabeledIssueMap(t *testing.T) {
 					repoId2: {issue1, issue2},
 				},
 			},
-			result: `<h3> issues reviewed
+			result: `<h3 id="issues-reviewed"> issues reviewed
 <span class="itemCount">(4 issues in 2 repos)</span>
 </h3>
 <div class="issueMap">
-<h4> <a href="https://github.bob.com/bitCoinLosers/jupiterToast"> bitCoinLosers/jupiterToast </a> 
+<details class="repo" open>
+<summary><h4> <a href="https://github.bob.com/bitCoinLosers/jupiterToast"> bitCoinLosers/jupiterToast </a> 
 <span class="itemCount">(2 issues)</span>
-</h4>
+</h4></summary>
 
 <div class="oneIssue"> <code>2019-Jun-13</code> &nbsp; <a href="https://github.acmecorp.com/design-technology/3dx/pull/636"> Fry the older bananas </a> </div>
-<div class="oneIssue"> <code>2019-Jun-15</code> &nbsp; <a href="https://github.acmecorp.com/design-technology/argocd-manifests/pull/2555"> Indemnify the cheese eaters </a> </div><h4> <a href="https://github.bob.com/federationOfPlanets/marsToilet"> federationOfPlanets/marsToilet </a>  </h4>
+<div class="oneIssue"> <code>2019-Jun-15</code> &nbsp; <a href="https://github.acmecorp.com/design-technology/argocd-manifests/pull/2555"> Indemnify the cheese eaters </a> </div>
+</details><details class="repo" open>
+<summary><h4> <a href="https://github.bob.com/federationOfPlanets/marsToilet"> federationOfPlanets/marsToilet </a> 
+<span class="itemCount">(2 issues)</span>
+</h4></summary>
 
 <div class="oneIssue"> <code>2019-Jun-13</code> &nbsp; <a href="https://github.acmecorp.com/design-technology/3dx/pull/636"> Fry the older bananas </a> </div>
 <div class="oneIssue"> <code>2019-Jun-15</code> &nbsp; <a href="https://github.acmecorp.com/design-technology/argocd-manifests/pull/2555"> Indemnify the cheese eaters </a> </div>
+</details>
 </div>`,
 		},
 	}
@@ -176,9 +232,14 @@ func Test_WriteHtmlLabeledCommitMap(t *testing.T) {
 			m: map[types.RepoId][]*types.MyCommit{
 				repoId1: {&commit1, &commit2},
 			},
-			result: `<h3> commits  </h3>
+			result: `<h3 id="commits"> commits 
+<span class="itemCount">(2 commits to 1 repos)</span>
+</h3>
 <div class="issueMap">
-<h4> <a href="https://hoser.github.com/federationOfPlanets/marsToilet"> federationOfPlanets/marsToilet </a> </h4>
+<details class="repo" open>
+<summary><h4> <a href="https://hoser.github.com/federationOfPlanets/marsToilet"> federationOfPlanets/marsToilet </a> 
+<span class="itemCount">(2 commits)</span>
+</h4></summary>
 
 <div class="oneIssue"> <code>2019-Jun-13
 <a href="https://github.acmecorp.com/design-technology/argocd-manifests/pull/2663/commits/fc25519428f4f91813d5a8c324c73ada2d94b578">fc25519</a> (pull/<a href="https://github.acmecorp.com/design-technology/3dx/pull/636">600</a>)
@@ -188,6 +249,7 @@ func Test_WriteHtmlLabeledCommitMap(t *testing.T) {
 <a href="https://github.acmecorp.com/design-technology/argocd-manifests/pull/2663/commits/bbd9f61f0c1bb26e58641f15da872afce9f6c1ec">bbd9f61</a>
 </code>
 &nbsp; Fry the older bananas </div>
+</details>
 </div>`,
 		},
 	}
@@ -212,6 +274,9 @@ func Test_WriteHtmlReport(t *testing.T) {
 				Login:   "bobby",
 				Email:   "bob@acmecorp.com",
 				GhOrgs:  []types.MyGhOrg{org1, org2},
+				// Bobby has left.
+				AvatarUrl:   "https://avatars.acmecorp.com/bobby",
+				JiraAccount: types.JiraAccountInactive,
 				IssuesCreated: &types.IssueSet{
 					Domain: "hoser",
 					Groups: map[types.RepoId][]types.MyIssue{
@@ -223,7 +288,19 @@ func Test_WriteHtmlReport(t *testing.T) {
 				IssuesCommented: nil,
 				PrsReviewed:     nil,
 				Commits: map[types.RepoId][]*types.MyCommit{
-					repoId1: {&commit1, &commit2},
+					repoId1: {&commit1, &commit2, &commitBot},
+				},
+				Transitions: &types.TransitionSet{
+					Domain: "issues.acmecorp.com",
+					Groups: map[types.RepoId][]types.MyTransition{
+						repoId2: {{Issue: issue1, From: "To Do", To: "Done", When: issue1.Updated}},
+					},
+				},
+				TimeLogged: &types.WorklogSet{
+					Domain: "issues.acmecorp.com",
+					Groups: map[types.RepoId][]types.MyWorklog{
+						repoId2: {{Issue: issue1, Spent: 90 * time.Minute}},
+					},
 				},
 			},
 			result: "hey there",
@@ -238,14 +315,78 @@ func Test_WriteHtmlReport(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			var b bytes.Buffer
 			assert.NoError(t, WriteHtmlReport(&b, &types.Report{
-				Title: "hello I am the report title",
-				Dr:    dr,
-				Users: []*types.MyUser{&tt.dude},
+				Title:    "hello I am the report title",
+				Dr:       dr,
+				Users:    []*types.MyUser{&tt.dude},
+				Warnings: []string{"jira search [x < y] matched 20 issues"},
 			}))
 			//fmt.Println("-------------------")
 			//fmt.Println(b.String())
 			//fmt.Println("-------------------")
 			//assert.Equal(t, tt.result, b.String())
+			got := b.String()
+			assert.Contains(t, got, `<li><a href="#user-bobby">Bobby McBobface</a>`)
+			assert.Contains(t, got, `<li><a href="#bobby-issues-created">Issues Created</a> <span class="itemCount">(4)</span></li>`)
+			assert.Contains(t, got, `<h2 id="user-bobby">`)
+			assert.Contains(t, got, `<img class="avatar" src="https://avatars.acmecorp.com/bobby" alt="">`)
+			assert.Contains(t, got, `<span class="flag">inactive in Jira</span></h2>`)
+			assert.Contains(t, got, `<h3 id="bobby-issues-created">`)
+			assert.Contains(t, got, `<a href="#bobby-commits">commits</a>`)
+			assert.Contains(t, got, `<h3 id="bobby-status-transitions">`)
+			assert.Contains(t, got, `<em>To Do &rarr; Done</em>`)
+			assert.Contains(t, got, `<h3 id="bobby-time-logged">`)
+			assert.Contains(t, got, `<li><a href="#bobby-time-logged">Time Logged</a> <span class="itemCount">(1)</span></li>`)
+			assert.Contains(t, got, `(1.5h on 1 issues in 1 projects)`)
+			assert.Contains(t, got, `<code>1.5h</code>`)
+			assert.Contains(t, got, `<input id="snipsFilter"`)
+			assert.Contains(t, got, `<div class="oneIssue bot">`)
+			assert.Equal(t, 1, strings.Count(got, `class="oneIssue bot"`))
+			assert.Contains(t, got, `<li>jira search [x &lt; y] matched 20 issues</li>`)
+			// Everything is inline, so the report works offline.
+			assert.NotContains(t, got, `<script src`)
+			assert.NotContains(t, got, `<link`)
 		})
 	}
 }
+
+func Test_MakeHtmlReportWriter(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "issue.tmpl"), []byte(`
+{{define "tmplIssue" -}}
+<b>{{toUpper .Title}}</b>
+{{- end}}`), 0o644))
+	writeF, err := MakeHtmlReportWriter(dir)
+	assert.NoError(t, err)
+	var b bytes.Buffer
+	assert.NoError(t, writeF(&b, &types.Report{
+		Title: "overridden",
+		Dr:    &types.DayRange{Year: 2023, Month: 1, Day: 3, DayCount: 1},
+		Users: []*types.MyUser{{
+			Login: "bobby",
+			IssuesCreated: &types.IssueSet{
+				Domain: "github.com",
+				Groups: map[types.RepoId][]types.MyIssue{repoId1: {issue1}},
+			},
+		}},
+	}))
+	assert.Contains(t, b.String(), "<b>FRY THE OLDER BANANAS</b>")
+	assert.Contains(t, b.String(), "<h1>overridden</h1>")
+
+	_, err = MakeHtmlReportWriter(t.TempDir())
+	assert.ErrorContains(t, err, "no .tmpl files found")
+}
+
+func Test_HtmlTemplatesRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, common.WriteTemplateDir(dir, HtmlTemplates()))
+	writeF, err := MakeHtmlReportWriter(dir)
+	assert.NoError(t, err)
+	report := &types.Report{
+		Title: "same",
+		Dr:    &types.DayRange{Year: 2023, Month: 1, Day: 3, DayCount: 1},
+	}
+	var want, got bytes.Buffer
+	assert.NoError(t, WriteHtmlReport(&want, report))
+	assert.NoError(t, writeF(&got, report))
+	assert.Equal(t, want.String(), got.String())
+}
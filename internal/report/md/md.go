@@ -1,57 +1,76 @@
 package md
 
 import (
+	"io"
+	"strings"
+	"text/template"
+
 	"github.com/monopole/snips/internal/report/common"
 	"github.com/monopole/snips/internal/types"
-	"html/template"
-	"io"
 )
 
 const (
+	tmplNameRepoLink = "tmplNameRepoLink"
+	tmplBodyRepoLink = `
+{{define "` + tmplNameRepoLink + `" -}}
+[{{mdEscape .Label}}](https://{{mdUrl .HRef}})
+{{- end}}
+`
 	tmplNameIssue = "tmplNameIssue"
 	tmplBodyIssue = `
 {{define "` + tmplNameIssue + `" -}}
-` + "`{{snipDate .Updated}}`" + ` [{{.Title}}]({{.HtmlUrl}})
+` + "`{{snipDate .Updated}}`" + ` [{{mdEscape .Title}}]({{mdUrl .HtmlUrl}})
+{{- if or .Epic .Sprints}} _({{mdEscape .Epic}}{{if and .Epic .Sprints}}; {{end}}{{mdEscape (join .Sprints ", ")}})_{{end}}
+{{- if .Votes}} _({{mdEscape (join .Votes ", ")}})_{{end}}
+{{- if .Comments}} _({{len .Comments}} comments)_
+{{- range .Comments}}
+    - ` + "`{{snipTime .When}}`" + ` [{{mdEscape .FirstLine}}]({{mdUrl .Url}})
+{{- end}}
+{{- end}}
 {{- end}}
 `
 	tmplNameCommit = "tmplNameCommit"
 	tmplBodyCommit = `
 {{define "` + tmplNameCommit + `" -}}
-` + "`{{snipDate .Committed}}`" + " [`{{shaSmall .Sha}}`]({{.Url}})" + `
-{{- if .Pr}} (pull/[{{.Pr.Number}}]({{.Pr.HtmlUrl}})){{end}} {{.MessageFirstLine}}
+` + "`{{snipDate .Committed}}`" + " [`{{shaSmall .Sha}}`]({{mdUrl .Url}})" + `
+{{- if .Pr}} (pull/[{{.Pr.Number}}]({{mdUrl .Pr.HtmlUrl}})){{end}} {{mdEscape .MessageFirstLine}}
 {{- end}}
 `
 	tmplNameRepoToIssueSet = "tmplNameRepoToIssueSet"
 	tmplBodyRepoToIssueSet = `
 {{define "` + tmplNameRepoToIssueSet + `" -}}
 {{range $repo, $list := .Groups }}
-#### {{$repo}}
+
+#### {{template "` + tmplNameRepoLink + `" issueGroup $.Domain $.GroupedBy $repo}}
 {{range $i, $issue := $list }}
   - {{template "` + tmplNameIssue + `" $issue}}
 {{- end}}
-{{end}}
+{{- end}}
 {{- end}}
 `
 	tmplNameRepoToCommitMap = "tmplNameRepoToCommitMap"
 	tmplBodyRepoToCommitMap = `
 {{define "` + tmplNameRepoToCommitMap + `" -}}
-{{range $repo, $list := . }}
-#### {{$repo}}
+{{range $repo, $list := .M }}
+
+#### {{template "` + tmplNameRepoLink + `" domainAndRepo $.Dgh $repo}}
 {{range $i, $issue := $list }}
  - {{template "` + tmplNameCommit + `" $issue}}
 {{- end}}
-{{end}}
+{{- end}}
 {{- end}}
 `
 
 	tmplNameLabelledIssueSet = "tmplNameLabelledIssueSet"
 	tmplBodyLabelledIssueSet = `
 {{define "` + tmplNameLabelledIssueSet + `" -}}
-{{if .ISet -}}
-### {{.Label}}:
-{{template "` + tmplNameRepoToIssueSet + `" .ISet}}
+{{if (or (eq .ISet nil) .ISet.IsEmpty) -}}
+### No {{mdEscape .Label}}
 {{- else -}}
-### No {{.Label}}
+### {{mdEscape .Label}}
+
+_{{.ISet.Count}} issues in {{.ISet.RepoCount}} repos_
+{{- template "` + tmplNameRepoToIssueSet + `" .ISet}}
 {{- end}}
 {{- end}}
 `
@@ -59,58 +78,224 @@ const (
 	tmplBodyLabelledCommitMap = `
 {{define "` + tmplNameLabelledCommitMap + `" -}}
 {{if .M -}}
-### {{.Label}}
-{{template "` + tmplNameRepoToCommitMap + `" .M}}
+### {{mdEscape .Label}}
+
+_{{mapTotalCommits .M}} commits to {{len .M}} repos_
+{{- template "` + tmplNameRepoToCommitMap + `" (domainAndCommitMap .Dgh .M)}}
 {{- else -}}
-### No {{.Label}}
+### No {{mdEscape .Label}}
+{{- end}}
+{{- end}}
+`
+	tmplNameLabelledWorklogSet = "tmplNameLabelledWorklogSet"
+	tmplBodyLabelledWorklogSet = `
+{{define "` + tmplNameLabelledWorklogSet + `" -}}
+{{if .WSet.IsEmpty -}}
+### No {{mdEscape .Label}}
+{{- else -}}
+### {{mdEscape .Label}}
+
+_{{hours .WSet.Total}} on {{.WSet.Count}} issues in {{.WSet.RepoCount}} projects_
+{{- range $repo, $list := .WSet.Groups }}
+
+#### {{template "` + tmplNameRepoLink + `" issueGroup $.WSet.Domain $.WSet.GroupedBy $repo}} ({{hours ($.WSet.RepoTotal $repo)}})
+{{range $i, $wl := $list }}
+  - ` + "`{{hours $wl.Spent}}`" + ` {{template "` + tmplNameIssue + `" $wl.Issue}}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- end}}
+`
+	tmplNameLabelledTransitionSet = "tmplNameLabelledTransitionSet"
+	tmplBodyLabelledTransitionSet = `
+{{define "` + tmplNameLabelledTransitionSet + `" -}}
+{{if .TSet.IsEmpty -}}
+### No {{mdEscape .Label}}
+{{- else -}}
+### {{mdEscape .Label}}
+
+_{{.TSet.Count}} transitions in {{.TSet.RepoCount}} projects_
+{{- range $repo, $list := .TSet.Groups }}
+
+#### {{template "` + tmplNameRepoLink + `" issueGroup $.TSet.Domain $.TSet.GroupedBy $repo}}
+{{range $i, $tr := $list }}
+  - ` + "`{{snipDate $tr.When}}`" + ` [{{mdEscape $tr.Issue.Title}}]({{mdUrl $tr.Issue.HtmlUrl}}) _{{mdEscape $tr.From}} → {{mdEscape $tr.To}}_
+{{- end}}
+{{- end}}
 {{- end}}
 {{- end}}
 `
 	tmplNameOrganizations = "tmplNameOrganizations"
 	tmplBodyOrganizations = `
 {{define "` + tmplNameOrganizations + `" -}}
-### Organizations
-{{range . -}} * {{if .Name}}{{.Name}} {{end}} {{.Login}}
-{{end}}
+### GitHub Organizations
+{{range .GhOrgs}}
+ * [{{if .Name}}{{mdEscape .Name}} {{end}}{{mdEscape .Login}}](https://{{$.Dgh}}/{{mdUrl .Login}})
+{{- end}}
+{{- end}}
+`
+	tmplNameSummaryIssueSet = "tmplNameSummaryIssueSet"
+	tmplBodySummaryIssueSet = `
+{{define "` + tmplNameSummaryIssueSet + `" -}}
+{{if (or (eq .ISet nil) .ISet.IsEmpty) -}}
+| {{mdEscape .Label}} | 0 | 0 |
+{{- else -}}
+| {{mdEscape .Label}} | {{.ISet.Count}} | {{.ISet.RepoCount}} |
+{{- end}}
+{{- end}}
+`
+	tmplNameSummaryCommits = "tmplNameSummaryCommits"
+	tmplBodySummaryCommits = `
+{{define "` + tmplNameSummaryCommits + `" -}}
+| {{mdEscape .Label}} | {{mapTotalCommits .M}} | {{len .M}} |
+{{- end}}
+`
+	tmplNameUserHighlights = "tmplNameUserHighlights"
+	tmplBodyUserHighlights = `
+{{define "` + tmplNameUserHighlights + `" -}}
+| what | items | repos |
+|:-----|------:|------:|
+{{template "` + tmplNameSummaryIssueSet + `" (labeledIssueSet "issues created" .U.IssuesCreated)}}
+{{template "` + tmplNameSummaryIssueSet + `" (labeledIssueSet "issues commented" .U.IssuesCommented)}}
+{{template "` + tmplNameSummaryIssueSet + `" (labeledIssueSet "issues closed" .U.IssuesClosed)}}
+{{template "` + tmplNameSummaryIssueSet + `" (labeledIssueSet "PRs reviewed" .U.PrsReviewed)}}
+{{template "` + tmplNameSummaryCommits + `" (labeledCommitMap "commits" .Dgh .U.Commits)}}
 {{- end}}
 `
 	tmplNameUser = "tmplNameUser"
 	tmplBodyUser = `
 {{define "` + tmplNameUser + `"}}
-## {{.Name}} (_{{if .Email}}{{.Email}}{{else}}{{.Login}}{{end}}_)
-{{if .GhOrgs}}
-{{template "` + tmplNameOrganizations + `" .GhOrgs}}
-{{else}}
-### no organizations
+## {{mdEscape .U.Name}} (_{{if .U.Email}}{{mdEscape .U.Email}}{{else}}{{mdEscape .U.Login}}{{end}}_){{with jiraFlag .U}} **{{.}}**{{end}}
+
+{{template "` + tmplNameUserHighlights + `" .}}
+
+{{if .U.GhOrgs -}}
+{{template "` + tmplNameOrganizations + `" domainAndOrgs .Dgh .U.GhOrgs}}
+{{- else -}}
+### No organizations
+{{- end}}
+
+{{template "` + tmplNameLabelledIssueSet + `" (labeledIssueSet "Issues Created" .U.IssuesCreated)}}
+
+{{template "` + tmplNameLabelledIssueSet + `" (labeledIssueSet "Issues Commented" .U.IssuesCommented)}}
+
+{{template "` + tmplNameLabelledIssueSet + `" (labeledIssueSet "Issues Closed" .U.IssuesClosed)}}
+
+{{template "` + tmplNameLabelledIssueSet + `" (labeledIssueSet "PRs Reviewed" .U.PrsReviewed)}}
+
+{{template "` + tmplNameLabelledCommitMap + `" (labeledCommitMap "Commits" .Dgh .U.Commits)}}
+{{range .U.Custom}}
+{{template "` + tmplNameLabelledIssueSet + `" (labeledIssueSet .Label .Issues)}}
+{{end -}}
+{{if .U.Transitions}}
+{{template "` + tmplNameLabelledTransitionSet + `" (labeledTransitionSet "Status Transitions" .U.Transitions)}}
+{{end -}}
+{{if .U.TimeLogged}}
+{{template "` + tmplNameLabelledWorklogSet + `" (labeledWorklogSet "Time Logged" .U.TimeLogged)}}
 {{end}}
-{{template "` + tmplNameLabelledIssueSet + `" (labeledIssueSet "Issues Created" .IssuesCreated)}}
-{{template "` + tmplNameLabelledIssueSet + `" (labeledIssueSet "Issues Closed" .IssuesClosed)}}
-{{template "` + tmplNameLabelledIssueSet + `" (labeledIssueSet "PRs Reviewed" .PrsReviewed)}}
-{{template "` + tmplNameLabelledCommitMap + `" (labeledCommitMap "Commits" "foo.com" .Commits)}}
 ---
 {{end}}
+`
+	tmplNameWarnings = "tmplNameWarnings"
+	tmplBodyWarnings = `
+{{define "` + tmplNameWarnings + `" -}}
+> **This report is incomplete:**
+{{- range .}}
+> - {{mdEscape .}}
+{{- end}}
+{{end}}
 `
 	tmplNameSnipsMain = "tmplNameSnipsMain"
 	tmplBodySnipsMain = `
 {{define "` + tmplNameSnipsMain + `" -}}
-# {{.Title}}
+# {{if .Title}}{{mdEscape .Title}}{{else}}Activity at {{.DomainGh}}{{end}}
+
 _{{ prettyDateRange .Dr }}_
-{{range .Users -}}
-   {{ template "` + tmplNameUser + `" . -}}
-{{- else -}}
-__no users__
+{{if .Warnings}}
+{{template "` + tmplNameWarnings + `" .Warnings}}
 {{- end}}
+{{- range .Users -}}
+   {{ template "` + tmplNameUser + `" (domainsAndUser $.DomainGh $.DomainJira .) -}}
+{{- else }}
+__no users__
+{{end}}
 {{- end}}
 `
 )
 
+// MdTemplates returns the built-in templates, in parse order.
+func MdTemplates() []common.NamedTemplate {
+	return []common.NamedTemplate{
+		{Name: tmplNameRepoLink, Body: tmplBodyRepoLink},
+		{Name: tmplNameIssue, Body: tmplBodyIssue},
+		{Name: tmplNameCommit, Body: tmplBodyCommit},
+		{Name: tmplNameOrganizations, Body: tmplBodyOrganizations},
+		{Name: tmplNameRepoToIssueSet, Body: tmplBodyRepoToIssueSet},
+		{Name: tmplNameRepoToCommitMap, Body: tmplBodyRepoToCommitMap},
+		{Name: tmplNameLabelledIssueSet, Body: tmplBodyLabelledIssueSet},
+		{Name: tmplNameLabelledCommitMap, Body: tmplBodyLabelledCommitMap},
+		{Name: tmplNameLabelledTransitionSet, Body: tmplBodyLabelledTransitionSet},
+		{Name: tmplNameLabelledWorklogSet, Body: tmplBodyLabelledWorklogSet},
+		{Name: tmplNameSummaryIssueSet, Body: tmplBodySummaryIssueSet},
+		{Name: tmplNameSummaryCommits, Body: tmplBodySummaryCommits},
+		{Name: tmplNameUserHighlights, Body: tmplBodyUserHighlights},
+		{Name: tmplNameUser, Body: tmplBodyUser},
+		{Name: tmplNameWarnings, Body: tmplBodyWarnings},
+		{Name: tmplNameSnipsMain, Body: tmplBodySnipsMain},
+	}
+}
+
+// mdSpecials are characters that can trigger markdown formatting in running text.
+// An ampersand is escaped so that text like "&lt;" isn't taken as an entity.
+var mdSpecials = strings.NewReplacer(
+	`&`, `&amp;`,
+	`\`, `\\`,
+	"`", "\\`",
+	`*`, `\*`,
+	`_`, `\_`,
+	`[`, `\[`,
+	`]`, `\]`,
+	`<`, `\<`,
+	`>`, `\>`,
+	`|`, `\|`,
+	`#`, `\#`,
+)
+
+// mdEscape escapes text so that it renders literally in markdown.
+func mdEscape(s string) string {
+	return mdSpecials.Replace(s)
+}
+
+// mdUrl escapes the characters that would end a markdown link destination early.
+func mdUrl(s string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(s)
+}
+
+func makeFuncMap() template.FuncMap {
+	m := common.MakeFuncMap()
+	m["mdEscape"] = mdEscape
+	m["mdUrl"] = mdUrl
+	return m
+}
+
 func makeMdTemplate() *template.Template {
-	return template.Must(
-		template.New("main").Funcs(common.MakeFuncMap()).Parse(
-			tmplBodyIssue + tmplBodyCommit + tmplBodyOrganizations +
-				tmplBodyRepoToIssueSet + tmplBodyRepoToCommitMap +
-				tmplBodyLabelledIssueSet + tmplBodyLabelledCommitMap +
-				tmplBodyUser + tmplBodySnipsMain))
+	t := template.New("main").Funcs(makeFuncMap())
+	for _, nt := range MdTemplates() {
+		template.Must(t.Parse(nt.Body))
+	}
+	return t
+}
+
+// MakeMdReportWriter returns a report writer using the built-in templates,
+// overridden by the {{define}} blocks in the template files found in tmplDir.
+func MakeMdReportWriter(tmplDir string) (func(io.Writer, *types.Report) error, error) {
+	t := makeMdTemplate()
+	if err := common.ApplyOverrides(t, MdTemplates(), tmplDir); err != nil {
+		return nil, err
+	}
+	return func(w io.Writer, r *types.Report) error {
+		return t.ExecuteTemplate(w, tmplNameSnipsMain, r)
+	}, nil
 }
 
 func WriteMdReport(w io.Writer, r *types.Report) error {
@@ -131,7 +316,7 @@ func WriteMdLabelledIssueSet(w io.Writer, l string, is *types.IssueSet) error {
 }
 
 func WriteMdLabelledCommitMap(
-	w io.Writer, l string, m map[types.RepoId][]*types.MyCommit) error {
+	w io.Writer, l string, dGh string, m map[types.RepoId][]*types.MyCommit) error {
 	return makeMdTemplate().ExecuteTemplate(
-		w, tmplNameLabelledCommitMap, common.LabeledCommitMap(l, "d.com", m))
+		w, tmplNameLabelledCommitMap, common.LabeledCommitMap(l, dGh, m))
 }
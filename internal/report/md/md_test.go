@@ -2,10 +2,115 @@ package md_test
 
 import (
 	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
 	. "github.com/monopole/snips/internal/report/md"
 	"github.com/monopole/snips/internal/types"
 	"github.com/stretchr/testify/assert"
-	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+const (
+	urlPr1 = "https://github.acmecorp.com/design-technology/3dx/pull/636"
+	urlPr2 = "https://github.acmecorp.com/design-technology/argocd-manifests/pull/2555"
+
+	urlCommit1 = "https://github.acmecorp.com/design-technology/argocd-manifests/pull/2663/commits/fc25519428f4f91813d5a8c324c73ada2d94b578"
+	urlCommit2 = "https://github.acmecorp.com/design-technology/argocd-manifests/pull/2663/commits/bbd9f61f0c1bb26e58641f15da872afce9f6c1ec"
+
+	ts1 = "13 Jun 19 10:11 PST"
+	ts2 = "15 Jun 19 10:17 PST"
+
+	orgName1 = "federationOfPlanets"
+	orgName2 = "bitCoinLosers"
+
+	title1 = "Fry the older bananas"
+	title2 = "Indemnify the cheese eaters"
+)
+
+var (
+	org1 = types.MyGhOrg{Name: orgName1, Login: "Micheal"}
+	org2 = types.MyGhOrg{Name: orgName2, Login: "Barton"}
+
+	repoId1 = types.RepoId{
+		Org:  orgName1,
+		Name: "marsToilet",
+	}
+	repoId2 = types.RepoId{
+		Org:  orgName2,
+		Name: "jupiterToast",
+	}
+	repoIdJira = types.RepoId{
+		Org:  "microsoft developers",
+		Name: "MSFT",
+	}
+
+	time1, _ = time.Parse(time.RFC822, ts1)
+	time2, _ = time.Parse(time.RFC822, ts2)
+	issue1   = types.MyIssue{
+		RepoId:  repoId1,
+		Number:  600,
+		Title:   title1,
+		HtmlUrl: urlPr1,
+		Updated: time1,
+	}
+	issue2 = types.MyIssue{
+		RepoId:  repoId1,
+		Number:  600,
+		Title:   title2,
+		HtmlUrl: urlPr2,
+		Updated: time2,
+	}
+	issueNasty = types.MyIssue{
+		RepoId:  repoIdJira,
+		Number:  12,
+		Title:   "Use <b> & *not* [brackets] in foo_bar | baz",
+		HtmlUrl: "https://issues.acmecorp.com/browse/MSFT-12",
+		Updated: time2,
+	}
+	issueJira = types.MyIssue{
+		RepoId:    repoIdJira,
+		Number:    7,
+		Title:     "Log some time",
+		HtmlUrl:   "https://issues.acmecorp.com/browse/MSFT-7",
+		Updated:   time1,
+		Epic:      "MSFT-100",
+		EpicTitle: "Big_thing",
+		Sprints:   []string{"Sprint 6", "Sprint 7"},
+	}
+	issueCommented = types.MyIssue{
+		RepoId:  repoIdJira,
+		Number:  9,
+		Title:   "Discuss things",
+		HtmlUrl: "https://issues.acmecorp.com/browse/MSFT-9",
+		Updated: time2,
+		Comments: []types.MyComment{
+			{Url: "https://issues.acmecorp.com/browse/MSFT-9?focusedCommentId=1", When: time1, FirstLine: "Seems *fine*"},
+			{Url: "https://issues.acmecorp.com/browse/MSFT-9?focusedCommentId=2", When: time2, FirstLine: "Shipped"},
+		},
+	}
+	commit1 = types.MyCommit{
+		RepoId:           repoId1,
+		Sha:              "fc25519",
+		Url:              urlCommit1,
+		MessageFirstLine: "Fry the older bananas",
+		Committed:        time1,
+		Author:           "bob",
+		Pr:               &issue1,
+	}
+	commit2 = types.MyCommit{
+		RepoId:           repoId1,
+		Sha:              "bbd9f61",
+		Url:              urlCommit2,
+		MessageFirstLine: "Fry the older bananas",
+		Committed:        time1,
+		Author:           "bob",
+		Pr:               nil,
+	}
 )
 
 func Test_WriteMdIssue(t *testing.T) {
@@ -17,11 +122,19 @@ func Test_WriteMdIssue(t *testing.T) {
 			issue:  issue1,
 			result: "`2019-Jun-13` [Fry the older bananas](https://github.acmecorp.com/design-technology/3dx/pull/636)",
 		},
+		"escaped": {
+			issue:  issueNasty,
+			result: "`2019-Jun-15` [Use \\<b\\> &amp; \\*not\\* \\[brackets\\] in foo\\_bar \\| baz](https://issues.acmecorp.com/browse/MSFT-12)",
+		},
+		"entity": {
+			issue:  types.MyIssue{Title: "Show &lt;b&gt; literally", HtmlUrl: urlPr1, Updated: time2},
+			result: "`2019-Jun-15` [Show &amp;lt;b&amp;gt; literally](" + urlPr1 + ")",
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 			var b bytes.Buffer
-			WriteMdIssue(&b, &tt.issue)
+			assert.NoError(t, WriteMdIssue(&b, &tt.issue))
 			assert.Equal(t, tt.result, b.String())
 		})
 	}
@@ -40,7 +153,7 @@ func Test_WriteMdCommit(t *testing.T) {
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 			var b bytes.Buffer
-			WriteMdCommit(&b, &tt.commit)
+			assert.NoError(t, WriteMdCommit(&b, &tt.commit))
 			assert.Equal(t, tt.result, b.String())
 		})
 	}
@@ -56,33 +169,134 @@ func Test_WriteMdLabelledIssueMap(t *testing.T) {
 			l:      "issues commented",
 			result: `### No issues commented`,
 		},
+		"empty": {
+			l:      "issues commented",
+			iSet:   &types.IssueSet{Domain: "github.com"},
+			result: `### No issues commented`,
+		},
+		"custom label": {
+			l:      "*P1* bugs_open",
+			iSet:   &types.IssueSet{Domain: "github.com"},
+			result: `### No \*P1\* bugs\_open`,
+		},
 		"t2": {
 			l: "issues reviewed",
 			iSet: &types.IssueSet{
-				Domain: "",
+				Domain: "github.acmecorp.com",
 				Groups: map[types.RepoId][]types.MyIssue{
 					repoId1: {issue1, issue2},
 					repoId2: {issue1, issue2},
 				},
 			},
-			result: `### issues reviewed:
+			result: `### issues reviewed
+
+_4 issues in 2 repos_
 
-#### bitCoinLosers/jupiterToast
+#### [bitCoinLosers/jupiterToast](https://github.acmecorp.com/bitCoinLosers/jupiterToast)
 
   - ` + "`2019-Jun-13`" + ` [Fry the older bananas](https://github.acmecorp.com/design-technology/3dx/pull/636)
   - ` + "`2019-Jun-15`" + ` [Indemnify the cheese eaters](https://github.acmecorp.com/design-technology/argocd-manifests/pull/2555)
 
-#### federationOfPlanets/marsToilet
+#### [federationOfPlanets/marsToilet](https://github.acmecorp.com/federationOfPlanets/marsToilet)
 
   - ` + "`2019-Jun-13`" + ` [Fry the older bananas](https://github.acmecorp.com/design-technology/3dx/pull/636)
-  - ` + "`2019-Jun-15`" + ` [Indemnify the cheese eaters](https://github.acmecorp.com/design-technology/argocd-manifests/pull/2555)
-`,
+  - ` + "`2019-Jun-15`" + ` [Indemnify the cheese eaters](https://github.acmecorp.com/design-technology/argocd-manifests/pull/2555)`,
+		},
+		"byEpic": {
+			l: "issues created",
+			iSet: &types.IssueSet{
+				Domain:    "issues.acmecorp.com",
+				GroupedBy: types.GroupByEpic,
+				Groups: map[types.RepoId][]types.MyIssue{
+					{Org: "Big_thing", Name: "MSFT-100"}: {issueJira},
+				},
+			},
+			result: `### issues created
+
+_1 issues in 1 repos_
+
+#### [MSFT-100 Big\_thing](https://issues.acmecorp.com/browse/MSFT-100)
+
+  - ` + "`2019-Jun-13`" + ` [Log some time](https://issues.acmecorp.com/browse/MSFT-7) _(MSFT-100; Sprint 6, Sprint 7)_`,
+		},
+		"bySprint": {
+			l: "issues created",
+			iSet: &types.IssueSet{
+				Domain:    "issues.acmecorp.com",
+				GroupedBy: types.GroupBySprint,
+				Groups: map[types.RepoId][]types.MyIssue{
+					{Name: "Sprint 7"}: {issueNasty},
+				},
+			},
+			result: `### issues created
+
+_1 issues in 1 repos_
+
+#### [Sprint 7](https://issues.acmecorp.com/issues/?jql=sprint+%3D+%22Sprint+7%22)
+
+  - ` + "`2019-Jun-15`" + ` [Use \<b\> &amp; \*not\* \[brackets\] in foo\_bar \| baz](https://issues.acmecorp.com/browse/MSFT-12)`,
+		},
+		"jira": {
+			l: "issues created",
+			iSet: &types.IssueSet{
+				Domain: "issues.acmecorp.com",
+				Groups: map[types.RepoId][]types.MyIssue{
+					repoIdJira: {issueNasty},
+				},
+			},
+			result: `### issues created
+
+_1 issues in 1 repos_
+
+#### [microsoft developers/MSFT](https://issues.acmecorp.com/projects/MSFT/issues)
+
+  - ` + "`2019-Jun-15`" + ` [Use \<b\> &amp; \*not\* \[brackets\] in foo\_bar \| baz](https://issues.acmecorp.com/browse/MSFT-12)`,
+		},
+		"gerrit": {
+			l: "PRs reviewed",
+			iSet: &types.IssueSet{
+				Domain: "github.acmecorp.com",
+				Groups: map[types.RepoId][]types.MyIssue{
+					{Org: "review.acmecorp.com", Name: "platform/build", Host: types.HostGerrit}: {{
+						Number:  11,
+						Title:   "Speed up the build",
+						HtmlUrl: "https://review.acmecorp.com/c/platform/build/+/11",
+						Updated: time1,
+						Votes:   []string{"Code-Review+2", "Verified+1"},
+					}},
+				},
+			},
+			result: `### PRs reviewed
+
+_1 issues in 1 repos_
+
+#### [review.acmecorp.com/platform/build](https://review.acmecorp.com/q/project:platform/build)
+
+  - ` + "`2019-Jun-13`" + ` [Speed up the build](https://review.acmecorp.com/c/platform/build/+/11) _(Code-Review+2, Verified+1)_`,
+		},
+		"comments": {
+			l: "issues commented",
+			iSet: &types.IssueSet{
+				Domain: "issues.acmecorp.com",
+				Groups: map[types.RepoId][]types.MyIssue{
+					repoIdJira: {issueCommented},
+				},
+			},
+			result: `### issues commented
+
+_1 issues in 1 repos_
+
+#### [microsoft developers/MSFT](https://issues.acmecorp.com/projects/MSFT/issues)
+
+  - ` + "`2019-Jun-15`" + ` [Discuss things](https://issues.acmecorp.com/browse/MSFT-9) _(2 comments)_
+    - ` + "`2019-Jun-13 10:11`" + ` [Seems \*fine\*](https://issues.acmecorp.com/browse/MSFT-9?focusedCommentId=1)
+    - ` + "`2019-Jun-15 10:17`" + ` [Shipped](https://issues.acmecorp.com/browse/MSFT-9?focusedCommentId=2)`,
 		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 			var b bytes.Buffer
-			WriteMdLabelledIssueSet(&b, tt.l, tt.iSet)
+			assert.NoError(t, WriteMdLabelledIssueSet(&b, tt.l, tt.iSet))
 			assert.Equal(t, tt.result, b.String())
 		})
 	}
@@ -105,69 +319,125 @@ func Test_WriteMdLabelledCommitMap(t *testing.T) {
 			},
 			result: `### commits
 
-#### federationOfPlanets/marsToilet
+_2 commits to 1 repos_
+
+#### [federationOfPlanets/marsToilet](https://github.acmecorp.com/federationOfPlanets/marsToilet)
 
 ` + " - `2019-Jun-13` [`fc25519`]" + `(https://github.acmecorp.com/design-technology/argocd-manifests/pull/2663/commits/fc25519428f4f91813d5a8c324c73ada2d94b578) (pull/[600](https://github.acmecorp.com/design-technology/3dx/pull/636)) Fry the older bananas
-` + " - `2019-Jun-13` [`bbd9f61`]" + `(https://github.acmecorp.com/design-technology/argocd-manifests/pull/2663/commits/bbd9f61f0c1bb26e58641f15da872afce9f6c1ec) Fry the older bananas
-`,
+` + " - `2019-Jun-13` [`bbd9f61`]" + `(https://github.acmecorp.com/design-technology/argocd-manifests/pull/2663/commits/bbd9f61f0c1bb26e58641f15da872afce9f6c1ec) Fry the older bananas`,
 		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 			var b bytes.Buffer
-			WriteMdLabelledCommitMap(&b, tt.l, tt.m)
+			assert.NoError(t, WriteMdLabelledCommitMap(&b, tt.l, "github.acmecorp.com", tt.m))
 			assert.Equal(t, tt.result, b.String())
 		})
 	}
 }
 
 func Test_WriteMdReport(t *testing.T) {
+	dr, err := types.MakeDayRange("2023/01/03", "", 14)
+	if err != nil {
+		t.Fatalf("bad time: %s", err.Error())
+	}
 	tests := map[string]struct {
-		dude   types.MyUser
-		result string
+		report types.Report
 	}{
-		"t1": {
-			dude: types.MyUser{
-				Name:    "Bobby Bobface",
-				Company: "TESLA",
-				Login:   "bobby",
-				Email:   "bob@acmecorp.com",
-				GhOrgs:  []types.MyGhOrg{org1, org2},
-				IssuesCreated: &types.IssueSet{
-					Domain: "hoserface",
-					Groups: map[types.RepoId][]types.MyIssue{
-						repoId1: {issue1, issue2},
-						repoId2: {issue1, issue2},
-					},
+		"report": {
+			report: types.Report{
+				Title:      "hello I am the report title",
+				DomainGh:   "github.acmecorp.com",
+				DomainJira: "issues.acmecorp.com",
+				Dr:         dr,
+				Warnings: []string{
+					"jira search [creator = \"bobby\"] matched 12000 issues, but only the first 10000 are shown",
 				},
-				IssuesClosed:    nil,
-				IssuesCommented: nil,
-				PrsReviewed:     nil,
-				Commits: map[types.RepoId][]*types.MyCommit{
-					repoId1: {&commit1, &commit2},
+				Users: []*types.MyUser{
+					{
+						Name:    "Bobby Bobface",
+						Company: "TESLA",
+						Login:   "bobby",
+						Email:   "bob@acmecorp.com",
+						GhOrgs:  []types.MyGhOrg{org1, org2},
+						IssuesCreated: &types.IssueSet{
+							Domain: "github.acmecorp.com",
+							Groups: map[types.RepoId][]types.MyIssue{
+								repoId1: {issue1, issue2},
+								repoId2: {issue1, issue2},
+							},
+						},
+						IssuesClosed: nil,
+						IssuesCommented: &types.IssueSet{
+							Domain: "issues.acmecorp.com",
+							Groups: map[types.RepoId][]types.MyIssue{
+								repoIdJira: {issueNasty},
+							},
+						},
+						PrsReviewed: nil,
+						Commits: map[types.RepoId][]*types.MyCommit{
+							repoId1: {&commit1, &commit2},
+						},
+						Transitions: &types.TransitionSet{
+							Domain: "issues.acmecorp.com",
+							Groups: map[types.RepoId][]types.MyTransition{
+								repoIdJira: {
+									{Issue: issueJira, From: "In Progress", To: "Done", When: time2},
+									{Issue: issueJira, From: "To Do", To: "In Progress", When: time1},
+								},
+							},
+						},
+						TimeLogged: &types.WorklogSet{
+							Domain: "issues.acmecorp.com",
+							Groups: map[types.RepoId][]types.MyWorklog{
+								repoIdJira: {
+									{Issue: issueNasty, Spent: 2*time.Hour + 15*time.Minute},
+									{Issue: issueJira, Spent: 30 * time.Minute},
+								},
+							},
+						},
+						Custom: []types.CustomIssueSet{
+							{
+								Label: "*P1* on_call tickets",
+								Issues: &types.IssueSet{
+									Domain: "issues.acmecorp.com",
+									Groups: map[types.RepoId][]types.MyIssue{
+										repoIdJira: {issueJira},
+									},
+								},
+							},
+							{
+								Label:  "Security PRs",
+								Issues: &types.IssueSet{Domain: "github.acmecorp.com"},
+							},
+						},
+					},
+					{
+						Name:        "Alice_Underscore",
+						Login:       "alice",
+						JiraAccount: types.JiraAccountUnknown,
+					},
 				},
 			},
-			result: "hey there",
+		},
+		"noUsers": {
+			report: types.Report{
+				DomainGh: "github.com",
+				Dr:       dr,
+			},
 		},
 	}
-	dr, err := types.MakeDayRange("2023/01/03", "", 0)
-	if err != nil {
-		t.Fatalf("bad time: %s", err.Error())
-	}
-
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
 			var b bytes.Buffer
-			assert.NoError(t, WriteMdReport(&b, &types.Report{
-				Title:    "hello I am the report title",
-				DomainGh: "github.com",
-				Dr:       dr,
-				Users:    []*types.MyUser{&tt.dude},
-			}))
-			//fmt.Println("+++++++++++++++++++++++++++++++++++++++++++++++++")
-			//fmt.Println(b.String())
-			//fmt.Println("+++++++++++++++++++++++++++++++++++++++++++++++++")
-			//assert.Equal(t, tt.result, b.String())
+			assert.NoError(t, WriteMdReport(&b, &tt.report))
+			golden := filepath.Join("testdata", name+".md")
+			if *update {
+				assert.NoError(t, os.WriteFile(golden, b.Bytes(), 0o644))
+			}
+			want, err := os.ReadFile(golden)
+			assert.NoError(t, err)
+			assert.Equal(t, string(want), b.String())
 		})
 	}
 }
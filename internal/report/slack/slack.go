@@ -0,0 +1,162 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/monopole/snips/internal/myhttp"
+	"github.com/monopole/snips/internal/report/common"
+	"github.com/monopole/snips/internal/types"
+)
+
+// Limits imposed by Slack on messages posted via webhook.
+// https://api.slack.com/reference/block-kit/blocks
+const (
+	maxBlocks       = 50
+	maxSectionText  = 3000
+	maxHeaderText   = 150
+	maxTitleText    = 120
+	maxItemsPerRepo = 5
+)
+
+type textObj struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type block struct {
+	Type     string    `json:"type"`
+	Text     *textObj  `json:"text,omitempty"`
+	Elements []textObj `json:"elements,omitempty"`
+}
+
+// message is a Slack Block Kit message.
+type message struct {
+	// Text is the fallback shown in notifications.
+	Text   string  `json:"text"`
+	Blocks []block `json:"blocks"`
+}
+
+// WriteSlackReport writes the report as Slack Block Kit JSON.
+func WriteSlackReport(w io.Writer, r *types.Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(makeMessage(r))
+}
+
+func makeMessage(r *types.Report) *message {
+	title := r.Title
+	if title == "" {
+		title = "Activity at " + r.DomainGh
+	}
+	m := &message{Text: title}
+	m.Blocks = append(m.Blocks, headerBlock(title))
+	if r.Dr != nil {
+		m.Blocks = append(m.Blocks, contextBlock(r.Dr.PrettyRange()))
+	}
+	if len(r.Warnings) > 0 {
+		m.Blocks = append(m.Blocks, contextBlock(
+			":warning: Incomplete: "+escape(strings.Join(r.Warnings, "\n"))))
+	}
+	for i, u := range r.Users {
+		ub := userBlocks(r.DomainGh, u)
+		// Reserve the last block for the "and N more" notice.
+		if len(m.Blocks)+len(ub) > maxBlocks-1 {
+			m.Blocks = append(m.Blocks, moreUsersBlock(r.DomainGh, r.Users[i:]))
+			break
+		}
+		m.Blocks = append(m.Blocks, ub...)
+	}
+	if len(r.Users) == 0 {
+		m.Blocks = append(m.Blocks, sectionBlock("*no users*"))
+	}
+	return m
+}
+
+func userBlocks(dGh string, u *types.MyUser) []block {
+	result := []block{headerBlock(userName(u))}
+	cats := common.UserCategories(dGh, u)
+	if len(cats) == 0 {
+		result = append(result, sectionBlock("_no activity_"))
+	}
+	for i := range cats {
+		result = append(result, sectionBlock(
+			renderCategory(&cats[i], myhttp.Scheme+common.UserHRef(dGh, u.Login))))
+	}
+	return append(result, block{Type: "divider"})
+}
+
+func userName(u *types.MyUser) string {
+	name := u.Login
+	if u.Name != "" {
+		name = u.Name + " (" + u.Login + ")"
+	}
+	if flag := common.JiraFlag(u); flag != "" {
+		name += " [" + flag + "]"
+	}
+	return name
+}
+
+// renderCategory renders one report section as mrkdwn, dropping whole repos
+// once the section would exceed Slack's text limit.
+func renderCategory(c *common.ItemCategory, moreHref string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s* (%d in %d repos)\n", escape(c.Label), c.Count(), len(c.Groups))
+	for i := range c.Groups {
+		chunk := renderGroup(&c.Groups[i])
+		more := link(moreHref, fmt.Sprintf("and %d more repos", len(c.Groups)-i))
+		if b.Len()+len(chunk)+len(more) > maxSectionText {
+			b.WriteString(more)
+			break
+		}
+		b.WriteString(chunk)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func renderGroup(g *common.ItemGroup) string {
+	var b strings.Builder
+	href := myhttp.Scheme + g.RepoHRef
+	fmt.Fprintf(&b, "*%s*\n", link(href, g.RepoLabel))
+	for i, item := range g.Items {
+		if i == maxItemsPerRepo {
+			fmt.Fprintf(&b, "• %s\n", link(href, fmt.Sprintf("and %d more", len(g.Items)-i)))
+			break
+		}
+		fmt.Fprintf(&b, "• `%s` %s\n",
+			item.When.Format(types.DayFormatHuman), link(item.Url, types.Clip(item.Title, maxTitleText)))
+	}
+	return b.String()
+}
+
+func moreUsersBlock(dGh string, users []*types.MyUser) block {
+	links := make([]string, len(users))
+	for i, u := range users {
+		links[i] = link(myhttp.Scheme+common.UserHRef(dGh, u.Login), u.Login)
+	}
+	return contextBlock(fmt.Sprintf("and %d more: %s", len(users), strings.Join(links, ", ")))
+}
+
+func headerBlock(s string) block {
+	return block{Type: "header", Text: &textObj{Type: "plain_text", Text: types.Clip(s, maxHeaderText)}}
+}
+
+func sectionBlock(s string) block {
+	return block{Type: "section", Text: &textObj{Type: "mrkdwn", Text: s}}
+}
+
+func contextBlock(s string) block {
+	return block{Type: "context", Elements: []textObj{{Type: "mrkdwn", Text: types.Clip(s, maxSectionText)}}}
+}
+
+func link(url, text string) string {
+	return "<" + url + "|" + escape(text) + ">"
+}
+
+// escape escapes the control characters of Slack's mrkdwn.
+// https://api.slack.com/reference/surfaces/formatting#escaping
+func escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
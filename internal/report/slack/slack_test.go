@@ -0,0 +1,88 @@
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/monopole/snips/internal/fake"
+	"github.com/monopole/snips/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_makeMessage(t *testing.T) {
+	dr, _ := types.MakeDayRange("2023/01/03", "", 3)
+	tests := map[string]struct {
+		users      []*types.MyUser
+		warnings   []string
+		wantBlocks int
+		wantText   []string
+	}{
+		"noUsers": {
+			wantBlocks: 3,
+			wantText:   []string{"*no users*"},
+		},
+		"warnings": {
+			warnings:   []string{"one <thing>", "another"},
+			wantBlocks: 4,
+			wantText:   []string{":warning: Incomplete: one &lt;thing&gt;\nanother"},
+		},
+		"itemsPerRepoCapped": {
+			users:      []*types.MyUser{fake.MakeUser("bob", 1, 8)},
+			wantBlocks: 5,
+			wantText: []string{
+				"*Issues Created* (8 in 1 repos)",
+				"<https://github.com/org/repo000|and 3 more>",
+				"bananas &amp; cheese",
+			},
+		},
+		"sectionTextCapped": {
+			users:      []*types.MyUser{fake.MakeUser("bob", 200, 5)},
+			wantBlocks: 5,
+			wantText:   []string{"more repos>"},
+		},
+		"blocksCapped": {
+			users: func() (r []*types.MyUser) {
+				for i := 0; i < 30; i++ {
+					r = append(r, fake.MakeUser(fmt.Sprintf("u%02d", i), 1, 1))
+				}
+				return
+			}(),
+			wantBlocks: maxBlocks - 2,
+			wantText:   []string{"and 15 more: <https://github.com/u15|u15>"},
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			m := makeMessage(&types.Report{DomainGh: "github.com", Dr: dr, Users: tt.users, Warnings: tt.warnings})
+			assert.Equal(t, tt.wantBlocks, len(m.Blocks))
+			var all strings.Builder
+			for _, b := range m.Blocks {
+				if b.Text != nil {
+					assert.LessOrEqual(t, len(b.Text.Text), maxSectionText)
+					all.WriteString(b.Text.Text)
+				}
+				for _, e := range b.Elements {
+					all.WriteString(e.Text)
+				}
+			}
+			for _, w := range tt.wantText {
+				assert.Contains(t, all.String(), w)
+			}
+		})
+	}
+}
+
+func Test_WriteSlackReport(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, WriteSlackReport(&b, &types.Report{
+		Title: "hello <world>",
+		Users: []*types.MyUser{fake.MakeUser("bob", 1, 1)},
+	}))
+	var m message
+	assert.NoError(t, json.Unmarshal(b.Bytes(), &m))
+	assert.Equal(t, "hello <world>", m.Text)
+	assert.Equal(t, "header", m.Blocks[0].Type)
+}
@@ -0,0 +1,195 @@
+package teams
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/monopole/snips/internal/myhttp"
+	"github.com/monopole/snips/internal/report/common"
+	"github.com/monopole/snips/internal/types"
+)
+
+const (
+	// maxPayloadBytes is the size limit on messages posted to a Teams webhook.
+	// https://learn.microsoft.com/en-us/microsoftteams/platform/webhooks-and-connectors/how-to/connectors-using#rate-limiting-for-connectors
+	maxPayloadBytes = 28 * 1024
+	// reservedBytes leaves room for the envelope and the "and N more" notice.
+	reservedBytes   = 2 * 1024
+	maxTitleText    = 120
+	maxItemsPerRepo = 5
+
+	contentTypeAdaptiveCard = "application/vnd.microsoft.card.adaptive"
+	adaptiveCardSchema      = "http://adaptivecards.io/schemas/adaptive-card.json"
+	adaptiveCardVersion     = "1.4"
+)
+
+type textBlock struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	Wrap     bool   `json:"wrap"`
+	Size     string `json:"size,omitempty"`
+	Weight   string `json:"weight,omitempty"`
+	IsSubtle bool   `json:"isSubtle,omitempty"`
+	Color    string `json:"color,omitempty"`
+}
+
+type card struct {
+	Schema  string      `json:"$schema"`
+	Type    string      `json:"type"`
+	Version string      `json:"version"`
+	Body    []textBlock `json:"body"`
+}
+
+type attachment struct {
+	ContentType string `json:"contentType"`
+	Content     card   `json:"content"`
+}
+
+// message is the envelope accepted by Teams incoming webhooks.
+type message struct {
+	Type        string       `json:"type"`
+	Attachments []attachment `json:"attachments"`
+}
+
+// WriteTeamsReport writes the report as a Teams message holding one Adaptive Card.
+func WriteTeamsReport(w io.Writer, r *types.Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(&message{
+		Type: "message",
+		Attachments: []attachment{{
+			ContentType: contentTypeAdaptiveCard,
+			Content: card{
+				Schema:  adaptiveCardSchema,
+				Type:    "AdaptiveCard",
+				Version: adaptiveCardVersion,
+				Body:    makeBody(r),
+			},
+		}},
+	})
+}
+
+// cardBuilder accumulates card elements until the payload budget is spent,
+// then counts what it had to leave out.
+type cardBuilder struct {
+	body      []textBlock
+	size      int
+	full      bool
+	skipped   int
+	skippedBy []string
+}
+
+func (cb *cardBuilder) add(tb textBlock) bool {
+	if cb.full {
+		return false
+	}
+	raw, _ := json.Marshal(tb)
+	if cb.size+len(raw) > maxPayloadBytes-reservedBytes {
+		cb.full = true
+		return false
+	}
+	cb.size += len(raw)
+	cb.body = append(cb.body, tb)
+	return true
+}
+
+func makeBody(r *types.Report) []textBlock {
+	title := r.Title
+	if title == "" {
+		title = "Activity at " + r.DomainGh
+	}
+	cb := &cardBuilder{}
+	cb.add(textBlock{Type: "TextBlock", Text: title, Wrap: true, Size: "Large", Weight: "Bolder"})
+	if r.Dr != nil {
+		cb.add(textBlock{Type: "TextBlock", Text: r.Dr.PrettyRange(), Wrap: true, IsSubtle: true})
+	}
+	for _, w := range r.Warnings {
+		cb.add(textBlock{Type: "TextBlock", Text: "Incomplete: " + w, Wrap: true, Color: "Warning"})
+	}
+	if len(r.Users) == 0 {
+		cb.add(textBlock{Type: "TextBlock", Text: "**no users**", Wrap: true})
+	}
+	for _, u := range r.Users {
+		addUser(cb, r.DomainGh, u)
+	}
+	if cb.skipped > 0 {
+		// Bypass the budget; reservedBytes was set aside for this.
+		cb.body = append(cb.body, textBlock{
+			Type: "TextBlock",
+			Text: fmt.Sprintf("and %d more from %s", cb.skipped, strings.Join(cb.skippedBy, ", ")),
+			Wrap: true, IsSubtle: true,
+		})
+	}
+	return cb.body
+}
+
+func addUser(cb *cardBuilder, dGh string, u *types.MyUser) {
+	userLink := link(myhttp.Scheme+common.UserHRef(dGh, u.Login), u.Login)
+	skippedBefore := cb.skipped
+	cats := common.UserCategories(dGh, u)
+	defer func() {
+		if cb.skipped > skippedBefore {
+			cb.skippedBy = append(cb.skippedBy, userLink)
+		}
+	}()
+	name := u.Login
+	if u.Name != "" {
+		name = u.Name + " (" + u.Login + ")"
+	}
+	if flag := common.JiraFlag(u); flag != "" {
+		name += " [" + flag + "]"
+	}
+	if !cb.add(textBlock{Type: "TextBlock", Text: name, Wrap: true, Size: "Large", Weight: "Bolder"}) {
+		cb.skipped += countItems(cats)
+		return
+	}
+	for i := range cats {
+		c := &cats[i]
+		if !cb.add(textBlock{
+			Type:   "TextBlock",
+			Text:   fmt.Sprintf("%s (%d in %d repos)", c.Label, c.Count(), len(c.Groups)),
+			Wrap:   true,
+			Size:   "Medium",
+			Weight: "Bolder",
+		}) {
+			cb.skipped += c.Count()
+			continue
+		}
+		for j := range c.Groups {
+			if !cb.add(textBlock{Type: "TextBlock", Text: renderGroup(&c.Groups[j]), Wrap: true}) {
+				cb.skipped += len(c.Groups[j].Items)
+			}
+		}
+	}
+}
+
+func countItems(cats []common.ItemCategory) int {
+	n := 0
+	for i := range cats {
+		n += cats[i].Count()
+	}
+	return n
+}
+
+func renderGroup(g *common.ItemGroup) string {
+	var b strings.Builder
+	href := myhttp.Scheme + g.RepoHRef
+	fmt.Fprintf(&b, "**%s**\n\n", link(href, g.RepoLabel))
+	for i, item := range g.Items {
+		if i == maxItemsPerRepo {
+			fmt.Fprintf(&b, "- %s\n", link(href, fmt.Sprintf("and %d more", len(g.Items)-i)))
+			break
+		}
+		fmt.Fprintf(&b, "- `%s` %s\n",
+			item.When.Format(types.DayFormatHuman), link(item.Url, types.Clip(item.Title, maxTitleText)))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// link makes a markdown link, replacing the brackets that
+// the Adaptive Card markdown subset can't escape.
+func link(url, text string) string {
+	return "[" + strings.NewReplacer("[", "(", "]", ")").Replace(text) + "](" + url + ")"
+}
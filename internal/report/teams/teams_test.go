@@ -0,0 +1,57 @@
+package teams
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/monopole/snips/internal/fake"
+	"github.com/monopole/snips/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_WriteTeamsReport(t *testing.T) {
+	tests := map[string]struct {
+		users    []*types.MyUser
+		wantText []string
+		dontWant []string
+	}{
+		"small": {
+			users: []*types.MyUser{fake.MakeUser("bob", 1, 7)},
+			wantText: []string{
+				"Issues Created (7 in 1 repos)",
+				"**[org/repo000](https://github.com/org/repo000)**",
+				"[fix (the) bananas & cheese](https://github.com/org/repo000/issues/0)",
+				"[and 2 more](https://github.com/org/repo000)",
+			},
+			dontWant: []string{"more from"},
+		},
+		"truncated": {
+			users: []*types.MyUser{fake.MakeUser("bob", 100, 3), fake.MakeUser("alice", 5, 3)},
+			wantText: []string{
+				"more from [bob](https://github.com/bob), [alice](https://github.com/alice)",
+			},
+		},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			var b bytes.Buffer
+			assert.NoError(t, WriteTeamsReport(&b, &types.Report{DomainGh: "github.com", Users: tt.users}))
+			assert.LessOrEqual(t, b.Len(), maxPayloadBytes)
+			var m message
+			assert.NoError(t, json.Unmarshal(b.Bytes(), &m))
+			assert.Equal(t, contentTypeAdaptiveCard, m.Attachments[0].ContentType)
+			var all strings.Builder
+			for _, tb := range m.Attachments[0].Content.Body {
+				all.WriteString(tb.Text + "\n")
+			}
+			for _, w := range tt.wantText {
+				assert.Contains(t, all.String(), w)
+			}
+			for _, w := range tt.dontWant {
+				assert.NotContains(t, all.String(), w)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/monopole/snips/internal/myhttp"
+)
+
+// Post sends the given JSON payload to a chat webhook (Slack, Teams, etc.).
+func Post(cl *http.Client, loc string, payload []byte) error {
+	// Webhook URLs are credentials, so keep them out of traces and errors.
+	req, err := http.NewRequestWithContext(
+		myhttp.WithSecretUrl(context.Background()), http.MethodPost, loc, bytes.NewReader(payload))
+	if err != nil {
+		return errors.New("bad webhook url")
+	}
+	req.Header.Set(myhttp.HeaderContentType, myhttp.ContentTypeJson)
+	resp, err := cl.Do(req)
+	if err != nil {
+		var uErr *url.Error
+		if errors.As(err, &uErr) {
+			err = uErr.Err
+		}
+		return fmt.Errorf("trouble posting to webhook at %s; %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook status code %d: %s", resp.StatusCode, myhttp.ErrBody(resp.Body))
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package webhook_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/monopole/snips/internal/myhttp"
+	. "github.com/monopole/snips/internal/report/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Post(t *testing.T) {
+	tests := map[string]struct {
+		status  int
+		wantErr string
+	}{
+		"ok":       {status: http.StatusOK},
+		"accepted": {status: http.StatusAccepted},
+		"rejected": {status: http.StatusBadRequest, wantErr: "webhook status code 400: invalid_blocks"},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			var got []byte
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, myhttp.ContentTypeJson, r.Header.Get(myhttp.HeaderContentType))
+				got, _ = io.ReadAll(r.Body)
+				w.WriteHeader(tt.status)
+				if tt.status >= 400 {
+					_, _ = w.Write([]byte("invalid_blocks\n"))
+				}
+			}))
+			defer srv.Close()
+			err := Post(srv.Client(), srv.URL+"/hook", []byte(`{"text":"hi"}`))
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, `{"text":"hi"}`, string(got))
+		})
+	}
+}
+
+func Test_PostUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	err := Post(srv.Client(), srv.URL+"/services/T0000/B0000/s3cr3t", []byte(`{"text":"hi"}`))
+	assert.ErrorContains(t, err, "trouble posting to webhook at 127.0.0.1:")
+	assert.NotContains(t, err.Error(), "s3cr3t")
+}
@@ -9,6 +9,7 @@ import (
 const (
 	DayFormatGitHub     = "2006-01-02"
 	DayFormatHuman      = "2006-Jan-02"
+	TimeFormatHuman     = "2006-Jan-02 15:04"
 	DayFormatJira       = "2006/01/02"
 	DateFormatJiraIssue = "2006-01-02T15:04:05.000-0700"
 	defaultDayCount     = 14 // two weeks
@@ -126,6 +127,11 @@ func (dr *DayRange) EndAsTime() time.Time {
 	return dr.StartAsTime().AddDate(0, 0, dr.DayCount-1)
 }
 
+// Contains is true if t falls on a day in the range.
+func (dr *DayRange) Contains(t time.Time) bool {
+	return !t.Before(dr.StartAsTime()) && t.Before(dr.EndAsTime().AddDate(0, 0, 1))
+}
+
 // PrettyRange returns a simplified date range as a string.
 func (dr *DayRange) PrettyRange() string {
 	d1 := dr.StartAsTime()
@@ -180,3 +180,23 @@ func TestDayRange_EndAsTime(t *testing.T) {
 		})
 	}
 }
+
+func TestDayRange_Contains(t *testing.T) {
+	dr := &DayRange{Year: 2020, Month: 3, Day: 18, DayCount: 2}
+	tests := map[string]struct {
+		t    time.Time
+		want bool
+	}{
+		"before":    {t: time.Date(2020, 3, 17, 23, 59, 0, 0, time.Local)},
+		"start":     {t: time.Date(2020, 3, 18, 0, 0, 0, 0, time.Local), want: true},
+		"last day":  {t: time.Date(2020, 3, 19, 23, 59, 0, 0, time.Local), want: true},
+		"after end": {t: time.Date(2020, 3, 20, 0, 0, 0, 0, time.Local)},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := dr.Contains(tc.t); got != tc.want {
+				t.Errorf("Contains() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
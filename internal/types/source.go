@@ -0,0 +1,68 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrUnknownUser means a user can't be found on a source of activity,
+// e.g. a jira or gerrit instance.
+var ErrUnknownUser = errors.New("unknown user")
+
+type unknownUserError struct {
+	source, who string
+}
+
+func (e *unknownUserError) Error() string {
+	return fmt.Sprintf("unknown %s user %q", e.source, e.who)
+}
+
+func (e *unknownUserError) Is(target error) bool {
+	return target == ErrUnknownUser
+}
+
+// UnknownUser returns an ErrUnknownUser naming the source and who was sought.
+func UnknownUser(source, who string) error {
+	return &unknownUserError{source: source, who: who}
+}
+
+// WarningList holds problems worth mentioning in the report,
+// e.g. search results cut off by a limit, or unknown users.
+// Searchers embed it to collect warnings as they go.
+type WarningList struct {
+	list []string
+}
+
+// Warnings returns the warnings recorded so far.
+func (wl *WarningList) Warnings() []string {
+	return wl.list
+}
+
+// Warn records a warning, unless it's already been recorded.
+func (wl *WarningList) Warn(format string, args ...any) {
+	if w := fmt.Sprintf(format, args...); !slices.Contains(wl.list, w) {
+		wl.list = append(wl.list, w)
+	}
+}
+
+// FirstLine returns the first non-blank line of a message, trimmed.
+func FirstLine(msg string) string {
+	for _, line := range strings.Split(msg, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// Clip shortens s to at most n runes, ending it with an ellipsis if cut,
+// e.g. to fit the text limits of chat messages.
+func Clip(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[0:n-1]) + "…"
+}
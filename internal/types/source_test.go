@@ -0,0 +1,60 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnknownUser(t *testing.T) {
+	err := fmt.Errorf("looking up; %w", UnknownUser("gerrit", "ghost"))
+	assert.True(t, errors.Is(err, ErrUnknownUser))
+	assert.EqualError(t, err, `looking up; unknown gerrit user "ghost"`)
+}
+
+func TestWarningList(t *testing.T) {
+	var wl WarningList
+	assert.Empty(t, wl.Warnings())
+	wl.Warn("%d issues skipped", 3)
+	wl.Warn("user %s unknown", "ghost")
+	wl.Warn("%d issues skipped", 3)
+	assert.Equal(t, []string{"3 issues skipped", "user ghost unknown"}, wl.Warnings())
+}
+
+func TestFirstLine(t *testing.T) {
+	tests := map[string]struct {
+		msg  string
+		want string
+	}{
+		"empty":   {},
+		"blank":   {msg: " \n\t\n", want: ""},
+		"one":     {msg: "Fix the thing", want: "Fix the thing"},
+		"leading": {msg: "\n  Fix the thing  \n\nbecause", want: "Fix the thing"},
+		"crlf":    {msg: "Fix the thing\r\nbecause", want: "Fix the thing"},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			assert.Equal(t, tt.want, FirstLine(tt.msg))
+		})
+	}
+}
+
+func TestClip(t *testing.T) {
+	tests := map[string]struct {
+		s    string
+		n    int
+		want string
+	}{
+		"empty":   {n: 3},
+		"short":   {s: "abc", n: 3, want: "abc"},
+		"long":    {s: "abcd", n: 3, want: "ab…"},
+		"unicode": {s: "ünïcödé", n: 4, want: "ünï…"},
+	}
+	for n, tt := range tests {
+		t.Run(n, func(t *testing.T) {
+			assert.Equal(t, tt.want, Clip(tt.s, tt.n))
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// StoppedError means a search stopped because its context ended,
+// e.g. on Ctrl-C, before finishing the activity of some users.
+type StoppedError struct {
+	Err error
+	// Logins are the users whose activity is incomplete.
+	Logins []string
+}
+
+func (e *StoppedError) Error() string {
+	return fmt.Sprintf("stopped before finishing %s; %s", strings.Join(e.Logins, ", "), e.Err)
+}
+
+func (e *StoppedError) Unwrap() error {
+	return e.Err
+}
+
+// Stopped returns err as a StoppedError naming the given users if ctx
+// has ended, as err likely came of that, else returns err as is.
+func Stopped(ctx context.Context, err error, users []*MyUser) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	logins := make([]string, len(users))
+	for i, u := range users {
+		logins[i] = u.Login
+	}
+	return &StoppedError{Err: err, Logins: logins}
+}
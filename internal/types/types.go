@@ -1,20 +1,33 @@
 package types
 
 import (
+	"sort"
 	"time"
 )
 
 type RepoId struct {
 	Org  string
 	Name string
+	// Host, if not empty, is one of the Host* kinds of code host
+	// holding the repo, meaning Org is the domain of that host
+	// rather than a GitHub org or nothing.
+	Host string
 }
 
+// Kinds of code host whose repos aren't on the report's domains.
+const (
+	// HostGerrit repos are Gerrit projects, whose names may hold slashes.
+	HostGerrit = "gerrit"
+	// HostBitbucket repos are named "{projectKey}/{repoSlug}".
+	HostBitbucket = "bitbucket"
+)
+
 func (id RepoId) String() string {
 	return id.Org + "/" + id.Name
 }
 
 func (id RepoId) Equals(other RepoId) bool {
-	return id.Org == other.Org && id.Name == other.Name
+	return id == other
 }
 
 // MyGhOrg is a GitHub Organization.
@@ -26,11 +39,35 @@ type MyGhOrg struct {
 // MyIssue holds an issue or a pull request.
 // In GitHub, at a high level, an issue and a pull request has the same representation.
 type MyIssue struct {
-	RepoId  RepoId
-	Number  int
+	RepoId RepoId
+	Number int
+	// Key is the Jira issue key, e.g. "MSFT-12", for issues from Jira.
+	Key     string
 	Title   string
 	HtmlUrl string
 	Updated time.Time
+	// State is the issue's state as reported by its source,
+	// e.g. "open" or "closed" on GitHub, or the status name in Jira.
+	State string
+	// Epic is the key of the Jira epic holding the issue, e.g. "MSFT-100".
+	Epic string
+	// EpicTitle is the summary of the epic, if known.
+	EpicTitle string
+	// Sprints are the names of the Jira sprints the issue has been in, oldest first.
+	Sprints []string
+	// Comments are the user's own comments on the issue in the
+	// report's day range, oldest first, if known.
+	Comments []MyComment
+	// Votes are the user's review votes on a Gerrit change, e.g. "Code-Review+2".
+	Votes []string
+}
+
+// MyComment is a comment on an issue.
+type MyComment struct {
+	Url  string
+	When time.Time
+	// FirstLine is the first line of the comment's text.
+	FirstLine string
 }
 
 type MyCommit struct {
@@ -43,9 +80,25 @@ type MyCommit struct {
 	Pr               *MyIssue
 }
 
+// Ways to group jira issues in a report.
+const (
+	GroupByProject = "project"
+	GroupByEpic    = "epic"
+	GroupBySprint  = "sprint"
+)
+
+// AllGroupings returns the ways to group jira issues.
+func AllGroupings() []string {
+	return []string{GroupByProject, GroupByEpic, GroupBySprint}
+}
+
 type IssueSet struct {
 	Domain string
 	Groups map[RepoId][]MyIssue
+	// GroupedBy, if not empty, is how the jira issues in Groups are grouped,
+	// e.g. GroupByEpic means the RepoId of each group holds an epic's key (in Name)
+	// and summary (in Org), rather than a project.
+	GroupedBy string
 }
 
 func (is *IssueSet) Count() int {
@@ -64,17 +117,155 @@ func (is *IssueSet) RepoCount() int {
 	return len(is.Groups)
 }
 
+// Add puts the issues in the group of the given repo.
+func (is *IssueSet) Add(id RepoId, issues ...MyIssue) {
+	if is.Groups == nil {
+		is.Groups = make(map[RepoId][]MyIssue)
+	}
+	is.Groups[id] = append(is.Groups[id], issues...)
+}
+
+// SortByUpdated orders the issues in each group from most to least recently updated.
+func (is *IssueSet) SortByUpdated() {
+	for _, lst := range is.Groups {
+		sort.SliceStable(lst, func(i, j int) bool {
+			return lst[i].Updated.After(lst[j].Updated)
+		})
+	}
+}
+
+// MyWorklog is the time a user logged against one issue.
+type MyWorklog struct {
+	Issue MyIssue
+	Spent time.Duration
+}
+
+// WorklogSet holds a user's worklogs, grouped by project.
+type WorklogSet struct {
+	Domain string
+	Groups map[RepoId][]MyWorklog
+	// GroupedBy is as in IssueSet.
+	GroupedBy string
+}
+
+// Total is the time logged across all projects.
+func (ws *WorklogSet) Total() time.Duration {
+	var t time.Duration
+	for id := range ws.Groups {
+		t += ws.RepoTotal(id)
+	}
+	return t
+}
+
+// RepoTotal is the time logged in the given project.
+func (ws *WorklogSet) RepoTotal(id RepoId) time.Duration {
+	var t time.Duration
+	for _, wl := range ws.Groups[id] {
+		t += wl.Spent
+	}
+	return t
+}
+
+// Count is the number of issues with time logged.
+func (ws *WorklogSet) Count() int {
+	c := 0
+	for _, v := range ws.Groups {
+		c += len(v)
+	}
+	return c
+}
+
+func (ws *WorklogSet) IsEmpty() bool {
+	return ws.Count() == 0
+}
+
+func (ws *WorklogSet) RepoCount() int {
+	return len(ws.Groups)
+}
+
+// MyTransition is a change of an issue's workflow status.
+type MyTransition struct {
+	Issue MyIssue
+	From  string
+	To    string
+	When  time.Time
+}
+
+// TransitionSet holds the status transitions a user made, grouped by project.
+type TransitionSet struct {
+	Domain string
+	Groups map[RepoId][]MyTransition
+	// GroupedBy is as in IssueSet.
+	GroupedBy string
+}
+
+func (ts *TransitionSet) Count() int {
+	c := 0
+	for _, v := range ts.Groups {
+		c += len(v)
+	}
+	return c
+}
+
+func (ts *TransitionSet) IsEmpty() bool {
+	return ts.Count() == 0
+}
+
+func (ts *TransitionSet) RepoCount() int {
+	return len(ts.Groups)
+}
+
+// CustomIssueSet is an IssueSet found by a search defined in the config file.
+type CustomIssueSet struct {
+	Label  string
+	Issues *IssueSet
+}
+
+// States of a user's Jira account.
+const (
+	JiraAccountActive   = "active"
+	JiraAccountInactive = "inactive"
+	// JiraAccountUnknown means the user couldn't be found in Jira.
+	JiraAccountUnknown = "unknown"
+	// JiraAccountAmbiguous means several Jira accounts match the user.
+	JiraAccountAmbiguous = "ambiguous"
+)
+
 type MyUser struct {
-	Name            string
-	Company         string
-	Login           string
-	Email           string
+	Name    string
+	Company string
+	Login   string
+	Email   string
+	// AvatarUrl locates the user's picture, if known.
+	AvatarUrl string
+	// JiraAccount is one of the JiraAccount* states, or empty if Jira wasn't searched.
+	JiraAccount     string
 	GhOrgs          []MyGhOrg
 	IssuesCreated   *IssueSet
 	IssuesClosed    *IssueSet
 	IssuesCommented *IssueSet
 	PrsReviewed     *IssueSet
 	Commits         map[RepoId][]*MyCommit
+	// TimeLogged is the time logged in Jira.
+	TimeLogged *WorklogSet
+	// Transitions are the Jira issue status changes made by the user.
+	Transitions *TransitionSet
+	// Custom are extra sections, e.g. from searches in the config file.
+	Custom []CustomIssueSet
+}
+
+// AddProfile fills in what the user lacks from their profile on
+// some source, e.g. the name of a user not looked up on GitHub.
+func (u *MyUser) AddProfile(name, email, avatarUrl string) {
+	if (u.Name == "" || u.Name == u.Login) && name != "" {
+		u.Name = name
+	}
+	if u.Email == "" {
+		u.Email = email
+	}
+	if u.AvatarUrl == "" {
+		u.AvatarUrl = avatarUrl
+	}
 }
 
 type Report struct {
@@ -83,4 +274,7 @@ type Report struct {
 	DomainJira string
 	Dr         *DayRange
 	Users      []*MyUser
+	// Warnings are problems that leave the report incomplete,
+	// e.g. search results cut off by a limit.
+	Warnings []string
 }
@@ -1,23 +1,43 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	_ "embed"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/google/go-github/v52/github"
+	"github.com/monopole/snips/internal/config"
+	"github.com/monopole/snips/internal/creds"
 	"github.com/monopole/snips/internal/fake"
+	"github.com/monopole/snips/internal/mybitbucket"
+	"github.com/monopole/snips/internal/mygerrit"
 	"github.com/monopole/snips/internal/mygh/client"
 	"github.com/monopole/snips/internal/mygh/oauth"
 	"github.com/monopole/snips/internal/mygh/search"
 	"github.com/monopole/snips/internal/myhttp"
 	"github.com/monopole/snips/internal/myjira"
 	"github.com/monopole/snips/internal/pgmargs"
+	"github.com/monopole/snips/internal/progress"
+	"github.com/monopole/snips/internal/report/common"
+	"github.com/monopole/snips/internal/report/csv"
 	"github.com/monopole/snips/internal/report/html"
 	"github.com/monopole/snips/internal/report/md"
+	"github.com/monopole/snips/internal/report/slack"
+	"github.com/monopole/snips/internal/report/teams"
+	"github.com/monopole/snips/internal/report/webhook"
 	"github.com/monopole/snips/internal/types"
+	"io"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
 //go:embed README.md
@@ -31,87 +51,387 @@ func main() {
 		fmt.Fprintf(os.Stderr, "\n")
 		os.Exit(1)
 	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(
+		progress.Writer(os.Stderr), &slog.HandlerOptions{Level: args.LogLevel})))
+	if len(args.AuthCommand) > 0 {
+		if err = runAuthCommand(args); err != nil {
+			log.Fatal(err.Error())
+		}
+		return
+	}
+	if args.DumpTemplatesDir != "" {
+		if err = dumpTemplates(args); err != nil {
+			log.Fatal(err.Error())
+		}
+		return
+	}
 	if !args.TestRenderOnly && !args.JustGetGhToken && len(args.UserNames) == 0 {
 		_, _ = fmt.Fprintln(os.Stderr, readMeMd)
 		os.Exit(0)
 	}
 
-	var users []*types.MyUser
+	// All services share one client, and so one set of TLS settings.
+	htCl, err := myhttp.MakeHttpClient(&args.Tls)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	if args.Trace.On {
+		if err = trace(htCl, &args.Trace); err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+	var (
+		users    []*types.MyUser
+		warnings []string
+	)
 	if args.TestRenderOnly {
 		users = fake.MakeSliceOfFakeUserData()
 	} else {
-		if users, err = getUserData(args); err != nil {
+		ctx, cancel := runContext(args.Timeout)
+		users, warnings, err = getUserData(ctx, args, htCl)
+		cancel()
+		if err != nil {
 			log.Fatal(err.Error())
 		}
 	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+	}
 
-	writeF := html.WriteHtmlReport
-	if args.Markdown {
-		writeF = md.WriteMdReport
+	report := &types.Report{
+		Title:      args.Title,
+		DomainGh:   args.Gh.Domain,
+		DomainJira: args.Jira.Domain,
+		Dr:         args.DateRange,
+		Users:      users,
+		Warnings:   warnings,
 	}
-	if err = writeF(
-		os.Stdout,
-		&types.Report{
-			Title:      args.Title,
-			DomainGh:   args.Gh.Domain,
-			DomainJira: args.Jira.Domain,
-			Dr:         args.DateRange,
-			Users:      users,
-		}); err != nil {
+	writeF, err := pickWriter(args)
+	if err != nil {
 		log.Fatal(err.Error())
 	}
+	if args.WebhookUrl != "" {
+		if err = postReport(args, htCl, writeF, report); err != nil {
+			log.Fatal(err.Error())
+		}
+		return
+	}
+	if err = writeF(os.Stdout, report); err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+// runContext returns a context ending on Ctrl-C, SIGTERM or, if
+// positive, the timeout.  Once it ends, signals are no longer caught,
+// so a second Ctrl-C stops snips at once, rather than after reporting.
+func runContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	cancel := stop
+	if timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		cancel = func() {
+			cancelTimeout()
+			stop()
+		}
+	}
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, cancel
 }
 
-func getUserData(args *pgmargs.Args) ([]*types.MyUser, error) {
-	htCl, err := myhttp.MakeHttpClient(args.CaPath)
+// partialWarning returns a warning that collection stopped early, why,
+// and whose activity is incomplete as a result.
+func partialWarning(ctx context.Context, timeout time.Duration, incomplete []string) string {
+	why := "collection was interrupted"
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		why = fmt.Sprintf("collection stopped after the %s timeout", timeout)
+	}
+	return fmt.Sprintf("%s; only what was gathered by then is reported, so the activity of %s is incomplete",
+		why, strings.Join(incomplete, ", "))
+}
+
+// trace makes the client trace its requests.
+func trace(htCl *http.Client, args *pgmargs.TraceArgs) error {
+	w := progress.Writer(os.Stderr)
+	if args.Path != "" {
+		// Even redacted, traces may say more than others should see.
+		f, err := os.OpenFile(args.Path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			return fmt.Errorf("trouble opening trace file; %w", err)
+		}
+		w = f
+	}
+	myhttp.Trace(htCl, w, args.Bodies)
+	return nil
+}
+
+func pickWriter(args *pgmargs.Args) (func(io.Writer, *types.Report) error, error) {
+	switch args.Format {
+	case pgmargs.FormatMd:
+		if args.TemplateDir != "" {
+			return md.MakeMdReportWriter(args.TemplateDir)
+		}
+		return md.WriteMdReport, nil
+	case pgmargs.FormatSlack:
+		return slack.WriteSlackReport, nil
+	case pgmargs.FormatTeams:
+		return teams.WriteTeamsReport, nil
+	case pgmargs.FormatCsv:
+		return csv.WriteCsvReport, nil
+	case pgmargs.FormatTsv:
+		return csv.WriteTsvReport, nil
+	default:
+		if args.TemplateDir != "" {
+			return html.MakeHtmlReportWriter(args.TemplateDir)
+		}
+		return html.WriteHtmlReport, nil
+	}
+}
+
+func dumpTemplates(args *pgmargs.Args) error {
+	tmpls := html.HtmlTemplates()
+	if args.Format == pgmargs.FormatMd {
+		tmpls = md.MdTemplates()
+	}
+	if err := common.WriteTemplateDir(args.DumpTemplatesDir, tmpls); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d templates to %s\n", len(tmpls), args.DumpTemplatesDir)
+	return nil
+}
+
+func postReport(args *pgmargs.Args, htCl *http.Client,
+	writeF func(io.Writer, *types.Report) error, report *types.Report) error {
+	var b bytes.Buffer
+	if err := writeF(&b, report); err != nil {
+		return err
+	}
+	return webhook.Post(htCl, args.WebhookUrl, b.Bytes())
+}
+
+// getGhToken returns the GitHub token saved for the domain, or, failing
+// that (or if forced to), logs in with the device flow and saves the new
+// token for later runs.
+func getGhToken(ctx context.Context, args *pgmargs.Args, htCl *http.Client, forceLogin bool) (string, error) {
+	store, err := creds.MakeStore(args.CredsPath, args.CredsPassphrase)
 	if err != nil {
+		return "", err
+	}
+	if !forceLogin {
+		var saved *creds.Token
+		if saved, err = store.Get(args.Gh.Domain); err != nil {
+			return "", passphraseHint(err)
+		}
+		if saved != nil {
+			return saved.Value, nil
+		}
+	}
+	if args.Gh.ClientId == "" {
+		return "", fmt.Errorf(
+			"snips has no OAuth client id for %s; register one with 'snips %s %s %s {clientId}', or supply a token",
+			args.Gh.Domain, pgmargs.CmdAuth, config.CmdAddDomain, args.Gh.Domain)
+	}
+	token, err := oauth.GetAccessToken(ctx, &oauth.Params{
+		GhDomain: args.Gh.Domain,
+		ClientId: args.Gh.ClientId,
+		HttpCl:   htCl,
+		Out:      progress.Writer(os.Stderr),
+	})
+	if err != nil {
+		return "", err
+	}
+	if err = store.Put(args.Gh.Domain, creds.Token{Value: token.Value, Scopes: token.Scope}); err != nil {
+		fmt.Fprintf(os.Stderr, "%s Login successful, but the token wasn't saved; %s\n", oauth.WarningPrefix, err)
+		return token.Value, nil
+	}
+	if !args.NoTokenEcho {
+		fmt.Fprintf(os.Stderr, "%s Login successful; the token is saved in %s for later runs.\n",
+			oauth.WarningPrefix, store.Path())
+		fmt.Fprintf(os.Stderr, "%s To manage saved tokens, see: snips %s %s\n",
+			oauth.WarningPrefix, pgmargs.CmdAuth, strings.Join(pgmargs.AllAuthCommands(), "|"))
+	}
+	return token.Value, nil
+}
+
+// makeCheckedGhClient returns a GitHub client, authenticated as a GitHub
+// App if one is given, whose token works and has the scopes snips needs.
+// If it doesn't, and mayLogin is true, it offers to log in again.
+func makeCheckedGhClient(
+	ctx context.Context, args *pgmargs.Args, htCl *http.Client, mayLogin bool) (*github.Client, error) {
+	if args.GhApp.Id != 0 {
+		// Apps can't log in again, and have permissions rather than scopes.
+		return client.MakeCheckedAppClient(ctx, htCl, args.Gh.Domain, args.Gh.ApiUrl, &args.GhApp)
+	}
+	ghCl, err := client.MakeGhApiClient(
+		ctx, htCl, args.Gh.Domain, args.Gh.ApiUrl, client.StaticTokenSource(args.Gh.Token))
+	if err != nil {
+		return nil, fmt.Errorf("trouble making github client: %w", err)
+	}
+	missing, err := client.CheckToken(ctx, ghCl)
+	if err != nil && !errors.Is(err, client.ErrBadToken) {
 		return nil, err
 	}
-	if args.JustGetGhToken || args.Gh.Token == "" {
-		args.Gh.Token, err = oauth.GetAccessToken(&oauth.Params{
-			GhDomain: args.Gh.Domain,
-			ClientId: args.Gh.ClientId,
-			HttpCl:   htCl,
-			Verbose:  false,
-		})
-		if err != nil {
-			return nil, err
+	if err == nil && len(missing) == 0 {
+		return ghCl, nil
+	}
+	if err == nil {
+		err = fmt.Errorf("the github token for %s lacks scopes %s; snips needs %s",
+			args.Gh.Domain, strings.Join(missing, ", "), strings.Join(client.RequiredScopes, ", "))
+	}
+	if !mayLogin || !confirm(fmt.Sprintf("%s.\nLog in to %s again?", err, args.Gh.Domain)) {
+		return nil, fmt.Errorf(
+			"%w; log in again with --just-get-gh-token, or make a token with scopes %s",
+			err, strings.Join(client.RequiredScopes, ", "))
+	}
+	if args.Gh.Token, err = getGhToken(ctx, args, htCl, true); err != nil {
+		return nil, err
+	}
+	return makeCheckedGhClient(ctx, args, htCl, false)
+}
+
+// confirm asks a yes or no question on the terminal, returning
+// false without asking if stdin isn't a terminal.
+func confirm(question string) bool {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "y")
+}
+
+// passphraseHint adds advice to errors caused by a missing passphrase.
+func passphraseHint(err error) error {
+	if errors.Is(err, creds.ErrNeedPassphrase) {
+		return fmt.Errorf("%w; set env var %s", err, pgmargs.EnvPassphrase)
+	}
+	return err
+}
+
+// runAuthCommand manages the saved tokens, or registers a GitHub domain.
+func runAuthCommand(args *pgmargs.Args) error {
+	if args.AuthCommand[0] == config.CmdAddDomain {
+		return config.RunAddDomain(os.Stdout, args.ConfigPath, args.AuthCommand[1:])
+	}
+	store, err := creds.MakeStore(args.CredsPath, args.CredsPassphrase)
+	if err != nil {
+		return err
+	}
+	return passphraseHint(store.RunCommand(os.Stdout, args.AuthCommand, args.Gh.Domain))
+}
+
+// countTasks returns the number of progress.Tasks collection takes:
+// one per user per source, but one for all users of bitbucket.
+func countTasks(args *pgmargs.Args) int {
+	n := 0
+	if !args.SkipGh {
+		n += len(args.UserNames)
+	}
+	if args.Jira.Token != "" {
+		n += len(args.UserNames)
+	}
+	if args.Gerrit.Domain != "" {
+		n += len(args.UserNames)
+	}
+	if args.Bitbucket.Domain != "" {
+		n++
+	}
+	return n
+}
+
+// getUserData returns the users' activity, and warnings about
+// anything that leaves that activity incomplete.  If ctx ends during
+// collection, it returns what was gathered so far, with a warning
+// that it's partial, rather than an error.
+func getUserData(ctx context.Context, args *pgmargs.Args, htCl *http.Client) ([]*types.MyUser, []string, error) {
+	var err error
+	if args.GhApp.Id == 0 && (args.JustGetGhToken || args.Gh.Token == "") {
+		if args.Gh.Token, err = getGhToken(ctx, args, htCl, args.JustGetGhToken); err != nil {
+			return nil, nil, err
 		}
 		if args.JustGetGhToken {
 			fmt.Println(args.Gh.Token)
-			return nil, nil
+			return nil, nil, nil
 		}
-		if !args.NoTokenEcho {
-			pgmargs.EchoToken(oauth.WarningPrefix, args.Gh.Token)
+	}
+	// Check tokens before collecting, rather than failing minutes later.
+	if args.Jira.Token != "" {
+		if err = myjira.MakeJiraBoss(ctx, htCl, &args.Jira, args.DateRange).CheckToken(); err != nil {
+			return nil, nil, err
 		}
 	}
-	ctx := context.Background()
+	if args.Progress && progress.IsTerminal(os.Stderr) {
+		defer progress.Start(os.Stderr, countTasks(args)).Stop()
+	}
+	type searcher interface {
+		DoSearch(users []*types.MyUser) error
+		Warnings() []string
+	}
+	var searchers []searcher
+	if args.Jira.Token != "" {
+		searchers = append(searchers, myjira.MakeJiraBoss(ctx, htCl, &args.Jira, args.DateRange))
+	}
+	if args.Gerrit.Domain != "" {
+		searchers = append(searchers, mygerrit.MakeGerritBoss(ctx, htCl, &args.Gerrit, args.DateRange))
+	}
+	if args.Bitbucket.Domain != "" {
+		searchers = append(searchers, mybitbucket.MakeBitbucketBoss(ctx, htCl, &args.Bitbucket, args.DateRange))
+	}
 	var (
-		ghCl  *github.Client
-		users []*types.MyUser
+		ghCl     *github.Client
+		users    []*types.MyUser
+		warnings []string
 	)
+	// stopped is true if err came of ctx ending, in which case the rest
+	// of the sources are skipped, and what's gathered is reported.
+	// skipping is true if some sources are yet to search.
+	stopped := func(err error, skipping bool) bool {
+		var sErr *types.StoppedError
+		if !errors.As(err, &sErr) {
+			return false
+		}
+		slog.Warn("collection stopped; reporting what was gathered", "err", err)
+		incomplete := sErr.Logins
+		if skipping {
+			incomplete = make([]string, len(users))
+			for i, u := range users {
+				incomplete[i] = u.Login
+			}
+		}
+		warnings = append(warnings, partialWarning(ctx, args.Timeout, incomplete))
+		return true
+	}
 	if args.SkipGh {
 		users = make([]*types.MyUser, len(args.UserNames))
 		for i, n := range args.UserNames {
 			users[i] = &types.MyUser{Name: n, Login: n}
 		}
 	} else {
-		ghCl, err = client.MakeGhApiClient(ctx, args.Gh.Domain, args.Gh.Token)
-		if err != nil {
-			return nil, fmt.Errorf("trouble making github client: %w", err)
+		if ghCl, err = makeCheckedGhClient(ctx, args, htCl, true); err != nil {
+			return nil, nil, err
 		}
 		users, err = search.MakeEngine(
-			ctx, ghCl, args.Gh.Domain).LookupPeeps(args.UserNames, args.DateRange)
+			ctx, ghCl, args.Gh.Domain, args.Config.GitHub.Sections).LookupPeeps(args.UserNames, args.DateRange)
+		if stopped(err, len(searchers) > 0) {
+			return users, warnings, nil
+		}
 		if err != nil {
-			return nil, fmt.Errorf("trouble doing queries: %w", err)
+			return nil, nil, fmt.Errorf("trouble doing queries: %w", err)
 		}
 	}
-	if args.Jira.Token != "" {
-		err = myjira.MakeJiraBoss(
-			htCl, &args.Jira, args.DateRange).DoSearch(users)
+	for i, s := range searchers {
+		err = s.DoSearch(users)
+		warnings = append(warnings, s.Warnings()...)
+		if stopped(err, i < len(searchers)-1) {
+			return users, warnings, nil
+		}
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
-	return users, nil
+	return users, warnings, nil
 }